@@ -1,36 +1,90 @@
 package gopay
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/mail"
 	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/go-resty/resty/v2"
+	"github.com/google/uuid"
 )
 
 type AdminClient interface {
 	NewNewPaymentService() NewPaymentService
 	NewAllPaymentService() AllPaymentService
 	NewGetPaymentService() GetPaymentService
+	NewResendService() ResendService
+	NewAuditService() AuditService
+	NewDenialService() DenialService
+	// WithActor returns client which sends the actor in HeaderActor, the server records it in the audit log
+	// as the author of the changes made with the API key of the client
+	WithActor(actor string) AdminClient
 }
 
-func NewAdminClient(serverURL string) (AdminClient, error) {
+type AdminClientOption func(api *resty.Client)
+
+// WithAPIKey authenticates requests with the API key, admin routes reject requests without it
+func WithAPIKey(key string) AdminClientOption {
+	return func(api *resty.Client) {
+		api.SetAuthToken(key)
+	}
+}
+
+func NewAdminClient(serverURL string, opts ...AdminClientOption) (AdminClient, error) {
 	baseURL, err := url.ParseRequestURI(serverURL)
 	if err != nil {
 		return nil, fmt.Errorf("gopay.NewAdminClient: %w", err)
 	}
 
-	apiURL := baseURL.JoinPath("api").String()
+	api := resty.New().SetBaseURL(baseURL.JoinPath("api").String())
+
+	for _, opt := range opts {
+		opt(api)
+	}
+
+	return &adminClientImpl{api: api}, nil
+}
+
+// responseErrors are restored from codes of error responses of the API
+var responseErrors = map[string]error{
+	"not_found":              ErrNotFound,
+	"already_exists":         ErrAlreadyExists,
+	"invalid_transition":     ErrInvalidTransition,
+	"provider_unavailable":   ErrProviderUnavailable,
+	"idempotency_key_reused": ErrIdempotencyKeyReused,
+	"request_in_progress":    ErrRequestInProgress,
+	"forbidden":              ErrForbidden,
+}
+
+// responseError returns error for the unsuccessful response, known errors can be checked with errors.Is
+func responseError(resp *resty.Response) error {
+	var problem struct {
+		Code   string `json:"code"`
+		Detail string `json:"detail"`
+	}
+
+	if err := json.Unmarshal(resp.Body(), &problem); err != nil || problem.Code == "" {
+		return fmt.Errorf("error response from API %s", resp.String())
+	}
+
+	if err, ok := responseErrors[problem.Code]; ok {
+		return fmt.Errorf("error response from API %s: %w", problem.Detail, err)
+	}
 
-	return &adminClientImpl{api: resty.New().SetBaseURL(apiURL)}, nil
+	return fmt.Errorf("error response from API %s (%s)", problem.Detail, problem.Code)
 }
 
 type adminClientImpl struct {
 	api *resty.Client
 }
 
+// NewNewPaymentService returns service with a new idempotency key, so repeated calls of Do create one payment
 func (i *adminClientImpl) NewNewPaymentService() NewPaymentService {
-	return &newPaymentServiceImpl{api: i.api}
+	return &newPaymentServiceImpl{api: i.api, idempotencyKey: uuid.NewString()}
 }
 
 func (i *adminClientImpl) NewAllPaymentService() AllPaymentService {
@@ -41,22 +95,47 @@ func (i *adminClientImpl) NewGetPaymentService() GetPaymentService {
 	return &getPaymentServiceImpl{api: i.api}
 }
 
+func (i *adminClientImpl) NewResendService() ResendService {
+	return &resendServiceImpl{api: i.api}
+}
+
+func (i *adminClientImpl) NewAuditService() AuditService {
+	return &auditServiceImpl{api: i.api}
+}
+
+func (i *adminClientImpl) NewDenialService() DenialService {
+	return &denialServiceImpl{api: i.api}
+}
+
+// WithActor must not be called concurrently with requests of the client, resty clones clients shallowly,
+// so headers are copied to keep the actor out of the original client
+func (i *adminClientImpl) WithActor(actor string) AdminClient {
+	api := i.api.Clone()
+	api.Header = i.api.Header.Clone()
+
+	return &adminClientImpl{api: api.SetHeader(HeaderActor, actor)}
+}
+
 type NewPaymentService interface {
 	Currency(currency string) NewPaymentService
 	Amount(amount uint) NewPaymentService
 	Description(description string) NewPaymentService
 	ResourceLink(link Link) NewPaymentService
+	ProductID(id ID) NewPaymentService
+	IdempotencyKey(key string) NewPaymentService
 	Do() (Link, error)
 
 	String() string
 }
 
 type newPaymentServiceImpl struct {
-	api         *resty.Client
-	currency    string
-	amount      uint
-	description string
-	link        Link
+	api            *resty.Client
+	currency       string
+	amount         uint
+	description    string
+	link           Link
+	productID      ID
+	idempotencyKey string
 }
 
 func (i *newPaymentServiceImpl) Currency(currency string) NewPaymentService {
@@ -83,6 +162,19 @@ func (i *newPaymentServiceImpl) ResourceLink(link Link) NewPaymentService {
 	return i
 }
 
+func (i *newPaymentServiceImpl) ProductID(id ID) NewPaymentService {
+	i.productID = id
+
+	return i
+}
+
+// IdempotencyKey replaces the generated key, e.g. to retry the request made by another service
+func (i *newPaymentServiceImpl) IdempotencyKey(key string) NewPaymentService {
+	i.idempotencyKey = key
+
+	return i
+}
+
 type newPaymentRequest struct {
 	Template PaymentTemplate `json:"template"`
 	User     User            `json:"user"`
@@ -93,12 +185,17 @@ func (i *newPaymentServiceImpl) Do() (Link, error) {
 		return "", fmt.Errorf("AdminClient.NewPayment: invalid link %s", i.link)
 	}
 
+	if i.productID != "" && !i.productID.Validate() {
+		return "", fmt.Errorf("AdminClient.NewPayment: invalid product id %s", i.productID)
+	}
+
 	req := newPaymentRequest{
 		Template: PaymentTemplate{
 			Currency:     i.currency,
 			Amount:       i.amount,
 			Description:  i.description,
 			ResourceLink: i.link,
+			ProductID:    i.productID,
 		},
 		User: User{
 			ID:    "id",
@@ -107,13 +204,13 @@ func (i *newPaymentServiceImpl) Do() (Link, error) {
 		},
 	}
 
-	resp, err := i.api.R().SetBody(&req).Post("/payments")
+	resp, err := i.api.R().SetHeader(HeaderIdempotencyKey, i.idempotencyKey).SetBody(&req).Post("/payments")
 	if err != nil {
 		return "", fmt.Errorf("AdminClient.NewPayment: %w", err)
 	}
 
 	if resp.StatusCode() != http.StatusOK {
-		return "", fmt.Errorf("AdminClient.NewPayment: error response from API %s", resp.String())
+		return "", fmt.Errorf("AdminClient.NewPayment: %w", responseError(resp))
 	}
 
 	return Link(resp.String()), nil
@@ -127,14 +224,95 @@ func (i *newPaymentServiceImpl) String() string {
 }
 
 type AllPaymentService interface {
+	Status(status Status) AllPaymentService
+	Email(email string) AllPaymentService
+	ProductID(id ID) AllPaymentService
+	CreatedFrom(from time.Time) AllPaymentService
+	CreatedTo(to time.Time) AllPaymentService
+	MinAmount(amount uint) AllPaymentService
+	MaxAmount(amount uint) AllPaymentService
+	Sort(sort PaymentSort) AllPaymentService
+	Limit(limit int) AllPaymentService
+	Cursor(cursor string) AllPaymentService
 	Do() (map[ID]Status, error)
+	Page() (PaymentPage, error)
 }
 
 type allPaymentServiceImpl struct {
-	api *resty.Client
+	api    *resty.Client
+	filter PaymentFilter
+}
+
+func (i *allPaymentServiceImpl) Status(status Status) AllPaymentService {
+	i.filter.Status = status
+
+	return i
+}
+
+func (i *allPaymentServiceImpl) Email(email string) AllPaymentService {
+	i.filter.Email = email
+
+	return i
+}
+
+func (i *allPaymentServiceImpl) ProductID(id ID) AllPaymentService {
+	i.filter.ProductID = id
+
+	return i
+}
+
+func (i *allPaymentServiceImpl) CreatedFrom(from time.Time) AllPaymentService {
+	i.filter.CreatedFrom = from
+
+	return i
 }
 
+func (i *allPaymentServiceImpl) CreatedTo(to time.Time) AllPaymentService {
+	i.filter.CreatedTo = to
+
+	return i
+}
+
+func (i *allPaymentServiceImpl) MinAmount(amount uint) AllPaymentService {
+	i.filter.MinAmount = amount
+
+	return i
+}
+
+func (i *allPaymentServiceImpl) MaxAmount(amount uint) AllPaymentService {
+	i.filter.MaxAmount = amount
+
+	return i
+}
+
+func (i *allPaymentServiceImpl) Sort(sort PaymentSort) AllPaymentService {
+	i.filter.Sort = sort
+
+	return i
+}
+
+func (i *allPaymentServiceImpl) Limit(limit int) AllPaymentService {
+	i.filter.Limit = limit
+
+	return i
+}
+
+// Cursor sets NextCursor of the previous page to get the next one
+func (i *allPaymentServiceImpl) Cursor(cursor string) AllPaymentService {
+	i.filter.Cursor = cursor
+
+	return i
+}
+
+// Do returns statuses of all payments matching the filters, limit, cursor and sort are not applied
 func (i *allPaymentServiceImpl) Do() (map[ID]Status, error) {
+	query, err := i.query()
+	if err != nil {
+		return nil, err
+	}
+
+	query.Del("sort")
+
 	var res struct {
 		Statuses []struct {
 			ID     ID     `json:"id"`
@@ -142,13 +320,13 @@ func (i *allPaymentServiceImpl) Do() (map[ID]Status, error) {
 		} `json:"statuses"`
 	}
 
-	resp, err := i.api.R().SetResult(&res).Get("/payments")
+	resp, err := i.api.R().SetQueryParamsFromValues(query).SetResult(&res).Get("/payments")
 	if err != nil {
 		return nil, fmt.Errorf("AdminClient.AllPayment: %w", err)
 	}
 
 	if resp.StatusCode() != http.StatusOK {
-		return nil, fmt.Errorf("AdminClient.AllPayment: error response from API %s", resp.String())
+		return nil, fmt.Errorf("AdminClient.AllPayment: %w", responseError(resp))
 	}
 
 	statuses := make(map[ID]Status)
@@ -160,9 +338,86 @@ func (i *allPaymentServiceImpl) Do() (map[ID]Status, error) {
 	return statuses, nil
 }
 
+// Page returns a page of payments matching the filters, 50 payments are returned if the limit is not set
+func (i *allPaymentServiceImpl) Page() (PaymentPage, error) {
+	query, err := i.query()
+	if err != nil {
+		return PaymentPage{}, err
+	}
+
+	limit := i.filter.Limit
+	if limit <= 0 {
+		limit = defaultPageLimit
+	}
+
+	query.Set("limit", strconv.Itoa(limit))
+
+	if i.filter.Cursor != "" {
+		query.Set("cursor", i.filter.Cursor)
+	}
+
+	var page PaymentPage
+
+	resp, err := i.api.R().SetQueryParamsFromValues(query).SetResult(&page).Get("/payments")
+	if err != nil {
+		return PaymentPage{}, fmt.Errorf("AdminClient.AllPayment: %w", err)
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		return PaymentPage{}, fmt.Errorf("AdminClient.AllPayment: %w", responseError(resp))
+	}
+
+	return page, nil
+}
+
+// query returns filters and sort of the request, the server returns a page only if limit or cursor is set
+func (i *allPaymentServiceImpl) query() (url.Values, error) {
+	if i.filter.Status != "" && !i.filter.Status.Validate() {
+		return nil, fmt.Errorf("AdminClient.AllPayment: invalid status %s", i.filter.Status)
+	}
+
+	if i.filter.Sort != "" && !i.filter.Sort.Validate() {
+		return nil, fmt.Errorf("AdminClient.AllPayment: invalid sort %s", i.filter.Sort)
+	}
+
+	query := url.Values{}
+
+	for param, value := range map[string]string{
+		"status":     string(i.filter.Status),
+		"email":      i.filter.Email,
+		"product_id": string(i.filter.ProductID),
+		"sort":       string(i.filter.Sort),
+	} {
+		if value != "" {
+			query.Set(param, value)
+		}
+	}
+
+	for param, value := range map[string]time.Time{
+		"created_from": i.filter.CreatedFrom,
+		"created_to":   i.filter.CreatedTo,
+	} {
+		if !value.IsZero() {
+			query.Set(param, value.Format(time.RFC3339Nano))
+		}
+	}
+
+	for param, value := range map[string]uint{
+		"min_amount": i.filter.MinAmount,
+		"max_amount": i.filter.MaxAmount,
+	} {
+		if value != 0 {
+			query.Set(param, strconv.FormatUint(uint64(value), 10))
+		}
+	}
+
+	return query, nil
+}
+
 type GetPaymentService interface {
 	ID(id ID) GetPaymentService
 	Do() (Status, error)
+	Details() (PaymentDetails, error)
 }
 
 type getPaymentServiceImpl struct {
@@ -187,8 +442,209 @@ func (i *getPaymentServiceImpl) Do() (Status, error) {
 	}
 
 	if resp.StatusCode() != http.StatusOK {
-		return "", fmt.Errorf("AdminClient.GetPayment: error response from API %s", resp.String())
+		return "", fmt.Errorf("AdminClient.GetPayment: %w", responseError(resp))
 	}
 
 	return Status(resp.String()), nil
 }
+
+// Details returns full payment information instead of the status only
+func (i *getPaymentServiceImpl) Details() (PaymentDetails, error) {
+	if !i.id.Validate() {
+		return PaymentDetails{}, fmt.Errorf("AdminClient.GetPaymentDetails: invalid id %s", i.id)
+	}
+
+	var details PaymentDetails
+
+	resp, err := i.api.R().SetResult(&details).Get(fmt.Sprintf("/payments/%s/details", i.id))
+	if err != nil {
+		return PaymentDetails{}, fmt.Errorf("AdminClient.GetPaymentDetails: %w", err)
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		return PaymentDetails{}, fmt.Errorf("AdminClient.GetPaymentDetails: %w", responseError(resp))
+	}
+
+	return details, nil
+}
+
+type ResendService interface {
+	Email(email string) ResendService
+	Do() (int, error)
+}
+
+type resendServiceImpl struct {
+	api   *resty.Client
+	email string
+}
+
+func (i *resendServiceImpl) Email(email string) ResendService {
+	i.email = email
+
+	return i
+}
+
+// Do sends links to all purchases of the customer to the email and returns the number of purchases
+func (i *resendServiceImpl) Do() (int, error) {
+	if _, err := mail.ParseAddress(i.email); err != nil {
+		return 0, fmt.Errorf("AdminClient.Resend: invalid email %s", i.email)
+	}
+
+	var res struct {
+		Purchases int `json:"purchases"`
+	}
+
+	resp, err := i.api.R().
+		SetBody(map[string]string{"email": i.email}).
+		SetResult(&res).
+		Post("/customers/resend")
+	if err != nil {
+		return 0, fmt.Errorf("AdminClient.Resend: %w", err)
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		return 0, fmt.Errorf("AdminClient.Resend: %w", responseError(resp))
+	}
+
+	return res.Purchases, nil
+}
+
+type AuditService interface {
+	Actor(actor string) AuditService
+	Action(action AuditAction) AuditService
+	Target(target string) AuditService
+	From(from time.Time) AuditService
+	To(to time.Time) AuditService
+	Limit(limit int) AuditService
+	Cursor(cursor string) AuditService
+	Do() (AuditPage, error)
+}
+
+type auditServiceImpl struct {
+	api    *resty.Client
+	filter AuditFilter
+}
+
+func (i *auditServiceImpl) Actor(actor string) AuditService {
+	i.filter.Actor = actor
+
+	return i
+}
+
+func (i *auditServiceImpl) Action(action AuditAction) AuditService {
+	i.filter.Action = action
+
+	return i
+}
+
+func (i *auditServiceImpl) Target(target string) AuditService {
+	i.filter.Target = target
+
+	return i
+}
+
+func (i *auditServiceImpl) From(from time.Time) AuditService {
+	i.filter.From = from
+
+	return i
+}
+
+func (i *auditServiceImpl) To(to time.Time) AuditService {
+	i.filter.To = to
+
+	return i
+}
+
+func (i *auditServiceImpl) Limit(limit int) AuditService {
+	i.filter.Limit = limit
+
+	return i
+}
+
+// Cursor sets NextCursor of the previous page to get the next one
+func (i *auditServiceImpl) Cursor(cursor string) AuditService {
+	i.filter.Cursor = cursor
+
+	return i
+}
+
+func (i *auditServiceImpl) Do() (AuditPage, error) {
+	query := url.Values{}
+
+	for param, value := range map[string]string{
+		"actor":  i.filter.Actor,
+		"action": string(i.filter.Action),
+		"target": i.filter.Target,
+		"cursor": i.filter.Cursor,
+	} {
+		if value != "" {
+			query.Set(param, value)
+		}
+	}
+
+	for param, value := range map[string]time.Time{
+		"from": i.filter.From,
+		"to":   i.filter.To,
+	} {
+		if !value.IsZero() {
+			query.Set(param, value.Format(time.RFC3339Nano))
+		}
+	}
+
+	if i.filter.Limit != 0 {
+		query.Set("limit", strconv.Itoa(i.filter.Limit))
+	}
+
+	var page AuditPage
+
+	resp, err := i.api.R().SetQueryParamsFromValues(query).SetResult(&page).Get("/audit")
+	if err != nil {
+		return AuditPage{}, fmt.Errorf("AdminClient.Audit: %w", err)
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		return AuditPage{}, fmt.Errorf("AdminClient.Audit: %w", responseError(resp))
+	}
+
+	return page, nil
+}
+
+type DenialService interface {
+	Command(command string) DenialService
+	Scope(scope Scope) DenialService
+	Do() error
+}
+
+type denialServiceImpl struct {
+	api     *resty.Client
+	command string
+	scope   Scope
+}
+
+func (i *denialServiceImpl) Command(command string) DenialService {
+	i.command = command
+
+	return i
+}
+
+func (i *denialServiceImpl) Scope(scope Scope) DenialService {
+	i.scope = scope
+
+	return i
+}
+
+// Do records the command denied to the actor of the client in the audit log as AuditAccessDenied
+func (i *denialServiceImpl) Do() error {
+	resp, err := i.api.R().
+		SetBody(map[string]string{"command": i.command, "scope": string(i.scope)}).
+		Post("/audit/denials")
+	if err != nil {
+		return fmt.Errorf("AdminClient.Denial: %w", err)
+	}
+
+	if resp.StatusCode() != http.StatusNoContent {
+		return fmt.Errorf("AdminClient.Denial: %w", responseError(resp))
+	}
+
+	return nil
+}
@@ -0,0 +1,171 @@
+package gopay_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+func TestAdminClient_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		expectErr error
+	}{
+		{
+			name:      "not found",
+			status:    http.StatusNotFound,
+			body:      `{"status":404,"code":"not_found","detail":"get payment details failed: not found"}`,
+			expectErr: gopay.ErrNotFound,
+		},
+		{
+			name:      "invalid transition",
+			status:    http.StatusConflict,
+			body:      `{"status":409,"code":"invalid_transition","detail":"update payment status failed: invalid status transition"}`,
+			expectErr: gopay.ErrInvalidTransition,
+		},
+		{
+			name:      "provider unavailable",
+			status:    http.StatusBadGateway,
+			body:      `{"status":502,"code":"provider_unavailable","detail":"create payment failed: payment provider unavailable"}`,
+			expectErr: gopay.ErrProviderUnavailable,
+		},
+		{
+			name:      "forbidden",
+			status:    http.StatusForbidden,
+			body:      `{"status":403,"code":"forbidden","detail":"API key has no scope payments:read"}`,
+			expectErr: gopay.ErrForbidden,
+		},
+		{
+			name:   "not a problem",
+			status: http.StatusBadGateway,
+			body:   "Bad Gateway",
+		},
+		{
+			name:   "disabled feature",
+			status: http.StatusNotFound,
+			body:   `{"status":404,"code":"disabled","detail":"email delivery is disabled"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			t.Cleanup(srv.Close)
+
+			client, err := gopay.NewAdminClient(srv.URL)
+			require.NoError(t, err)
+
+			_, err = client.NewGetPaymentService().ID(gopay.ID(uuid.NewString())).Details()
+			require.Error(t, err)
+
+			for _, domainErr := range []error{
+				gopay.ErrNotFound,
+				gopay.ErrAlreadyExists,
+				gopay.ErrInvalidTransition,
+				gopay.ErrProviderUnavailable,
+				gopay.ErrForbidden,
+			} {
+				require.Equal(t, domainErr == tt.expectErr, errors.Is(err, domainErr), domainErr)
+			}
+		})
+	}
+}
+
+func TestAdminClient_NewPaymentIdempotencyKey(t *testing.T) {
+	t.Parallel()
+
+	var keys []string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(gopay.HeaderIdempotencyKey))
+
+		_, _ = w.Write([]byte("https://redirect.com/uuid"))
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := gopay.NewAdminClient(srv.URL)
+	require.NoError(t, err)
+
+	// repeated calls of the same service are retries of one request
+	service := client.NewNewPaymentService().ResourceLink("https://resource.com")
+	for range 2 {
+		_, err = service.Do()
+		require.NoError(t, err)
+	}
+
+	_, err = client.NewNewPaymentService().ResourceLink("https://resource.com").Do()
+	require.NoError(t, err)
+
+	require.Len(t, keys, 3)
+	require.NotEmpty(t, keys[0])
+	require.Equal(t, keys[0], keys[1])
+	require.NotEqual(t, keys[0], keys[2])
+}
+
+func TestAdminClient_WithActor(t *testing.T) {
+	t.Parallel()
+
+	var actors []string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actors = append(actors, r.Header.Get(gopay.HeaderActor))
+
+		_, _ = w.Write([]byte(`{"entries":[]}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := gopay.NewAdminClient(srv.URL)
+	require.NoError(t, err)
+
+	_, err = client.WithActor(gopay.ActorTelegram(1)).NewAuditService().Do()
+	require.NoError(t, err)
+
+	// the actor is not shared with the original client
+	_, err = client.NewAuditService().Do()
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"telegram:1", ""}, actors)
+}
+
+func TestAdminClient_Denial(t *testing.T) {
+	t.Parallel()
+
+	var (
+		path, actor string
+		body        map[string]string
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, actor = r.URL.Path, r.Header.Get(gopay.HeaderActor)
+		_ = json.NewDecoder(r.Body).Decode(&body)
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := gopay.NewAdminClient(srv.URL)
+	require.NoError(t, err)
+
+	require.NoError(t, client.WithActor(gopay.ActorTelegram(1)).NewDenialService().
+		Command("/new").Scope(gopay.ScopePaymentsWrite).Do())
+
+	require.Equal(t, "/api/audit/denials", path)
+	require.Equal(t, "telegram:1", actor)
+	require.Equal(t, map[string]string{"command": "/new", "scope": "payments:write"}, body)
+}
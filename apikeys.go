@@ -0,0 +1,232 @@
+package gopay
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// apiKeyPrefix makes keys recognizable, e.g. by secret scanners
+const apiKeyPrefix = "gpk"
+
+var (
+	// ErrInvalidAPIKeyName is returned for empty names of API keys
+	ErrInvalidAPIKeyName = errors.New("invalid API key name")
+	// ErrInvalidScope is returned for unknown roles and scopes and for keys without scopes
+	ErrInvalidScope = errors.New("invalid scope")
+	// ErrForbidden is returned by AdminClient when the API key has no scope required by the route
+	ErrForbidden = errors.New("forbidden")
+)
+
+// Scope allows a group of admin routes, keys get scopes directly or by roles
+type Scope string
+
+const (
+	// ScopePaymentsRead allows to list payments and read their statuses and details
+	ScopePaymentsRead Scope = "payments:read"
+	// ScopePaymentsWrite allows to create payments
+	ScopePaymentsWrite Scope = "payments:write"
+	// ScopePurchasesResend allows to resend purchases to customers
+	ScopePurchasesResend Scope = "purchases:resend"
+	// ScopeRefunds allows to refund succeeded payments
+	ScopeRefunds Scope = "refunds"
+	// ScopeFiles allows to list and upload file versions
+	ScopeFiles Scope = "files"
+	// ScopeTemplates allows to preview notification templates of products
+	ScopeTemplates Scope = "templates"
+	// ScopeAdmin allows everything, including maintenance routes such as backups
+	ScopeAdmin Scope = "admin"
+)
+
+var scopes = []Scope{
+	ScopePaymentsRead, ScopePaymentsWrite, ScopePurchasesResend, ScopeRefunds, ScopeFiles, ScopeTemplates, ScopeAdmin,
+}
+
+// roles are named sets of scopes for typical team members, support staff looks up payments and resends
+// purchases but can not create or refund payments
+var roles = map[string][]Scope{
+	"viewer":  {ScopePaymentsRead},
+	"support": {ScopePaymentsRead, ScopePurchasesResend},
+	"manager": {ScopePaymentsRead, ScopePaymentsWrite, ScopePurchasesResend, ScopeRefunds},
+	"editor":  {ScopePaymentsRead, ScopeFiles, ScopeTemplates},
+	"admin":   {ScopeAdmin},
+}
+
+// ParseScopes returns scopes of the roles and scopes, e.g. "support" and "files",
+// the result is sorted and has no duplicates
+func ParseScopes(values []string) ([]Scope, error) {
+	var parsed []Scope
+
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+
+		if role, ok := roles[value]; ok {
+			parsed = append(parsed, role...)
+
+			continue
+		}
+
+		if !slices.Contains(scopes, Scope(value)) {
+			return nil, fmt.Errorf("%w %q, expected one of roles %s or scopes %s",
+				ErrInvalidScope, value, strings.Join(slices.Sorted(maps.Keys(roles)), ", "), joinScopes(scopes))
+		}
+
+		parsed = append(parsed, Scope(value))
+	}
+
+	if len(parsed) == 0 {
+		return nil, fmt.Errorf("%w: at least one role or scope is required", ErrInvalidScope)
+	}
+
+	slices.Sort(parsed)
+
+	return slices.Compact(parsed), nil
+}
+
+func joinScopes(scopes []Scope) string {
+	values := make([]string, len(scopes))
+	for i, scope := range scopes {
+		values[i] = string(scope)
+	}
+
+	return strings.Join(values, ", ")
+}
+
+// HasScope reports whether the scopes allow the scope, ScopeAdmin allows everything
+func HasScope(scopes []Scope, scope Scope) bool {
+	return slices.Contains(scopes, ScopeAdmin) || slices.Contains(scopes, scope)
+}
+
+// APIKeyStorage keeps API keys by their IDs, GetAPIKey and DeleteAPIKey return an error
+// wrapping ErrNotFound for unknown keys
+type APIKeyStorage interface {
+	SetAPIKey(key APIKey) error
+	GetAPIKey(id string) (APIKey, error)
+	ListAPIKeys() ([]APIKey, error)
+	DeleteAPIKey(id string) error
+}
+
+// APIKey gives access to the admin API, only the hash of its secret is stored,
+// the key itself is shown once when it is created
+type APIKey struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	SecretHash string    `json:"secret_hash,omitempty"`
+	Scopes     []Scope   `json:"scopes"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// HasScope reports whether the key allows the scope
+func (k APIKey) HasScope(scope Scope) bool {
+	return HasScope(k.Scopes, scope)
+}
+
+// APIKeyManager creates API keys and checks keys of the requests
+type APIKeyManager struct {
+	storage APIKeyStorage
+	now     func() time.Time
+}
+
+func NewAPIKeyManager(apiKeyStorage APIKeyStorage) *APIKeyManager {
+	return &APIKeyManager{
+		storage: apiKeyStorage,
+		now:     time.Now,
+	}
+}
+
+// CreateKey saves new key with the name and the roles or scopes, see ParseScopes, and returns it
+// with the key to be given to the client, the key has format gpk_<id>_<secret>
+func (km *APIKeyManager) CreateKey(name string, rolesOrScopes []string) (APIKey, string, error) {
+	if strings.TrimSpace(name) == "" {
+		return APIKey{}, "", ErrInvalidAPIKeyName
+	}
+
+	keyScopes, err := ParseScopes(rolesOrScopes)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+
+	id, err := randomString(8)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+
+	secret, err := randomString(32)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+
+	key := APIKey{
+		ID:         id,
+		Name:       name,
+		SecretHash: secretHash(secret),
+		Scopes:     keyScopes,
+		CreatedAt:  km.now().UTC(),
+	}
+
+	if err = km.storage.SetAPIKey(key); err != nil {
+		return APIKey{}, "", err
+	}
+
+	return key, strings.Join([]string{apiKeyPrefix, id, secret}, "_"), nil
+}
+
+func (km *APIKeyManager) ListKeys() ([]APIKey, error) {
+	return km.storage.ListAPIKeys()
+}
+
+// RevokeKey deletes the key, requests with it are rejected immediately
+func (km *APIKeyManager) RevokeKey(id string) error {
+	return km.storage.DeleteAPIKey(id)
+}
+
+// Authenticate returns the stored key if the key of the request is valid
+func (km *APIKeyManager) Authenticate(key string) (APIKey, bool, error) {
+	parts := strings.Split(key, "_")
+	if len(parts) != 3 || parts[0] != apiKeyPrefix {
+		return APIKey{}, false, nil
+	}
+
+	id, secret := parts[1], parts[2]
+
+	stored, err := km.storage.GetAPIKey(id)
+	if errors.Is(err, ErrNotFound) {
+		return APIKey{}, false, nil
+	}
+
+	if err != nil {
+		return APIKey{}, false, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(secretHash(secret)), []byte(stored.SecretHash)) != 1 {
+		return APIKey{}, false, nil
+	}
+
+	return stored, true, nil
+}
+
+// randomString returns n random bytes encoded with base64url without the underscore,
+// which separates parts of the key
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("gopay.randomString: %w", err)
+	}
+
+	return strings.ReplaceAll(base64.RawURLEncoding.EncodeToString(b), "_", "-"), nil
+}
+
+// secretHash is fast, because secrets are random and long enough to make brute force useless
+func secretHash(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,168 @@
+package gopay_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/Anton-Kraev/gopay"
+	"github.com/Anton-Kraev/gopay/mocks"
+)
+
+func TestAPIKeyManager_CreateKey(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	storage := mocks.NewMockAPIKeyStorage(ctrl)
+	km := gopay.NewAPIKeyManager(storage)
+
+	_, _, err := km.CreateKey(" ", []string{"admin"})
+	require.ErrorIs(t, err, gopay.ErrInvalidAPIKeyName)
+
+	_, _, err = km.CreateKey("bot", nil)
+	require.ErrorIs(t, err, gopay.ErrInvalidScope)
+
+	var saved gopay.APIKey
+
+	storage.EXPECT().SetAPIKey(gomock.Any()).DoAndReturn(func(key gopay.APIKey) error {
+		saved = key
+
+		return nil
+	}).Times(1)
+
+	apiKey, key, err := km.CreateKey("bot", []string{"support"})
+	require.NoError(t, err)
+
+	assert.Equal(t, saved, apiKey)
+	assert.Equal(t, "bot", apiKey.Name)
+	assert.Equal(t, []gopay.Scope{gopay.ScopePaymentsRead, gopay.ScopePurchasesResend}, apiKey.Scopes)
+	assert.True(t, strings.HasPrefix(key, "gpk_"+apiKey.ID+"_"))
+	assert.NotContains(t, apiKey.SecretHash, strings.Split(key, "_")[2], "secret must be stored hashed")
+}
+
+func TestAPIKeyManager_Authenticate(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	storage := mocks.NewMockAPIKeyStorage(ctrl)
+	km := gopay.NewAPIKeyManager(storage)
+
+	var stored gopay.APIKey
+
+	storage.EXPECT().SetAPIKey(gomock.Any()).DoAndReturn(func(key gopay.APIKey) error {
+		stored = key
+
+		return nil
+	}).Times(1)
+
+	_, key, err := km.CreateKey("bot", []string{"admin"})
+	require.NoError(t, err)
+
+	errStorage := errors.New("storage error")
+
+	storage.EXPECT().GetAPIKey(gomock.Any()).DoAndReturn(func(id string) (gopay.APIKey, error) {
+		switch id {
+		case stored.ID:
+			return stored, nil
+		case "broken":
+			return gopay.APIKey{}, errStorage
+		default:
+			return gopay.APIKey{}, fmt.Errorf("API key %w", gopay.ErrNotFound)
+		}
+	}).AnyTimes()
+
+	tests := []struct {
+		name      string
+		key       string
+		expected  bool
+		expectErr error
+	}{
+		{name: "valid", key: key, expected: true},
+		{name: "wrong secret", key: "gpk_" + stored.ID + "_secret"},
+		{name: "unknown id", key: "gpk_unknown_secret"},
+		{name: "bad format", key: stored.ID},
+		{name: "admin token", key: "token"},
+		{name: "storage error", key: "gpk_broken_secret", expectErr: errStorage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			apiKey, ok, err := km.Authenticate(tt.key)
+			require.ErrorIs(t, err, tt.expectErr)
+			assert.Equal(t, tt.expected, ok)
+
+			if tt.expected {
+				assert.Equal(t, stored, apiKey)
+			}
+		})
+	}
+}
+
+func TestParseScopes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		values    []string
+		expected  []gopay.Scope
+		expectErr error
+	}{
+		{
+			name:     "role",
+			values:   []string{"viewer"},
+			expected: []gopay.Scope{gopay.ScopePaymentsRead},
+		},
+		{
+			name:     "roles and scopes without duplicates",
+			values:   []string{"support", " files", "payments:read"},
+			expected: []gopay.Scope{gopay.ScopeFiles, gopay.ScopePaymentsRead, gopay.ScopePurchasesResend},
+		},
+		{
+			name:   "manager role",
+			values: []string{"manager"},
+			expected: []gopay.Scope{
+				gopay.ScopePaymentsRead, gopay.ScopePaymentsWrite, gopay.ScopePurchasesResend, gopay.ScopeRefunds,
+			},
+		},
+		{
+			name:      "unknown scope",
+			values:    []string{"viewer", "refund"},
+			expectErr: gopay.ErrInvalidScope,
+		},
+		{
+			name:      "empty",
+			expectErr: gopay.ErrInvalidScope,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			scopes, err := gopay.ParseScopes(tt.values)
+			require.ErrorIs(t, err, tt.expectErr)
+			assert.Equal(t, tt.expected, scopes)
+		})
+	}
+}
+
+func TestAPIKey_HasScope(t *testing.T) {
+	t.Parallel()
+
+	support := gopay.APIKey{Scopes: []gopay.Scope{gopay.ScopePaymentsRead, gopay.ScopePurchasesResend}}
+	assert.True(t, support.HasScope(gopay.ScopePurchasesResend))
+	assert.False(t, support.HasScope(gopay.ScopePaymentsWrite))
+	assert.False(t, support.HasScope(gopay.ScopeRefunds))
+	assert.False(t, support.HasScope(gopay.ScopeFiles))
+	assert.False(t, support.HasScope(gopay.ScopeAdmin))
+
+	admin := gopay.APIKey{Scopes: []gopay.Scope{gopay.ScopeAdmin}}
+	assert.True(t, admin.HasScope(gopay.ScopeFiles))
+}
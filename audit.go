@@ -0,0 +1,212 @@
+package gopay
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// HeaderActor is sent by clients acting on behalf of people, e.g. the bot sends Telegram IDs of admins,
+// the server can not verify it, so it is recorded only as an annotation of the actor authenticated by the API key
+const HeaderActor = "X-Actor"
+
+// maxActorLength limits actors sent by clients
+const maxActorLength = 64
+
+// AuditAction names a mutating admin action recorded in the audit log
+type AuditAction string
+
+const (
+	AuditPaymentCreate      AuditAction = "payment.create"
+	AuditPaymentRefund      AuditAction = "payment.refund"
+	AuditPurchasesResend    AuditAction = "purchases.resend"
+	AuditFileVersionPublish AuditAction = "file_version.publish"
+	AuditAPIKeyCreate       AuditAction = "api_key.create"
+	AuditAPIKeyRevoke       AuditAction = "api_key.revoke"
+	AuditDatabaseMigrate    AuditAction = "database.migrate"
+	AuditDatabaseRestore    AuditAction = "database.restore"
+	// AuditAccessDenied is recorded when the API key has no scope required by the route
+	// or the client, e.g. the bot, denies the command to the person it acts on behalf of
+	AuditAccessDenied AuditAction = "access.denied"
+)
+
+// ActorAdminToken is the actor of requests with the admin token
+const ActorAdminToken = "admin_token"
+
+func ActorAPIKey(id string) string {
+	return "api_key:" + id
+}
+
+func ActorTelegram(id int64) string {
+	return "telegram:" + strconv.FormatInt(id, 10)
+}
+
+func ActorCLI(user string) string {
+	return "cli:" + user
+}
+
+// ValidateActor checks actors sent by clients in HeaderActor, they must be printable ASCII
+func ValidateActor(actor string) bool {
+	if actor == "" || len(actor) > maxActorLength {
+		return false
+	}
+
+	for _, r := range actor {
+		if r < '!' || r > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Actor is who made the action: the API key, the admin token or the OS user of the CLI
+type Actor struct {
+	Name string `json:"actor"`
+	// OnBehalfOf is the person named by the client in HeaderActor, e.g. the Telegram admin of the bot,
+	// it is not verified by the server
+	OnBehalfOf string `json:"on_behalf_of,omitempty"`
+}
+
+// AuditEntry is a record of the audit log, entries are never changed or deleted
+type AuditEntry struct {
+	// ID is assigned by the storage, IDs of newer entries are greater
+	ID uint64    `json:"id"`
+	At time.Time `json:"at"`
+	Actor
+	Action AuditAction `json:"action"`
+	// Target is the changed entity, e.g. "payment:<id>"
+	Target string          `json:"target,omitempty"`
+	Before json.RawMessage `json:"before,omitempty" swaggertype:"object"`
+	After  json.RawMessage `json:"after,omitempty" swaggertype:"object"`
+}
+
+// AuditFilter selects a page of audit entries, zero fields are not applied
+type AuditFilter struct {
+	// Actor matches both the actor and the person it acted on behalf of
+	Actor  string
+	Action AuditAction
+	Target string
+	From   time.Time // inclusive
+	To     time.Time // exclusive
+	Limit  int
+	// Cursor is NextCursor of the previous page, empty for the first page
+	Cursor string
+}
+
+// Match reports whether entry satisfies all filter conditions
+func (f AuditFilter) Match(entry AuditEntry) bool {
+	switch {
+	case f.Actor != "" && entry.Name != f.Actor && entry.OnBehalfOf != f.Actor:
+		return false
+	case f.Action != "" && entry.Action != f.Action:
+		return false
+	case f.Target != "" && entry.Target != f.Target:
+		return false
+	case !f.From.IsZero() && entry.At.Before(f.From):
+		return false
+	case !f.To.IsZero() && !entry.At.Before(f.To):
+		return false
+	default:
+		return true
+	}
+}
+
+// AuditPage is a page of audit entries, newest entries go first
+type AuditPage struct {
+	Entries []AuditEntry `json:"entries"`
+	// NextCursor is empty for the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// AuditStorage keeps the audit log, it can only be appended to
+type AuditStorage interface {
+	// AppendAuditEntry saves the entry with the next ID and returns it
+	AppendAuditEntry(entry AuditEntry) (AuditEntry, error)
+	// ListAuditEntries returns a page of entries matching the filter, newest entries go first,
+	// it returns an error wrapping ErrInvalidCursor for cursors it has not returned
+	ListAuditEntries(filter AuditFilter) (AuditPage, error)
+}
+
+// AuditTx is implemented by transactions of storages which keep the audit log, entries appended through it
+// are committed together with the other changes of the transaction
+type AuditTx interface {
+	AppendAuditEntry(entry AuditEntry) (AuditEntry, error)
+}
+
+// AuditLog records mutating admin actions
+type AuditLog struct {
+	storage AuditStorage
+	now     func() time.Time
+}
+
+func NewAuditLog(storage AuditStorage) *AuditLog {
+	return &AuditLog{
+		storage: storage,
+		now:     time.Now,
+	}
+}
+
+// Record appends the action to the log, before and after are states of the target encoded to JSON,
+// they are omitted if nil
+func (al *AuditLog) Record(actor Actor, action AuditAction, target string, before, after any) (AuditEntry, error) {
+	entry, err := al.entry(actor, action, target, before, after)
+	if err != nil {
+		return AuditEntry{}, fmt.Errorf("gopay.AuditLog.Record: %w", err)
+	}
+
+	return al.storage.AppendAuditEntry(entry)
+}
+
+// RecordTx appends the action like Record, but in the transaction of the change
+func (al *AuditLog) RecordTx(
+	tx AuditTx, actor Actor, action AuditAction, target string, before, after any,
+) (AuditEntry, error) {
+	entry, err := al.entry(actor, action, target, before, after)
+	if err != nil {
+		return AuditEntry{}, fmt.Errorf("gopay.AuditLog.RecordTx: %w", err)
+	}
+
+	return tx.AppendAuditEntry(entry)
+}
+
+func (al *AuditLog) entry(actor Actor, action AuditAction, target string, before, after any) (AuditEntry, error) {
+	entry := AuditEntry{
+		At:     al.now().UTC(),
+		Actor:  actor,
+		Action: action,
+		Target: target,
+	}
+
+	var err error
+
+	if entry.Before, err = marshalState(before); err != nil {
+		return AuditEntry{}, err
+	}
+
+	if entry.After, err = marshalState(after); err != nil {
+		return AuditEntry{}, err
+	}
+
+	return entry, nil
+}
+
+// List returns a page of entries matching the filter, newest entries go first
+func (al *AuditLog) List(filter AuditFilter) (AuditPage, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultPageLimit
+	}
+
+	filter.Limit = min(filter.Limit, maxPageLimit)
+
+	return al.storage.ListAuditEntries(filter)
+}
+
+func marshalState(state any) (json.RawMessage, error) {
+	if state == nil {
+		return nil, nil
+	}
+
+	return json.Marshal(state)
+}
@@ -0,0 +1,160 @@
+package gopay_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/Anton-Kraev/gopay"
+	"github.com/Anton-Kraev/gopay/mocks"
+)
+
+func TestAuditLog_Record(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	storage := mocks.NewMockAuditStorage(ctrl)
+	al := gopay.NewAuditLog(storage)
+
+	actor := gopay.Actor{Name: gopay.ActorAPIKey("bot"), OnBehalfOf: gopay.ActorTelegram(1)}
+
+	storage.EXPECT().AppendAuditEntry(gomock.Any()).DoAndReturn(func(entry gopay.AuditEntry) (gopay.AuditEntry, error) {
+		entry.ID = 1
+
+		return entry, nil
+	}).Times(1)
+
+	entry, err := al.Record(actor, gopay.AuditPaymentCreate, "payment:uuid", nil, gopay.Payment{Amount: 100})
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(1), entry.ID)
+	assert.Equal(t, actor, entry.Actor)
+	assert.False(t, entry.At.IsZero())
+	assert.Nil(t, entry.Before)
+
+	var after gopay.Payment
+	require.NoError(t, json.Unmarshal(entry.After, &after))
+	assert.Equal(t, uint(100), after.Amount)
+}
+
+func TestAuditFilter_Match(t *testing.T) {
+	t.Parallel()
+
+	entry := gopay.AuditEntry{
+		At:     testNow,
+		Actor:  gopay.Actor{Name: "api_key:bot", OnBehalfOf: "telegram:1"},
+		Action: gopay.AuditPaymentCreate,
+		Target: "payment:uuid",
+	}
+
+	tests := []struct {
+		name     string
+		filter   gopay.AuditFilter
+		expected bool
+	}{
+		{name: "empty", expected: true},
+		{name: "actor", filter: gopay.AuditFilter{Actor: "api_key:bot"}, expected: true},
+		{name: "on behalf of", filter: gopay.AuditFilter{Actor: "telegram:1"}, expected: true},
+		{name: "other actor", filter: gopay.AuditFilter{Actor: "telegram:2"}},
+		{name: "other action", filter: gopay.AuditFilter{Action: gopay.AuditPurchasesResend}},
+		{name: "target", filter: gopay.AuditFilter{Target: "payment:uuid"}, expected: true},
+		{name: "from", filter: gopay.AuditFilter{From: testNow}, expected: true},
+		{name: "to", filter: gopay.AuditFilter{To: testNow}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.expected, tt.filter.Match(entry))
+		})
+	}
+}
+
+// auditPaymentTx is the transaction of the storage which keeps the audit log
+type auditPaymentTx struct {
+	*mocks.MockPaymentTx
+	audit *mocks.MockAuditStorage
+}
+
+func (tx auditPaymentTx) AppendAuditEntry(entry gopay.AuditEntry) (gopay.AuditEntry, error) {
+	return tx.audit.AppendAuditEntry(entry)
+}
+
+func TestPaymentManager_As(t *testing.T) {
+	t.Parallel()
+
+	actor := gopay.Actor{Name: gopay.ActorAPIKey("bot"), OnBehalfOf: gopay.ActorTelegram(1)}
+	paid := gopay.Payment{Status: gopay.StatusSucceeded, PaymentLink: "payment.link", ProviderID: "provider"}
+
+	// expectEntry expects the refund recorded by the actor with states before and after it
+	expectEntry := func(storage *mocks.MockAuditStorage) {
+		storage.EXPECT().AppendAuditEntry(gomock.Any()).
+			DoAndReturn(func(entry gopay.AuditEntry) (gopay.AuditEntry, error) {
+				assert.Equal(t, actor, entry.Actor)
+				assert.Equal(t, gopay.AuditPaymentRefund, entry.Action)
+				assert.Equal(t, "payment:1", entry.Target)
+				assert.Contains(t, string(entry.Before), `"status":"succeeded"`)
+				assert.Contains(t, string(entry.After), `"status":"refunded"`)
+
+				return entry, nil
+			}).Times(1)
+	}
+
+	tests := []struct {
+		name string
+		// inTx makes the storage transaction keep the audit log
+		inTx      bool
+		actor     gopay.Actor
+		txEntries int
+		entries   int
+	}{
+		{name: "in transaction", inTx: true, actor: actor, txEntries: 1},
+		{name: "after transaction", actor: actor, entries: 1},
+		{name: "without actor", inTx: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			mf, _ := setupMocks(ctrl)
+			storage := mocks.NewMockAuditStorage(ctrl)
+			txStorage := mocks.NewMockAuditStorage(ctrl)
+
+			pm := gopay.NewPaymentManager(mf.mockLinks, mf.mockStorage, mf.mockPayments,
+				gopay.WithClock(func() time.Time { return testNow }),
+				gopay.WithAuditLog(gopay.NewAuditLog(storage)),
+			)
+
+			var tx gopay.PaymentTx = mf.mockTx
+			if tt.inTx {
+				tx = auditPaymentTx{MockPaymentTx: mf.mockTx, audit: txStorage}
+			}
+
+			mf.mockStorage.EXPECT().Get(gopay.ID("1")).Return(paid, nil).Times(2)
+			mf.mockPayments.EXPECT().RefundPayment(paid, "refund-1").Return(nil).Times(1)
+			mf.mockStorage.EXPECT().Update(gomock.Any()).
+				DoAndReturn(func(fn func(tx gopay.PaymentTx) error) error { return fn(tx) }).Times(1)
+			mf.mockTx.EXPECT().Get(gopay.ID("1")).Return(paid, nil).Times(1)
+			mf.mockTx.EXPECT().SetLink(gopay.ID("1"), gopay.Link("payment.link")).Return(nil).Times(1)
+			mf.mockTx.EXPECT().Set(gopay.ID("1"), gomock.Any()).Return(nil).Times(1)
+
+			if tt.txEntries != 0 {
+				expectEntry(txStorage)
+			}
+
+			if tt.entries != 0 {
+				expectEntry(storage)
+			}
+
+			_, err := pm.As(tt.actor).RefundPayment("1", "request")
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package gopay
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+)
+
+type (
+	backupStorage interface {
+		// Backup writes consistent snapshot of the whole database, returns the number of written bytes
+		Backup(w io.Writer) (int64, error)
+	}
+
+	backupUploader interface {
+		PutBackup(ctx context.Context, name string, r io.Reader) error
+	}
+)
+
+type BackupConfig struct {
+	// Compress enables gzip compression of backups uploaded on schedule
+	Compress bool
+}
+
+// BackupManager makes database snapshots while the service is running
+type BackupManager struct {
+	storage  backupStorage
+	uploader backupUploader
+	config   BackupConfig
+	now      func() time.Time
+}
+
+func NewBackupManager(backupStorage backupStorage, backupUploader backupUploader, config BackupConfig) *BackupManager {
+	return &BackupManager{
+		storage:  backupStorage,
+		uploader: backupUploader,
+		config:   config,
+		now:      time.Now,
+	}
+}
+
+// BackupName returns file name for the backup made at the moment
+func BackupName(at time.Time, compress bool) string {
+	name := "gopay-" + at.UTC().Format("20060102T150405Z") + ".db"
+	if compress {
+		name += ".gz"
+	}
+
+	return name
+}
+
+// WriteBackup writes snapshot of the database to w, the snapshot is compressed with gzip if compress is set
+func (bm *BackupManager) WriteBackup(w io.Writer, compress bool) error {
+	if !compress {
+		_, err := bm.storage.Backup(w)
+
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+
+	if _, err := bm.storage.Backup(gz); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
+
+// UploadBackup streams snapshot of the database to the file storage, returns the name of uploaded backup
+func (bm *BackupManager) UploadBackup(ctx context.Context) (string, error) {
+	name := BackupName(bm.now(), bm.config.Compress)
+
+	pr, pw := io.Pipe()
+
+	go func() {
+		pw.CloseWithError(bm.WriteBackup(pw, bm.config.Compress))
+	}()
+
+	if err := bm.uploader.PutBackup(ctx, name, pr); err != nil {
+		// unblocks the snapshot writer if upload stopped reading
+		pr.CloseWithError(err)
+
+		return "", err
+	}
+
+	return name, nil
+}
+
+// Start uploads backups with the interval until ctx is done
+func (bm *BackupManager) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			name, err := bm.UploadBackup(ctx)
+			if err != nil {
+				slog.Default().Error(fmt.Errorf("gopay.BackupManager.Start: %w", err).Error())
+
+				continue
+			}
+
+			slog.Default().Info("database backup uploaded", slog.String("name", name))
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package gopay_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/Anton-Kraev/gopay"
+	"github.com/Anton-Kraev/gopay/mocks"
+)
+
+type backupMockFields struct {
+	mockStorage  *mocks.MockbackupStorage
+	mockUploader *mocks.MockbackupUploader
+}
+
+func setupBackupMocks(ctrl *gomock.Controller, config gopay.BackupConfig) (backupMockFields, *gopay.BackupManager) {
+	mf := backupMockFields{
+		mockStorage:  mocks.NewMockbackupStorage(ctrl),
+		mockUploader: mocks.NewMockbackupUploader(ctrl),
+	}
+
+	return mf, gopay.NewBackupManager(mf.mockStorage, mf.mockUploader, config)
+}
+
+func writeSnapshot(w io.Writer) (int64, error) {
+	n, err := w.Write([]byte("snapshot"))
+
+	return int64(n), err
+}
+
+func gunzip(t *testing.T, data []byte) string {
+	t.Helper()
+
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	plain, err := io.ReadAll(gz)
+	require.NoError(t, err)
+
+	return string(plain)
+}
+
+func TestBackupManager_WriteBackup(t *testing.T) {
+	t.Parallel()
+
+	errBackup := errors.New("backup failed")
+
+	tests := []struct {
+		name     string
+		compress bool
+		snapshot func(w io.Writer) (int64, error)
+		expected error
+	}{
+		{
+			name:     "plain",
+			snapshot: writeSnapshot,
+		},
+		{
+			name:     "compressed",
+			compress: true,
+			snapshot: writeSnapshot,
+		},
+		{
+			name:     "storage error",
+			compress: true,
+			snapshot: func(io.Writer) (int64, error) { return 0, errBackup },
+			expected: errBackup,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mf, bm := setupBackupMocks(ctrl, gopay.BackupConfig{})
+			mf.mockStorage.EXPECT().Backup(gomock.Any()).DoAndReturn(tt.snapshot).Times(1)
+
+			var buf bytes.Buffer
+
+			err := bm.WriteBackup(&buf, tt.compress)
+			if tt.expected != nil {
+				require.ErrorIs(t, err, tt.expected)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			if tt.compress {
+				assert.Equal(t, "snapshot", gunzip(t, buf.Bytes()))
+			} else {
+				assert.Equal(t, "snapshot", buf.String())
+			}
+		})
+	}
+}
+
+func TestBackupManager_UploadBackup(t *testing.T) {
+	t.Parallel()
+
+	errUpload := errors.New("upload failed")
+
+	tests := []struct {
+		name     string
+		upload   func(ctx context.Context, name string, r io.Reader) error
+		expected error
+	}{
+		{
+			name: "success",
+			upload: func(_ context.Context, name string, r io.Reader) error {
+				data, err := io.ReadAll(r)
+				if err != nil {
+					return err
+				}
+
+				if !strings.HasPrefix(name, "gopay-") || !strings.HasSuffix(name, ".db.gz") {
+					return errors.New("unexpected name " + name)
+				}
+
+				if gunzip(t, data) != "snapshot" {
+					return errors.New("unexpected data")
+				}
+
+				return nil
+			},
+		},
+		{
+			name: "upload error before reading snapshot",
+			upload: func(context.Context, string, io.Reader) error {
+				return errUpload
+			},
+			expected: errUpload,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mf, bm := setupBackupMocks(ctrl, gopay.BackupConfig{Compress: true})
+
+			done := make(chan struct{})
+
+			mf.mockStorage.EXPECT().Backup(gomock.Any()).DoAndReturn(func(w io.Writer) (int64, error) {
+				defer close(done)
+
+				return writeSnapshot(w)
+			}).Times(1)
+			mf.mockUploader.EXPECT().PutBackup(gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(tt.upload).Times(1)
+
+			name, err := bm.UploadBackup(context.Background())
+
+			// snapshot writer must finish even if upload failed
+			<-done
+
+			if tt.expected != nil {
+				require.ErrorIs(t, err, tt.expected)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.True(t, strings.HasSuffix(name, ".db.gz"))
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/Anton-Kraev/gopay/internal/cmd/admin"
+)
+
+func main() {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	cmd := admin.NewAdminCmd()
+	if err := cmd.Run(ctx, os.Args); err != nil {
+		log.Fatalln(err)
+	}
+}
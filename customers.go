@@ -0,0 +1,208 @@
+package gopay
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	tokenPurposeLogin   = "login"
+	tokenPurposeSession = "session"
+)
+
+type (
+	// CustomerStorage finds payments by the buyer email ignoring case and remembers used one-time tokens,
+	// MarkTokenUsed returns false if the token is already used and not expired
+	CustomerStorage interface {
+		GetPaymentsByEmail(email string) (map[ID]Payment, error)
+		MarkTokenUsed(token string, expiresAt time.Time) (bool, error)
+	}
+
+	customerLinker interface {
+		Link(id ID) Link
+		LoginLink(token string) Link
+	}
+
+	customerNotifier interface {
+		NotifyPurchases(user User, purchases []Purchase) error
+		NotifyLoginLink(user User, link Link) error
+	}
+)
+
+type CustomerConfig struct {
+	// Cooldown limits how often emails can be requested by customers for the same address
+	Cooldown time.Duration
+	// LoginTTL is the lifetime of magic link for login into the customer library
+	LoginTTL time.Duration
+	// SessionTTL is the lifetime of the customer library session
+	SessionTTL time.Duration
+}
+
+// CustomerManager gives customers access to everything they bought
+type CustomerManager struct {
+	storage  CustomerStorage
+	signer   tokenSigner
+	links    customerLinker
+	notifier customerNotifier
+	config   CustomerConfig
+
+	mu       sync.Mutex
+	lastSent map[string]time.Time
+}
+
+func NewCustomerManager(
+	customerStorage CustomerStorage,
+	tokenSigner tokenSigner,
+	customerLinker customerLinker,
+	customerNotifier customerNotifier,
+	config CustomerConfig,
+) *CustomerManager {
+	return &CustomerManager{
+		storage:  customerStorage,
+		signer:   tokenSigner,
+		links:    customerLinker,
+		notifier: customerNotifier,
+		config:   config,
+		lastSent: make(map[string]time.Time),
+	}
+}
+
+// GetPayments returns all payments of the customer ordered by creation time
+func (cm *CustomerManager) GetPayments(email string) ([]Purchase, error) {
+	return cm.purchases(email, func(Payment) bool { return true })
+}
+
+// GetPurchases returns succeeded payments of the customer ordered by creation time
+func (cm *CustomerManager) GetPurchases(email string) ([]Purchase, error) {
+	return cm.purchases(email, func(p Payment) bool { return p.Status == StatusSucceeded })
+}
+
+func (cm *CustomerManager) purchases(email string, match func(Payment) bool) ([]Purchase, error) {
+	payments, err := cm.storage.GetPaymentsByEmail(strings.ToLower(email))
+	if err != nil {
+		return nil, err
+	}
+
+	purchases := make([]Purchase, 0, len(payments))
+
+	for id, payment := range payments {
+		if !match(payment) {
+			continue
+		}
+
+		purchases = append(purchases, Purchase{
+			ID:      id,
+			Payment: payment,
+			Link:    cm.links.Link(id),
+		})
+	}
+
+	slices.SortFunc(purchases, func(a, b Purchase) int {
+		return a.Payment.CreatedAt.Compare(b.Payment.CreatedAt)
+	})
+
+	return purchases, nil
+}
+
+// ResendPurchases sends links to all purchases of the customer by email, returns the number of purchases,
+// nothing is sent if the customer has no purchases
+func (cm *CustomerManager) ResendPurchases(email string) (int, error) {
+	purchases, err := cm.GetPurchases(email)
+	if err != nil || len(purchases) == 0 {
+		return 0, err
+	}
+
+	// the latest known customer data is used for the greeting
+	user := purchases[len(purchases)-1].Payment.User
+
+	if err = cm.notifier.NotifyPurchases(user, purchases); err != nil {
+		return 0, err
+	}
+
+	return len(purchases), nil
+}
+
+// RecoverPurchases is ResendPurchases requested by the customer, repeated requests
+// for the same email address within cooldown are silently ignored
+func (cm *CustomerManager) RecoverPurchases(email string) (int, error) {
+	if !cm.allow("recover", email) {
+		return 0, nil
+	}
+
+	return cm.ResendPurchases(email)
+}
+
+// RequestLogin sends magic link for login into the customer library, the link is sent only
+// if the customer has at least one payment, repeated requests within cooldown are silently ignored
+func (cm *CustomerManager) RequestLogin(email string) error {
+	email = strings.ToLower(email)
+
+	if !cm.allow("login", email) {
+		return nil
+	}
+
+	payments, err := cm.GetPayments(email)
+	if err != nil || len(payments) == 0 {
+		return err
+	}
+
+	token, err := cm.signer.Sign(tokenPurposeLogin, email, cm.config.LoginTTL)
+	if err != nil {
+		return err
+	}
+
+	return cm.notifier.NotifyLoginLink(payments[len(payments)-1].Payment.User, cm.links.LoginLink(token))
+}
+
+// Login exchanges magic link token for the session token, every magic link can be used only once
+func (cm *CustomerManager) Login(token string) (string, error) {
+	email, err := cm.signer.Verify(tokenPurposeLogin, token)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256([]byte(token))
+
+	marked, err := cm.storage.MarkTokenUsed(hex.EncodeToString(hash[:]), time.Now().Add(cm.config.LoginTTL))
+	if err != nil {
+		return "", err
+	}
+
+	if !marked {
+		return "", ErrInvalidToken
+	}
+
+	return cm.signer.Sign(tokenPurposeSession, email, cm.config.SessionTTL)
+}
+
+// Authenticate returns customer email for the session token
+func (cm *CustomerManager) Authenticate(session string) (string, error) {
+	return cm.signer.Verify(tokenPurposeSession, session)
+}
+
+func (cm *CustomerManager) allow(action, email string) bool {
+	key := action + ":" + strings.ToLower(email)
+	now := time.Now()
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if now.Sub(cm.lastSent[key]) < cm.config.Cooldown {
+		return false
+	}
+
+	cm.lastSent[key] = now
+
+	// forget expired records to keep memory bounded
+	for k, sent := range cm.lastSent {
+		if now.Sub(sent) >= cm.config.Cooldown {
+			delete(cm.lastSent, k)
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,203 @@
+package gopay_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/Anton-Kraev/gopay"
+	"github.com/Anton-Kraev/gopay/mocks"
+)
+
+type customerMockFields struct {
+	mockStorage  *mocks.MockCustomerStorage
+	mockSigner   *mocks.MocktokenSigner
+	mockLinks    *mocks.MockcustomerLinker
+	mockNotifier *mocks.MockcustomerNotifier
+}
+
+func setupCustomerMocks(ctrl *gomock.Controller) (customerMockFields, *gopay.CustomerManager) {
+	mf := customerMockFields{
+		mockStorage:  mocks.NewMockCustomerStorage(ctrl),
+		mockSigner:   mocks.NewMocktokenSigner(ctrl),
+		mockLinks:    mocks.NewMockcustomerLinker(ctrl),
+		mockNotifier: mocks.NewMockcustomerNotifier(ctrl),
+	}
+
+	cm := gopay.NewCustomerManager(mf.mockStorage, mf.mockSigner, mf.mockLinks, mf.mockNotifier, gopay.CustomerConfig{
+		Cooldown:   time.Hour,
+		LoginTTL:   15 * time.Minute,
+		SessionTTL: 24 * time.Hour,
+	})
+
+	return mf, cm
+}
+
+func TestCustomerManager_ResendPurchases(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no purchases", func(t *testing.T) {
+		t.Parallel()
+
+		ctrl := gomock.NewController(t)
+		mf, cm := setupCustomerMocks(ctrl)
+
+		mf.mockStorage.EXPECT().GetPaymentsByEmail("buyer@mail.com").Return(map[gopay.ID]gopay.Payment{
+			"pending": {Status: gopay.StatusPending},
+		}, nil).Times(1)
+
+		sent, err := cm.ResendPurchases("Buyer@Mail.com")
+
+		require.NoError(t, err)
+		assert.Equal(t, 0, sent)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		ctrl := gomock.NewController(t)
+		mf, cm := setupCustomerMocks(ctrl)
+
+		now := time.Now()
+		oldUser := gopay.User{ID: "1", Name: "old name", Email: "buyer@mail.com"}
+		newUser := gopay.User{ID: "1", Name: "new name", Email: "buyer@mail.com"}
+
+		mf.mockStorage.EXPECT().GetPaymentsByEmail("buyer@mail.com").Return(map[gopay.ID]gopay.Payment{
+			"new":     {User: newUser, Status: gopay.StatusSucceeded, CreatedAt: now},
+			"old":     {User: oldUser, Status: gopay.StatusSucceeded, CreatedAt: now.Add(-time.Hour)},
+			"pending": {User: newUser, Status: gopay.StatusPending, CreatedAt: now},
+		}, nil).Times(1)
+		mf.mockLinks.EXPECT().Link(gopay.ID("new")).Return(gopay.Link("https://redirect.com/new")).Times(1)
+		mf.mockLinks.EXPECT().Link(gopay.ID("old")).Return(gopay.Link("https://redirect.com/old")).Times(1)
+		mf.mockNotifier.EXPECT().NotifyPurchases(newUser, gomock.Any()).
+			DoAndReturn(func(_ gopay.User, purchases []gopay.Purchase) error {
+				assert.Equal(t, gopay.ID("old"), purchases[0].ID)
+				assert.Equal(t, gopay.ID("new"), purchases[1].ID)
+
+				return nil
+			}).Times(1)
+
+		sent, err := cm.ResendPurchases("buyer@mail.com")
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, sent)
+	})
+}
+
+func TestCustomerManager_RecoverPurchases(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	mf, cm := setupCustomerMocks(ctrl)
+
+	mf.mockStorage.EXPECT().GetPaymentsByEmail("buyer@mail.com").Return(map[gopay.ID]gopay.Payment{
+		"1": {Status: gopay.StatusSucceeded},
+	}, nil).Times(1)
+	mf.mockLinks.EXPECT().Link(gopay.ID("1")).Return(gopay.Link("https://redirect.com/1")).Times(1)
+	mf.mockNotifier.EXPECT().NotifyPurchases(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+	sent, err := cm.RecoverPurchases("buyer@mail.com")
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+
+	// repeated request within cooldown is ignored
+	sent, err = cm.RecoverPurchases("BUYER@mail.com")
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+}
+
+func TestCustomerManager_RequestLogin(t *testing.T) {
+	t.Parallel()
+
+	t.Run("unknown customer", func(t *testing.T) {
+		t.Parallel()
+
+		ctrl := gomock.NewController(t)
+		mf, cm := setupCustomerMocks(ctrl)
+
+		mf.mockStorage.EXPECT().GetPaymentsByEmail("buyer@mail.com").Return(map[gopay.ID]gopay.Payment{}, nil).Times(1)
+
+		require.NoError(t, cm.RequestLogin("buyer@mail.com"))
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		ctrl := gomock.NewController(t)
+		mf, cm := setupCustomerMocks(ctrl)
+
+		user := gopay.User{ID: "1", Email: "Buyer@Mail.com"}
+
+		mf.mockStorage.EXPECT().GetPaymentsByEmail("buyer@mail.com").Return(map[gopay.ID]gopay.Payment{
+			"1": {User: user, Status: gopay.StatusPending},
+		}, nil).Times(1)
+		mf.mockLinks.EXPECT().Link(gopay.ID("1")).Return(gopay.Link("https://redirect.com/1")).Times(1)
+		mf.mockSigner.EXPECT().Sign("login", "buyer@mail.com", 15*time.Minute).Return("token", nil).Times(1)
+		mf.mockLinks.EXPECT().LoginLink("token").Return(gopay.Link("https://gopay.com/login")).Times(1)
+		mf.mockNotifier.EXPECT().NotifyLoginLink(user, gopay.Link("https://gopay.com/login")).Return(nil).Times(1)
+
+		require.NoError(t, cm.RequestLogin("Buyer@Mail.com"))
+
+		// repeated request within cooldown is ignored
+		require.NoError(t, cm.RequestLogin("buyer@mail.com"))
+	})
+}
+
+func TestCustomerManager_Login(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		setup     func(mf customerMockFields)
+		expectErr error
+	}{
+		{
+			name: "invalid token",
+			setup: func(mf customerMockFields) {
+				mf.mockSigner.EXPECT().Verify("login", "token").Return("", gopay.ErrInvalidToken).Times(1)
+			},
+			expectErr: gopay.ErrInvalidToken,
+		},
+		{
+			name: "token already used",
+			setup: func(mf customerMockFields) {
+				mf.mockSigner.EXPECT().Verify("login", "token").Return("buyer@mail.com", nil).Times(1)
+				mf.mockStorage.EXPECT().MarkTokenUsed(gomock.Any(), gomock.Any()).Return(false, nil).Times(1)
+			},
+			expectErr: gopay.ErrInvalidToken,
+		},
+		{
+			name: "success",
+			setup: func(mf customerMockFields) {
+				mf.mockSigner.EXPECT().Verify("login", "token").Return("buyer@mail.com", nil).Times(1)
+				mf.mockStorage.EXPECT().MarkTokenUsed(gomock.Any(), gomock.Any()).Return(true, nil).Times(1)
+				mf.mockSigner.EXPECT().Sign("session", "buyer@mail.com", 24*time.Hour).Return("session", nil).Times(1)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			mf, cm := setupCustomerMocks(ctrl)
+
+			tt.setup(mf)
+
+			session, err := cm.Login("token")
+
+			if tt.expectErr != nil {
+				require.ErrorIs(t, err, tt.expectErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, "session", session)
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package gopay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrFileNotFound       = fmt.Errorf("file %w", ErrNotFound)
+	ErrUnknownFileVersion = fmt.Errorf("file version %w", ErrNotFound)
+	ErrNoRecipient        = errors.New("no recipient address for notification")
+)
+
+type (
+	fileStorage interface {
+		GetData(ctx context.Context, id ID, version uint) ([]byte, error)
+		PutData(ctx context.Context, id ID, version uint, data []byte) error
+		Exists(ctx context.Context, id ID, version uint) (bool, error)
+	}
+
+	// FileVersionStorage keeps version history of the sold files, AddFileVersions fails if the first added
+	// version is not greater than the latest stored one
+	FileVersionStorage interface {
+		GetFileVersions(id ID) ([]FileVersion, error)
+		AddFileVersions(id ID, versions ...FileVersion) error
+		GetProductPayments(productID ID) (map[ID]Payment, error)
+	}
+
+	paymentLinker interface {
+		Link(id ID) Link
+	}
+
+	notifier interface {
+		NotifyFileUpdate(ctx context.Context, user User, update FileUpdate) error
+	}
+)
+
+// Notifiers is a set of configured notification channels
+type Notifiers map[NotifyChannel]notifier
+
+type FileManager struct {
+	files     fileStorage
+	versions  FileVersionStorage
+	links     paymentLinker
+	notifiers Notifiers
+}
+
+func NewFileManager(
+	fileStorage fileStorage,
+	fileVersionStorage FileVersionStorage,
+	paymentLinker paymentLinker,
+	notifiers Notifiers,
+) *FileManager {
+	return &FileManager{
+		files:     fileStorage,
+		versions:  fileVersionStorage,
+		links:     paymentLinker,
+		notifiers: notifiers,
+	}
+}
+
+// GetFile returns file content of the given version, zero version means the latest one
+func (fm *FileManager) GetFile(ctx context.Context, id ID, version uint) ([]byte, error) {
+	versions, err := fm.versions.GetFileVersions(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// files uploaded before versioning have a single untracked version
+	if len(versions) == 0 {
+		versions = []FileVersion{{Version: 1}}
+	}
+
+	if version == 0 {
+		version = versions[len(versions)-1].Version
+	}
+
+	if version > versions[len(versions)-1].Version {
+		return nil, ErrUnknownFileVersion
+	}
+
+	return fm.files.GetData(ctx, id, version)
+}
+
+func (fm *FileManager) GetFileVersions(ctx context.Context, id ID) ([]FileVersion, error) {
+	versions, err := fm.versions.GetFileVersions(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(versions) != 0 {
+		return versions, nil
+	}
+
+	exists, err := fm.files.Exists(ctx, id, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, ErrFileNotFound
+	}
+
+	return []FileVersion{{Version: 1}}, nil
+}
+
+// PublishVersion uploads new version of the file and makes it the latest one, the version is added before
+// the upload, so concurrent publishes get different versions and the loser fails with ErrAlreadyExists
+// without overwriting the uploaded file, the version stays without data if the upload fails
+func (fm *FileManager) PublishVersion(ctx context.Context, id ID, data []byte, comment string) (FileVersion, error) {
+	versions, err := fm.versions.GetFileVersions(id)
+	if err != nil {
+		return FileVersion{}, err
+	}
+
+	var newVersions []FileVersion
+
+	if len(versions) == 0 {
+		exists, err := fm.files.Exists(ctx, id, 1)
+		if err != nil {
+			return FileVersion{}, err
+		}
+
+		// keep track of the file uploaded before versioning
+		if exists {
+			versions = []FileVersion{{Version: 1}}
+			newVersions = append(newVersions, versions[0])
+		}
+	}
+
+	version := FileVersion{
+		Version:    1,
+		Comment:    comment,
+		UploadedAt: time.Now().UTC(),
+	}
+
+	if len(versions) != 0 {
+		version.Version = versions[len(versions)-1].Version + 1
+	}
+
+	if err = fm.versions.AddFileVersions(id, append(newVersions, version)...); err != nil {
+		return FileVersion{}, err
+	}
+
+	if err = fm.files.PutData(ctx, id, version.Version, data); err != nil {
+		return FileVersion{}, err
+	}
+
+	return version, nil
+}
+
+// NotifyBuyers sends notifications about the file version to everyone who has succeeded payment for the product,
+// returns the number of sent notifications
+func (fm *FileManager) NotifyBuyers(
+	ctx context.Context, id ID, version FileVersion, channels []NotifyChannel,
+) (int, error) {
+	for _, channel := range channels {
+		if _, ok := fm.notifiers[channel]; !ok {
+			return 0, fmt.Errorf("notify channel %s is not configured", channel)
+		}
+	}
+
+	payments, err := fm.versions.GetProductPayments(id)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		sent     int
+		errs     []error
+		notified = make(map[string]struct{})
+	)
+
+	for paymentID, payment := range payments {
+		if payment.Status != StatusSucceeded {
+			continue
+		}
+
+		// buyer with several payments for the product is notified once
+		if _, ok := notified[strings.ToLower(payment.User.Email)]; ok {
+			continue
+		}
+
+		notified[strings.ToLower(payment.User.Email)] = struct{}{}
+
+		update := FileUpdate{
+			ProductID: id,
+			Version:   version.Version,
+			Comment:   version.Comment,
+			Link:      fm.links.Link(paymentID),
+		}
+
+		for _, channel := range channels {
+			err = fm.notifiers[channel].NotifyFileUpdate(ctx, payment.User, update)
+			if errors.Is(err, ErrNoRecipient) {
+				continue
+			}
+
+			if err != nil {
+				errs = append(errs, fmt.Errorf("payment %s: %w", paymentID, err))
+
+				continue
+			}
+
+			sent++
+		}
+	}
+
+	return sent, errors.Join(errs...)
+}
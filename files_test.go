@@ -0,0 +1,246 @@
+package gopay_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/Anton-Kraev/gopay"
+	"github.com/Anton-Kraev/gopay/mocks"
+)
+
+type fileMockFields struct {
+	mockFiles    *mocks.MockfileStorage
+	mockVersions *mocks.MockFileVersionStorage
+	mockLinks    *mocks.MockpaymentLinker
+	mockEmail    *mocks.Mocknotifier
+}
+
+func setupFileMocks(ctrl *gomock.Controller) (fileMockFields, *gopay.FileManager) {
+	mf := fileMockFields{
+		mockFiles:    mocks.NewMockfileStorage(ctrl),
+		mockVersions: mocks.NewMockFileVersionStorage(ctrl),
+		mockLinks:    mocks.NewMockpaymentLinker(ctrl),
+		mockEmail:    mocks.NewMocknotifier(ctrl),
+	}
+
+	fm := gopay.NewFileManager(mf.mockFiles, mf.mockVersions, mf.mockLinks, gopay.Notifiers{
+		gopay.NotifyChannelEmail: mf.mockEmail,
+	})
+
+	return mf, fm
+}
+
+func TestFileManager_GetFile(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		version    uint
+		setupMocks func(f fileMockFields)
+		expected   error
+	}{
+		{
+			name: "legacy file without versions",
+			setupMocks: func(f fileMockFields) {
+				f.mockVersions.EXPECT().GetFileVersions(gopay.ID("file")).Return(nil, nil).Times(1)
+				f.mockFiles.EXPECT().GetData(gomock.Any(), gopay.ID("file"), uint(1)).
+					Return([]byte("data"), nil).Times(1)
+			},
+		},
+		{
+			name: "latest version by default",
+			setupMocks: func(f fileMockFields) {
+				f.mockVersions.EXPECT().GetFileVersions(gopay.ID("file")).
+					Return([]gopay.FileVersion{{Version: 1}, {Version: 2}}, nil).Times(1)
+				f.mockFiles.EXPECT().GetData(gomock.Any(), gopay.ID("file"), uint(2)).
+					Return([]byte("data"), nil).Times(1)
+			},
+		},
+		{
+			name:    "older version",
+			version: 1,
+			setupMocks: func(f fileMockFields) {
+				f.mockVersions.EXPECT().GetFileVersions(gopay.ID("file")).
+					Return([]gopay.FileVersion{{Version: 1}, {Version: 2}}, nil).Times(1)
+				f.mockFiles.EXPECT().GetData(gomock.Any(), gopay.ID("file"), uint(1)).
+					Return([]byte("data"), nil).Times(1)
+			},
+		},
+		{
+			name:    "unknown version",
+			version: 3,
+			setupMocks: func(f fileMockFields) {
+				f.mockVersions.EXPECT().GetFileVersions(gopay.ID("file")).
+					Return([]gopay.FileVersion{{Version: 1}, {Version: 2}}, nil).Times(1)
+			},
+			expected: gopay.ErrUnknownFileVersion,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			mf, fm := setupFileMocks(ctrl)
+			tt.setupMocks(mf)
+
+			data, err := fm.GetFile(context.Background(), "file", tt.version)
+
+			if tt.expected != nil {
+				require.ErrorIs(t, err, tt.expected)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, []byte("data"), data)
+		})
+	}
+}
+
+func TestFileManager_PublishVersion(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		setupMocks func(f fileMockFields)
+		expected   uint
+		errMsg     string
+	}{
+		{
+			name: "first version",
+			setupMocks: func(f fileMockFields) {
+				f.mockVersions.EXPECT().GetFileVersions(gopay.ID("file")).Return(nil, nil).Times(1)
+				f.mockFiles.EXPECT().Exists(gomock.Any(), gopay.ID("file"), uint(1)).Return(false, nil).Times(1)
+				gomock.InOrder(
+					f.mockVersions.EXPECT().AddFileVersions(gopay.ID("file"), gomock.Any()).Return(nil).Times(1),
+					f.mockFiles.EXPECT().PutData(gomock.Any(), gopay.ID("file"), uint(1), []byte("data")).
+						Return(nil).Times(1),
+				)
+			},
+			expected: 1,
+		},
+		{
+			name: "legacy file is tracked as the first version",
+			setupMocks: func(f fileMockFields) {
+				f.mockVersions.EXPECT().GetFileVersions(gopay.ID("file")).Return(nil, nil).Times(1)
+				f.mockFiles.EXPECT().Exists(gomock.Any(), gopay.ID("file"), uint(1)).Return(true, nil).Times(1)
+				f.mockFiles.EXPECT().PutData(gomock.Any(), gopay.ID("file"), uint(2), []byte("data")).
+					Return(nil).Times(1)
+				f.mockVersions.EXPECT().AddFileVersions(gopay.ID("file"), gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ gopay.ID, versions ...gopay.FileVersion) error {
+						if versions[0].Version != 1 || versions[1].Version != 2 {
+							return errors.New("error versions order")
+						}
+
+						return nil
+					}).Times(1)
+			},
+			expected: 2,
+		},
+		{
+			name: "next version",
+			setupMocks: func(f fileMockFields) {
+				f.mockVersions.EXPECT().GetFileVersions(gopay.ID("file")).
+					Return([]gopay.FileVersion{{Version: 1}, {Version: 2}}, nil).Times(1)
+				f.mockVersions.EXPECT().AddFileVersions(gopay.ID("file"), gomock.Any()).Return(nil).Times(1)
+				f.mockFiles.EXPECT().PutData(gomock.Any(), gopay.ID("file"), uint(3), []byte("data")).
+					Return(nil).Times(1)
+			},
+			expected: 3,
+		},
+		{
+			name: "concurrent publish does not overwrite the version",
+			setupMocks: func(f fileMockFields) {
+				f.mockVersions.EXPECT().GetFileVersions(gopay.ID("file")).
+					Return([]gopay.FileVersion{{Version: 1}}, nil).Times(1)
+				f.mockVersions.EXPECT().AddFileVersions(gopay.ID("file"), gomock.Any()).
+					Return(errors.New("error version already exists")).Times(1)
+			},
+			errMsg: "error version already exists",
+		},
+		{
+			name: "error put data",
+			setupMocks: func(f fileMockFields) {
+				f.mockVersions.EXPECT().GetFileVersions(gopay.ID("file")).
+					Return([]gopay.FileVersion{{Version: 1}}, nil).Times(1)
+				f.mockVersions.EXPECT().AddFileVersions(gopay.ID("file"), gomock.Any()).Return(nil).Times(1)
+				f.mockFiles.EXPECT().PutData(gomock.Any(), gopay.ID("file"), uint(2), []byte("data")).
+					Return(errors.New("error put data")).Times(1)
+			},
+			errMsg: "error put data",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			mf, fm := setupFileMocks(ctrl)
+			tt.setupMocks(mf)
+
+			version, err := fm.PublishVersion(context.Background(), "file", []byte("data"), "comment")
+
+			if tt.errMsg != "" {
+				require.EqualError(t, err, tt.errMsg)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, version.Version)
+			assert.Equal(t, "comment", version.Comment)
+		})
+	}
+}
+
+func TestFileManager_NotifyBuyers(t *testing.T) {
+	t.Parallel()
+
+	t.Run("channel not configured", func(t *testing.T) {
+		t.Parallel()
+
+		ctrl := gomock.NewController(t)
+		_, fm := setupFileMocks(ctrl)
+
+		_, err := fm.NotifyBuyers(
+			context.Background(), "file", gopay.FileVersion{Version: 2}, []gopay.NotifyChannel{gopay.NotifyChannelTelegram},
+		)
+
+		require.Error(t, err)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		ctrl := gomock.NewController(t)
+		mf, fm := setupFileMocks(ctrl)
+
+		buyer := gopay.User{ID: "1", Name: "name", Email: "buyer@mail.com"}
+
+		mf.mockVersions.EXPECT().GetProductPayments(gopay.ID("file")).Return(map[gopay.ID]gopay.Payment{
+			"paid":    {User: buyer, Status: gopay.StatusSucceeded},
+			"pending": {User: gopay.User{Email: "other@mail.com"}, Status: gopay.StatusPending},
+		}, nil).Times(1)
+		mf.mockLinks.EXPECT().Link(gopay.ID("paid")).Return(gopay.Link("https://redirect.com/paid")).Times(1)
+		mf.mockEmail.EXPECT().NotifyFileUpdate(gomock.Any(), buyer, gopay.FileUpdate{
+			ProductID: "file",
+			Version:   2,
+			Link:      "https://redirect.com/paid",
+		}).Return(nil).Times(1)
+
+		sent, err := fm.NotifyBuyers(
+			context.Background(), "file", gopay.FileVersion{Version: 2}, []gopay.NotifyChannel{gopay.NotifyChannelEmail},
+		)
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, sent)
+	})
+}
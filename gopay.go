@@ -1,98 +1,469 @@
 package gopay
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+var (
+	ErrCreatePayment = errors.New("create payment failed")
+	ErrInvalidCursor = errors.New("invalid cursor")
 
-var ErrCreatePayment = errors.New("create payment failed")
+	// ErrNotFound is wrapped by storages when the requested entity does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrAlreadyExists is wrapped by storages when the entity being added conflicts with the stored one
+	ErrAlreadyExists = errors.New("already exists")
+	// ErrInvalidTransition is returned when the payment status can not be changed to the requested one
+	ErrInvalidTransition = errors.New("invalid status transition")
+	// ErrProviderUnavailable is wrapped by payment services when the provider can not be reached or fails
+	ErrProviderUnavailable = errors.New("payment provider unavailable")
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
 
 type (
 	linkGenerator interface {
 		GenerateLink() (ID, Link, error)
+		Link(id ID) Link
 	}
 
-	paymentStorage interface {
+	// PaymentStorage keeps payments and links to the paid resources, Get, GetStatus and GetLink return
+	// an error wrapping ErrNotFound for unknown payments, ListPayments filters and pages payments as described by PaymentFilter
+	PaymentStorage interface {
 		Get(id ID) (Payment, error)
-		Set(id ID, pay Payment) error
 		GetStatus(id ID) (Status, error)
 		GetStatuses() (map[ID]Status, error)
-		UpdateStatus(id ID, status Status) error
-		SetLink(id ID, link Link) error
 		GetLink(id ID) (Link, error)
+		ListPayments(filter PaymentFilter) (PaymentPage, error)
+		Update(fn func(tx PaymentTx) error) error
 	}
 
+	// PaymentTx is a unit of work over payment storage, all changes made through it are committed
+	// atomically when the function passed to Update returns nil and discarded otherwise
+	PaymentTx interface {
+		Get(id ID) (Payment, error)
+		Set(id ID, pay Payment) error
+		SetLink(id ID, link Link) error
+	}
+
+	// paymentService creates and refunds payments at the provider, requests with the same idempotency key
+	// create or refund only one payment at the provider
 	paymentService interface {
-		CreatePayment(id ID, template PaymentTemplate) (*Payment, error)
+		CreatePayment(id ID, template PaymentTemplate, idempotencyKey string) (*Payment, error)
+		RefundPayment(payment Payment, idempotencyKey string) error
+	}
+
+	paymentNotifier interface {
+		NotifyPaymentCreated(event PaymentEvent) error
+		NotifyPaymentSucceeded(event PaymentEvent) error
 	}
 )
 
 type PaymentManager struct {
 	links    linkGenerator
-	storage  paymentStorage
+	storage  PaymentStorage
 	payments paymentService
+	notifier paymentNotifier
+	now      func() time.Time
+	ttl      time.Duration
+
+	idempotency    IdempotencyStorage // nil if idempotency keys are not saved
+	idempotencyTTL time.Duration
+
+	auditLog *AuditLog // nil if changes are not recorded
+	actor    Actor     // set by As, changes are recorded only if it is set
+}
+
+type Option func(pm *PaymentManager)
+
+// WithNotifier sets notifier for payment creation and success events,
+// notification errors are logged and do not affect payment processing
+func WithNotifier(notifier paymentNotifier) Option {
+	return func(pm *PaymentManager) {
+		pm.notifier = notifier
+	}
+}
+
+// WithClock sets time source for payment timestamps
+func WithClock(now func() time.Time) Option {
+	return func(pm *PaymentManager) {
+		pm.now = now
+	}
+}
+
+// WithPaymentTTL sets time given to pay for the payment, it is reported to clients as payment expiration time
+// and should match settings of the payment service, which cancels unpaid payments
+func WithPaymentTTL(ttl time.Duration) Option {
+	return func(pm *PaymentManager) {
+		pm.ttl = ttl
+	}
+}
+
+// WithAuditLog records payment creations and refunds made by actors of managers returned by As
+func WithAuditLog(auditLog *AuditLog) Option {
+	return func(pm *PaymentManager) {
+		pm.auditLog = auditLog
+	}
 }
 
 func NewPaymentManager(
-	linkGenerator linkGenerator, paymentStorage paymentStorage, paymentService paymentService,
+	linkGenerator linkGenerator, paymentStorage PaymentStorage, paymentService paymentService, opts ...Option,
 ) *PaymentManager {
-	return &PaymentManager{
+	pm := &PaymentManager{
 		links:    linkGenerator,
 		storage:  paymentStorage,
 		payments: paymentService,
+		now:      time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(pm)
 	}
+
+	return pm
+}
+
+// As returns the manager which records changes made by the actor in the audit log, entries are appended
+// in the transaction of the change if the storage transaction implements AuditTx and after it otherwise
+func (pm *PaymentManager) As(actor Actor) *PaymentManager {
+	acting := *pm
+	acting.actor = actor
+
+	return &acting
 }
 
 func (pm *PaymentManager) CreatePayment(template PaymentTemplate, user User) (Link, error) {
-	id, link, err := pm.links.GenerateLink()
+	details, err := pm.CreatePaymentDetails(template, user)
 	if err != nil {
 		return "", err
 	}
 
-	payment, err := pm.payments.CreatePayment(id, template)
+	return details.Link, nil
+}
+
+// CreatePaymentDetails creates payment like CreatePayment and returns all information about it
+func (pm *PaymentManager) CreatePaymentDetails(template PaymentTemplate, user User) (PaymentDetails, error) {
+	id, link, err := pm.links.GenerateLink()
+	if err != nil {
+		return PaymentDetails{}, err
+	}
+
+	return pm.createPayment(id, link, "", template, user)
+}
+
+// createPayment passes the idempotency key to the payment service, payment ID is used if the key is empty
+func (pm *PaymentManager) createPayment(
+	id ID, link Link, key string, template PaymentTemplate, user User,
+) (PaymentDetails, error) {
+	if key == "" {
+		key = string(id)
+	}
+
+	payment, err := pm.payments.CreatePayment(id, template, key)
 	if err != nil {
-		return "", err
+		return PaymentDetails{}, err
 	}
 
 	if payment == nil {
-		return "", ErrCreatePayment
+		return PaymentDetails{}, ErrCreatePayment
 	}
 
 	payment.User = user
 	payment.ResourceLink = template.ResourceLink
+	payment.ProductID = template.ProductID
+	payment.Currency = template.Currency
+	payment.Description = template.Description
+	payment.CreatedAt = pm.now().UTC()
+	payment.UpdatedAt = payment.CreatedAt
 
-	if err = pm.storage.Set(id, *payment); err != nil {
-		return "", err
+	if pm.ttl > 0 {
+		payment.ExpiresAt = payment.CreatedAt.Add(pm.ttl)
 	}
 
-	if err = pm.storage.SetLink(id, payment.PaymentLink); err != nil {
-		return "", err
+	payment.History = []StatusChange{{
+		Status:    payment.Status,
+		ChangedAt: payment.CreatedAt,
+		Source:    StatusSourceCreation,
+	}}
+
+	var audited bool
+
+	if err = pm.storage.Update(func(tx PaymentTx) error {
+		if err := tx.Set(id, *payment); err != nil {
+			return err
+		}
+
+		if err := tx.SetLink(id, payment.PaymentLink); err != nil {
+			return err
+		}
+
+		audited, err = pm.auditTx(tx, AuditPaymentCreate, id, nil, *payment)
+
+		return err
+	}); err != nil {
+		return PaymentDetails{}, err
 	}
 
-	return link, nil
+	if !audited {
+		pm.audit(AuditPaymentCreate, id, nil, *payment)
+	}
+
+	if pm.notifier != nil {
+		event := PaymentEvent{ID: id, Payment: *payment, Link: link}
+
+		if err = pm.notifier.NotifyPaymentCreated(event); err != nil {
+			slog.Default().Error("notify payment created failed", slog.String("id", string(id)), slog.Any("error", err))
+		}
+	}
+
+	return PaymentDetails{
+		ID:           id,
+		Payment:      *payment,
+		Link:         link,
+		RedirectLink: payment.PaymentLink,
+	}, nil
 }
 
 func (pm *PaymentManager) GetAllPaymentsStatuses() (map[ID]Status, error) {
 	return pm.storage.GetStatuses()
 }
 
+// GetPaymentsStatuses returns statuses of all payments matching the filter, sort, limit and cursor are not applied
+func (pm *PaymentManager) GetPaymentsStatuses(filter PaymentFilter) (map[ID]Status, error) {
+	filter.Sort, filter.Limit, filter.Cursor = "", 0, ""
+	if filter == (PaymentFilter{}) {
+		return pm.storage.GetStatuses()
+	}
+
+	filter.Sort = SortCreatedAsc
+	filter.Limit = maxPageLimit
+
+	statuses := make(map[ID]Status)
+
+	for {
+		page, err := pm.storage.ListPayments(filter)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range page.Payments {
+			statuses[entry.ID] = entry.Payment.Status
+		}
+
+		if page.NextCursor == "" {
+			return statuses, nil
+		}
+
+		filter.Cursor = page.NextCursor
+	}
+}
+
+// ListPayments returns a page of payments matching the filter, newest payments go first by default
+func (pm *PaymentManager) ListPayments(filter PaymentFilter) (PaymentPage, error) {
+	if filter.Sort == "" {
+		filter.Sort = SortCreatedDesc
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = defaultPageLimit
+	}
+
+	filter.Limit = min(filter.Limit, maxPageLimit)
+
+	return pm.storage.ListPayments(filter)
+}
+
 func (pm *PaymentManager) GetPaymentStatus(id ID) (Status, error) {
 	return pm.storage.GetStatus(id)
 }
 
+func (pm *PaymentManager) GetPaymentDetails(id ID) (PaymentDetails, error) {
+	payment, err := pm.storage.Get(id)
+	if err != nil {
+		return PaymentDetails{}, err
+	}
+
+	redirect, err := pm.storage.GetLink(id)
+	if err != nil {
+		return PaymentDetails{}, err
+	}
+
+	return PaymentDetails{
+		ID:           id,
+		Payment:      payment,
+		Link:         pm.links.Link(id),
+		RedirectLink: redirect,
+	}, nil
+}
+
 func (pm *PaymentManager) GetRedirectLink(id ID) (Link, error) {
 	return pm.storage.GetLink(id)
 }
 
+// RefundPayment refunds the full amount of the succeeded payment at the payment service and changes
+// its status to StatusRefunded, the refunded payment is returned as is, ErrInvalidTransition is returned
+// for payments which are not succeeded
+func (pm *PaymentManager) RefundPayment(id ID, requestID string) (Payment, error) {
+	payment, err := pm.storage.Get(id)
+	if err != nil {
+		return Payment{}, err
+	}
+
+	if payment.Status == StatusRefunded {
+		return payment, nil
+	}
+
+	if !payment.Status.CanTransition(StatusRefunded) {
+		return Payment{}, fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, payment.Status, StatusRefunded)
+	}
+
+	// the key is derived from the payment ID, so the retry after failed status update does not refund twice
+	if err = pm.payments.RefundPayment(payment, "refund-"+string(id)); err != nil {
+		return Payment{}, err
+	}
+
+	if err = pm.updateStatus(id, StatusRefunded, StatusSourceAdmin, requestID, AuditPaymentRefund); err != nil {
+		return Payment{}, err
+	}
+
+	return pm.storage.Get(id)
+}
+
+// UpdatePaymentStatus changes payment status as UpdatePaymentStatusFrom, the change is recorded with StatusSourceAdmin
 func (pm *PaymentManager) UpdatePaymentStatus(id ID, newStatus Status) error {
-	if newStatus == StatusSucceeded {
-		payment, err := pm.storage.Get(id)
+	return pm.UpdatePaymentStatusFrom(id, newStatus, StatusSourceAdmin, "")
+}
+
+// UpdatePaymentStatusFrom changes payment status and records the change in the payment history with its source
+// and the ID of the request which caused it, update to the current status is ignored,
+// ErrInvalidTransition is returned if the payment is already finished
+func (pm *PaymentManager) UpdatePaymentStatusFrom(id ID, newStatus Status, source StatusSource, requestID string) error {
+	return pm.updateStatus(id, newStatus, source, requestID, "")
+}
+
+// updateStatus changes payment status as UpdatePaymentStatusFrom and records the change as the action,
+// the change is not recorded if the action is empty
+func (pm *PaymentManager) updateStatus(
+	id ID, newStatus Status, source StatusSource, requestID string, action AuditAction,
+) error {
+	var (
+		before, payment Payment
+		changed         bool
+		audited         bool
+	)
+
+	// payment is read and written in the same transaction, so concurrent webhooks for it are serialized
+	err := pm.storage.Update(func(tx PaymentTx) error {
+		var err error
+
+		payment, err = tx.Get(id)
 		if err != nil {
 			return err
 		}
 
-		err = pm.storage.SetLink(id, payment.ResourceLink)
-		if err != nil {
+		if payment.Status == newStatus {
+			return nil
+		}
+
+		before = payment
+
+		if !payment.Status.CanTransition(newStatus) {
+			return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, payment.Status, newStatus)
+		}
+
+		now := pm.now().UTC()
+
+		payment.Status = newStatus
+		payment.UpdatedAt = now
+		payment.History = append(payment.History, StatusChange{
+			Status:    newStatus,
+			ChangedAt: now,
+			Source:    source,
+			RequestID: requestID,
+		})
+
+		switch newStatus {
+		case StatusSucceeded:
+			payment.PaidAt = now
+
+			if err = tx.SetLink(id, payment.ResourceLink); err != nil {
+				return err
+			}
+		case StatusRefunded:
+			// the customer loses access to the resource of the refunded payment
+			if err = tx.SetLink(id, payment.PaymentLink); err != nil {
+				return err
+			}
+		}
+
+		changed = true
+
+		if err = tx.Set(id, payment); err != nil {
 			return err
 		}
+
+		audited, err = pm.auditTx(tx, action, id, before, payment)
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if changed && !audited {
+		pm.audit(action, id, before, payment)
 	}
 
-	return pm.storage.UpdateStatus(id, newStatus)
+	// repeated webhooks for the paid payment must not duplicate notifications
+	if pm.notifier != nil && changed && newStatus == StatusSucceeded {
+		event := PaymentEvent{ID: id, Payment: payment, Link: pm.links.Link(id)}
+
+		if err = pm.notifier.NotifyPaymentSucceeded(event); err != nil {
+			slog.Default().Error("notify payment succeeded failed", slog.String("id", string(id)), slog.Any("error", err))
+		}
+	}
+
+	return nil
+}
+
+// auditing reports whether the action is recorded, nothing is recorded without the audit log or the actor
+func (pm *PaymentManager) auditing(action AuditAction) bool {
+	return action != "" && pm.auditLog != nil && pm.actor.Name != ""
+}
+
+// auditTx appends the change to the audit log in the transaction, it reports whether the change needs
+// no further recording
+func (pm *PaymentManager) auditTx(tx PaymentTx, action AuditAction, id ID, before, after any) (bool, error) {
+	if !pm.auditing(action) {
+		return true, nil
+	}
+
+	auditTx, ok := tx.(AuditTx)
+	if !ok {
+		return false, nil
+	}
+
+	if _, err := pm.auditLog.RecordTx(auditTx, pm.actor, action, "payment:"+string(id), before, after); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// audit appends the change to the audit log after the transaction, errors are only logged
+// because the change is already committed
+func (pm *PaymentManager) audit(action AuditAction, id ID, before, after any) {
+	if !pm.auditing(action) {
+		return
+	}
+
+	if _, err := pm.auditLog.Record(pm.actor, action, "payment:"+string(id), before, after); err != nil {
+		slog.Default().Error("record audit entry failed", slog.String("id", string(id)),
+			slog.String("action", string(action)), slog.Any("error", err))
+	}
 }
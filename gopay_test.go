@@ -3,6 +3,7 @@ package gopay_test
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -12,24 +13,38 @@ import (
 	"github.com/Anton-Kraev/gopay/mocks"
 )
 
+var testNow = time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
 type mockFields struct {
 	mockLinks    *mocks.MocklinkGenerator
-	mockStorage  *mocks.MockpaymentStorage
+	mockStorage  *mocks.MockPaymentStorage
+	mockTx       *mocks.MockPaymentTx
 	mockPayments *mocks.MockpaymentService
 }
 
 func setupMocks(ctrl *gomock.Controller) (mockFields, *gopay.PaymentManager) {
 	mf := mockFields{
 		mockLinks:    mocks.NewMocklinkGenerator(ctrl),
-		mockStorage:  mocks.NewMockpaymentStorage(ctrl),
+		mockStorage:  mocks.NewMockPaymentStorage(ctrl),
+		mockTx:       mocks.NewMockPaymentTx(ctrl),
 		mockPayments: mocks.NewMockpaymentService(ctrl),
 	}
 
-	pm := gopay.NewPaymentManager(mf.mockLinks, mf.mockStorage, mf.mockPayments)
+	pm := gopay.NewPaymentManager(mf.mockLinks, mf.mockStorage, mf.mockPayments, gopay.WithClock(func() time.Time {
+		return testNow
+	}))
 
 	return mf, pm
 }
 
+// expectUpdate runs unit of work passed to the storage with the mocked transaction
+func expectUpdate(f mockFields) {
+	f.mockStorage.EXPECT().Update(gomock.Any()).
+		DoAndReturn(func(fn func(tx gopay.PaymentTx) error) error {
+			return fn(f.mockTx)
+		}).Times(1)
+}
+
 func TestPaymentManager_CreatePayment(t *testing.T) {
 	t.Parallel()
 
@@ -67,7 +82,7 @@ func TestPaymentManager_CreatePayment(t *testing.T) {
 			setupMocks: func(f mockFields) {
 				f.mockLinks.EXPECT().GenerateLink().
 					Return(gopay.ID("uuid"), gopay.Link("https://redirect.com/uuid"), nil).Times(1)
-				f.mockPayments.EXPECT().CreatePayment(gopay.ID("uuid"), gopay.PaymentTemplate{}).
+				f.mockPayments.EXPECT().CreatePayment(gopay.ID("uuid"), gopay.PaymentTemplate{}, "uuid").
 					Return(nil, errors.New("error create payment")).Times(1)
 			},
 			expected: expected{
@@ -80,7 +95,7 @@ func TestPaymentManager_CreatePayment(t *testing.T) {
 			setupMocks: func(f mockFields) {
 				f.mockLinks.EXPECT().GenerateLink().
 					Return(gopay.ID("uuid"), gopay.Link("https://redirect.com/uuid"), nil).Times(1)
-				f.mockPayments.EXPECT().CreatePayment(gopay.ID("uuid"), gopay.PaymentTemplate{}).
+				f.mockPayments.EXPECT().CreatePayment(gopay.ID("uuid"), gopay.PaymentTemplate{}, "uuid").
 					Return(nil, nil).Times(1)
 			},
 			expected: expected{
@@ -93,9 +108,10 @@ func TestPaymentManager_CreatePayment(t *testing.T) {
 			setupMocks: func(f mockFields) {
 				f.mockLinks.EXPECT().GenerateLink().
 					Return(gopay.ID("uuid"), gopay.Link("https://redirect.com/uuid"), nil).Times(1)
-				f.mockPayments.EXPECT().CreatePayment(gopay.ID("uuid"), gopay.PaymentTemplate{}).
+				f.mockPayments.EXPECT().CreatePayment(gopay.ID("uuid"), gopay.PaymentTemplate{}, "uuid").
 					Return(&gopay.Payment{}, nil).Times(1)
-				f.mockStorage.EXPECT().Set(gopay.ID("uuid"), gomock.Any()).
+				expectUpdate(f)
+				f.mockTx.EXPECT().Set(gopay.ID("uuid"), gomock.Any()).
 					Return(errors.New("error set payment")).Times(1)
 			},
 			expected: expected{
@@ -108,11 +124,12 @@ func TestPaymentManager_CreatePayment(t *testing.T) {
 			setupMocks: func(f mockFields) {
 				f.mockLinks.EXPECT().GenerateLink().
 					Return(gopay.ID("uuid"), gopay.Link("https://redirect.com/uuid"), nil).Times(1)
-				f.mockPayments.EXPECT().CreatePayment(gopay.ID("uuid"), gopay.PaymentTemplate{}).
+				f.mockPayments.EXPECT().CreatePayment(gopay.ID("uuid"), gopay.PaymentTemplate{}, "uuid").
 					Return(&gopay.Payment{PaymentLink: "payment"}, nil).Times(1)
-				f.mockStorage.EXPECT().Set(gopay.ID("uuid"), gomock.Any()).
+				expectUpdate(f)
+				f.mockTx.EXPECT().Set(gopay.ID("uuid"), gomock.Any()).
 					Return(nil).Times(1)
-				f.mockStorage.EXPECT().SetLink(gopay.ID("uuid"), gopay.Link("payment")).
+				f.mockTx.EXPECT().SetLink(gopay.ID("uuid"), gopay.Link("payment")).
 					Return(errors.New("error set link")).Times(1)
 			},
 			expected: expected{
@@ -143,10 +160,11 @@ func TestPaymentManager_CreatePayment(t *testing.T) {
 					Amount:       100,
 					Description:  "description",
 					ResourceLink: "resource",
-				}).
+				}, "uuid").
 					Return(&gopay.Payment{Amount: 100, Status: gopay.StatusPending, PaymentLink: "payment"}, nil).
 					Times(1)
-				f.mockStorage.EXPECT().Set(gopay.ID("uuid"), gomock.Any()).
+				expectUpdate(f)
+				f.mockTx.EXPECT().Set(gopay.ID("uuid"), gomock.Any()).
 					DoAndReturn(func(_ gopay.ID, payment gopay.Payment) error {
 						if payment.Amount != 100 || payment.Status != gopay.StatusPending {
 							return errors.New("error create new payment")
@@ -156,9 +174,13 @@ func TestPaymentManager_CreatePayment(t *testing.T) {
 							return errors.New("error set payment fields")
 						}
 
+						if !payment.CreatedAt.Equal(testNow) || !payment.UpdatedAt.Equal(testNow) || len(payment.History) != 1 {
+							return errors.New("error set payment timestamps")
+						}
+
 						return nil
 					}).Times(1)
-				f.mockStorage.EXPECT().SetLink(gopay.ID("uuid"), gopay.Link("payment")).
+				f.mockTx.EXPECT().SetLink(gopay.ID("uuid"), gopay.Link("payment")).
 					Return(nil).Times(1)
 			},
 			expected: expected{
@@ -189,6 +211,54 @@ func TestPaymentManager_CreatePayment(t *testing.T) {
 	}
 }
 
+func TestPaymentManager_CreatePaymentDetails(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		ttl       time.Duration
+		expiresAt time.Time
+	}{
+		{
+			name: "unlimited",
+		},
+		{
+			name:      "limited",
+			ttl:       time.Hour,
+			expiresAt: testNow.Add(time.Hour),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			mf, _ := setupMocks(ctrl)
+			pm := gopay.NewPaymentManager(mf.mockLinks, mf.mockStorage, mf.mockPayments,
+				gopay.WithClock(func() time.Time { return testNow }),
+				gopay.WithPaymentTTL(tt.ttl),
+			)
+
+			mf.mockLinks.EXPECT().GenerateLink().
+				Return(gopay.ID("uuid"), gopay.Link("https://redirect.com/uuid"), nil).Times(1)
+			mf.mockPayments.EXPECT().CreatePayment(gopay.ID("uuid"), gopay.PaymentTemplate{}, "uuid").
+				Return(&gopay.Payment{Status: gopay.StatusPending, PaymentLink: "payment"}, nil).Times(1)
+			expectUpdate(mf)
+			mf.mockTx.EXPECT().Set(gopay.ID("uuid"), gomock.Any()).Return(nil).Times(1)
+			mf.mockTx.EXPECT().SetLink(gopay.ID("uuid"), gopay.Link("payment")).Return(nil).Times(1)
+
+			details, err := pm.CreatePaymentDetails(gopay.PaymentTemplate{}, gopay.User{})
+			require.NoError(t, err)
+
+			assert.Equal(t, gopay.ID("uuid"), details.ID)
+			assert.Equal(t, gopay.Link("https://redirect.com/uuid"), details.Link)
+			assert.Equal(t, gopay.Link("payment"), details.RedirectLink)
+			assert.Equal(t, tt.expiresAt, details.Payment.ExpiresAt)
+		})
+	}
+}
+
 func TestPaymentManager_GetRedirectLink(t *testing.T) {
 	t.Parallel()
 
@@ -220,7 +290,7 @@ func TestPaymentManager_GetRedirectLink(t *testing.T) {
 	})
 }
 
-func TestPaymentManager_UpdatePaymentStatus(t *testing.T) {
+func TestPaymentManager_UpdatePaymentStatusFrom(t *testing.T) {
 	t.Parallel()
 
 	type args struct {
@@ -241,7 +311,8 @@ func TestPaymentManager_UpdatePaymentStatus(t *testing.T) {
 				status: gopay.StatusSucceeded,
 			},
 			setupMocks: func(f mockFields) {
-				f.mockStorage.EXPECT().Get(gopay.ID("1")).
+				expectUpdate(f)
+				f.mockTx.EXPECT().Get(gopay.ID("1")).
 					Return(gopay.Payment{}, errors.New("error get payment")).Times(1)
 			},
 			errExpected: true,
@@ -253,9 +324,10 @@ func TestPaymentManager_UpdatePaymentStatus(t *testing.T) {
 				status: gopay.StatusSucceeded,
 			},
 			setupMocks: func(f mockFields) {
-				f.mockStorage.EXPECT().Get(gopay.ID("1")).
+				expectUpdate(f)
+				f.mockTx.EXPECT().Get(gopay.ID("1")).
 					Return(gopay.Payment{ResourceLink: "resource.link"}, nil).Times(1)
-				f.mockStorage.EXPECT().SetLink(gopay.ID("1"), gopay.Link("resource.link")).
+				f.mockTx.EXPECT().SetLink(gopay.ID("1"), gopay.Link("resource.link")).
 					Return(errors.New("error set link")).Times(1)
 			},
 			errExpected: true,
@@ -267,8 +339,19 @@ func TestPaymentManager_UpdatePaymentStatus(t *testing.T) {
 				status: gopay.StatusWaitingForCapture,
 			},
 			setupMocks: func(f mockFields) {
-				f.mockStorage.EXPECT().UpdateStatus(gopay.ID("1"), gopay.StatusWaitingForCapture).
-					Return(errors.New("error update status")).Times(1)
+				expectUpdate(f)
+				f.mockTx.EXPECT().Get(gopay.ID("1")).
+					Return(gopay.Payment{Status: gopay.StatusPending}, nil).Times(1)
+				f.mockTx.EXPECT().Set(gopay.ID("1"), gopay.Payment{
+					Status:    gopay.StatusWaitingForCapture,
+					UpdatedAt: testNow,
+					History: []gopay.StatusChange{{
+						Status:    gopay.StatusWaitingForCapture,
+						ChangedAt: testNow,
+						Source:    gopay.StatusSourceWebhook,
+						RequestID: "request",
+					}},
+				}).Return(errors.New("error update status")).Times(1)
 			},
 			errExpected: true,
 		},
@@ -279,12 +362,55 @@ func TestPaymentManager_UpdatePaymentStatus(t *testing.T) {
 				status: gopay.StatusSucceeded,
 			},
 			setupMocks: func(f mockFields) {
-				f.mockStorage.EXPECT().Get(gopay.ID("1")).
-					Return(gopay.Payment{ResourceLink: "resource.link"}, nil).Times(1)
-				f.mockStorage.EXPECT().SetLink(gopay.ID("1"), gopay.Link("resource.link")).
-					Return(nil).Times(1)
-				f.mockStorage.EXPECT().UpdateStatus(gopay.ID("1"), gopay.StatusSucceeded).
+				expectUpdate(f)
+				f.mockTx.EXPECT().Get(gopay.ID("1")).Return(gopay.Payment{
+					Status:       gopay.StatusPending,
+					ResourceLink: "resource.link",
+					History:      []gopay.StatusChange{{Status: gopay.StatusPending, Source: gopay.StatusSourceCreation}},
+				}, nil).Times(1)
+				f.mockTx.EXPECT().SetLink(gopay.ID("1"), gopay.Link("resource.link")).
 					Return(nil).Times(1)
+				f.mockTx.EXPECT().Set(gopay.ID("1"), gopay.Payment{
+					Status:       gopay.StatusSucceeded,
+					ResourceLink: "resource.link",
+					UpdatedAt:    testNow,
+					PaidAt:       testNow,
+					History: []gopay.StatusChange{
+						{Status: gopay.StatusPending, Source: gopay.StatusSourceCreation},
+						{
+							Status:    gopay.StatusSucceeded,
+							ChangedAt: testNow,
+							Source:    gopay.StatusSourceWebhook,
+							RequestID: "request",
+						},
+					},
+				}).Return(nil).Times(1)
+			},
+			errExpected: false,
+		},
+		{
+			name: "finished payment",
+			args: args{
+				id:     gopay.ID("1"),
+				status: gopay.StatusPending,
+			},
+			setupMocks: func(f mockFields) {
+				expectUpdate(f)
+				f.mockTx.EXPECT().Get(gopay.ID("1")).
+					Return(gopay.Payment{Status: gopay.StatusSucceeded}, nil).Times(1)
+			},
+			errExpected: true,
+		},
+		{
+			name: "same status is ignored",
+			args: args{
+				id:     gopay.ID("1"),
+				status: gopay.StatusPending,
+			},
+			setupMocks: func(f mockFields) {
+				expectUpdate(f)
+				f.mockTx.EXPECT().Get(gopay.ID("1")).
+					Return(gopay.Payment{Status: gopay.StatusPending}, nil).Times(1)
 			},
 			errExpected: false,
 		},
@@ -298,9 +424,350 @@ func TestPaymentManager_UpdatePaymentStatus(t *testing.T) {
 			mf, pm := setupMocks(ctrl)
 			tt.setupMocks(mf)
 
-			err := pm.UpdatePaymentStatus(tt.args.id, tt.args.status)
+			err := pm.UpdatePaymentStatusFrom(tt.args.id, tt.args.status, gopay.StatusSourceWebhook, "request")
 
 			require.Equal(t, tt.errExpected, err != nil)
 		})
 	}
 }
+
+func TestPaymentManager_UpdatePaymentStatus(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	mf := mockFields{
+		mockLinks:    mocks.NewMocklinkGenerator(ctrl),
+		mockStorage:  mocks.NewMockPaymentStorage(ctrl),
+		mockTx:       mocks.NewMockPaymentTx(ctrl),
+		mockPayments: mocks.NewMockpaymentService(ctrl),
+	}
+	pm := gopay.NewPaymentManager(
+		mf.mockLinks, mf.mockStorage, mf.mockPayments, gopay.WithClock(func() time.Time { return testNow }),
+	)
+
+	expectUpdate(mf)
+	mf.mockTx.EXPECT().Get(gopay.ID("1")).Return(gopay.Payment{Status: gopay.StatusPending}, nil).Times(1)
+	mf.mockTx.EXPECT().Set(gopay.ID("1"), gopay.Payment{
+		Status:    gopay.StatusCancelled,
+		UpdatedAt: testNow,
+		History: []gopay.StatusChange{
+			{Status: gopay.StatusCancelled, ChangedAt: testNow, Source: gopay.StatusSourceAdmin},
+		},
+	}).Return(nil).Times(1)
+
+	require.NoError(t, pm.UpdatePaymentStatus("1", gopay.StatusCancelled))
+}
+
+func TestPaymentManager_RefundPayment(t *testing.T) {
+	t.Parallel()
+
+	paid := gopay.Payment{
+		Status:       gopay.StatusSucceeded,
+		PaymentLink:  "payment.link",
+		ResourceLink: "resource.link",
+		ProviderID:   "provider",
+	}
+	refunded := paid
+	refunded.Status = gopay.StatusRefunded
+	refunded.UpdatedAt = testNow
+	refunded.History = []gopay.StatusChange{
+		{Status: gopay.StatusRefunded, ChangedAt: testNow, Source: gopay.StatusSourceAdmin, RequestID: "request"},
+	}
+
+	tests := []struct {
+		name        string
+		setupMocks  func(f mockFields)
+		expected    gopay.Payment
+		expectedErr error
+	}{
+		{
+			name: "success",
+			setupMocks: func(f mockFields) {
+				f.mockStorage.EXPECT().Get(gopay.ID("1")).Return(paid, nil).Times(1)
+				f.mockPayments.EXPECT().RefundPayment(paid, "refund-1").Return(nil).Times(1)
+				expectUpdate(f)
+				f.mockTx.EXPECT().Get(gopay.ID("1")).Return(paid, nil).Times(1)
+				f.mockTx.EXPECT().SetLink(gopay.ID("1"), gopay.Link("payment.link")).Return(nil).Times(1)
+				f.mockTx.EXPECT().Set(gopay.ID("1"), refunded).Return(nil).Times(1)
+				f.mockStorage.EXPECT().Get(gopay.ID("1")).Return(refunded, nil).Times(1)
+			},
+			expected: refunded,
+		},
+		{
+			name: "already refunded",
+			setupMocks: func(f mockFields) {
+				f.mockStorage.EXPECT().Get(gopay.ID("1")).Return(refunded, nil).Times(1)
+			},
+			expected: refunded,
+		},
+		{
+			name: "not paid",
+			setupMocks: func(f mockFields) {
+				f.mockStorage.EXPECT().Get(gopay.ID("1")).Return(gopay.Payment{Status: gopay.StatusPending}, nil).Times(1)
+			},
+			expectedErr: gopay.ErrInvalidTransition,
+		},
+		{
+			name: "provider unavailable",
+			setupMocks: func(f mockFields) {
+				f.mockStorage.EXPECT().Get(gopay.ID("1")).Return(paid, nil).Times(1)
+				f.mockPayments.EXPECT().RefundPayment(paid, "refund-1").
+					Return(gopay.ErrProviderUnavailable).Times(1)
+			},
+			expectedErr: gopay.ErrProviderUnavailable,
+		},
+		{
+			name: "payment not found",
+			setupMocks: func(f mockFields) {
+				f.mockStorage.EXPECT().Get(gopay.ID("1")).Return(gopay.Payment{}, gopay.ErrNotFound).Times(1)
+			},
+			expectedErr: gopay.ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			mf, pm := setupMocks(ctrl)
+			tt.setupMocks(mf)
+
+			payment, err := pm.RefundPayment("1", "request")
+
+			require.ErrorIs(t, err, tt.expectedErr)
+			assert.Equal(t, tt.expected, payment)
+		})
+	}
+}
+
+func TestPaymentManager_Notifications(t *testing.T) {
+	t.Parallel()
+
+	setup := func(t *testing.T) (mockFields, *mocks.MockpaymentNotifier, *gopay.PaymentManager) {
+		t.Helper()
+
+		ctrl := gomock.NewController(t)
+		mf := mockFields{
+			mockLinks:    mocks.NewMocklinkGenerator(ctrl),
+			mockStorage:  mocks.NewMockPaymentStorage(ctrl),
+			mockTx:       mocks.NewMockPaymentTx(ctrl),
+			mockPayments: mocks.NewMockpaymentService(ctrl),
+		}
+		mockNotifier := mocks.NewMockpaymentNotifier(ctrl)
+
+		pm := gopay.NewPaymentManager(
+			mf.mockLinks,
+			mf.mockStorage,
+			mf.mockPayments,
+			gopay.WithNotifier(mockNotifier),
+			gopay.WithClock(func() time.Time { return testNow }),
+		)
+
+		return mf, mockNotifier, pm
+	}
+
+	t.Run("payment created", func(t *testing.T) {
+		t.Parallel()
+
+		mf, mockNotifier, pm := setup(t)
+		user := gopay.User{ID: "1", Name: "name", Email: "email@mail.com"}
+
+		mf.mockLinks.EXPECT().GenerateLink().
+			Return(gopay.ID("uuid"), gopay.Link("https://redirect.com/uuid"), nil).Times(1)
+		mf.mockPayments.EXPECT().CreatePayment(gopay.ID("uuid"), gomock.Any(), "uuid").
+			Return(&gopay.Payment{Amount: 100, Status: gopay.StatusPending, PaymentLink: "payment"}, nil).Times(1)
+		expectUpdate(mf)
+		mf.mockTx.EXPECT().Set(gopay.ID("uuid"), gomock.Any()).Return(nil).Times(1)
+		mf.mockTx.EXPECT().SetLink(gopay.ID("uuid"), gopay.Link("payment")).Return(nil).Times(1)
+		mockNotifier.EXPECT().NotifyPaymentCreated(gomock.Any()).
+			DoAndReturn(func(event gopay.PaymentEvent) error {
+				if event.ID != "uuid" || event.Link != "https://redirect.com/uuid" || event.Payment.User != user {
+					return errors.New("error event fields")
+				}
+
+				return nil
+			}).Times(1)
+
+		_, err := pm.CreatePayment(gopay.PaymentTemplate{Amount: 100, Currency: "RUB"}, user)
+
+		require.NoError(t, err)
+	})
+
+	t.Run("payment succeeded", func(t *testing.T) {
+		t.Parallel()
+
+		mf, mockNotifier, pm := setup(t)
+
+		expectUpdate(mf)
+		mf.mockTx.EXPECT().Get(gopay.ID("1")).
+			Return(gopay.Payment{Status: gopay.StatusPending, ResourceLink: "resource.link"}, nil).Times(1)
+		mf.mockTx.EXPECT().SetLink(gopay.ID("1"), gopay.Link("resource.link")).Return(nil).Times(1)
+		mf.mockTx.EXPECT().Set(gopay.ID("1"), gomock.Any()).Return(nil).Times(1)
+		mf.mockLinks.EXPECT().Link(gopay.ID("1")).Return(gopay.Link("https://redirect.com/1")).Times(1)
+		mockNotifier.EXPECT().NotifyPaymentSucceeded(gopay.PaymentEvent{
+			ID: "1",
+			Payment: gopay.Payment{
+				Status:       gopay.StatusSucceeded,
+				ResourceLink: "resource.link",
+				UpdatedAt:    testNow,
+				PaidAt:       testNow,
+				History: []gopay.StatusChange{
+					{Status: gopay.StatusSucceeded, ChangedAt: testNow, Source: gopay.StatusSourceWebhook},
+				},
+			},
+			Link: "https://redirect.com/1",
+		}).Return(nil).Times(1)
+
+		require.NoError(t, pm.UpdatePaymentStatusFrom("1", gopay.StatusSucceeded, gopay.StatusSourceWebhook, ""))
+	})
+
+	t.Run("repeated success is not notified", func(t *testing.T) {
+		t.Parallel()
+
+		mf, _, pm := setup(t)
+
+		expectUpdate(mf)
+		mf.mockTx.EXPECT().Get(gopay.ID("1")).
+			Return(gopay.Payment{Status: gopay.StatusSucceeded, ResourceLink: "resource.link"}, nil).Times(1)
+
+		require.NoError(t, pm.UpdatePaymentStatusFrom("1", gopay.StatusSucceeded, gopay.StatusSourceWebhook, ""))
+	})
+}
+
+func TestPaymentManager_ListPayments(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		filter   gopay.PaymentFilter
+		expected gopay.PaymentFilter
+	}{
+		{
+			name:     "defaults",
+			filter:   gopay.PaymentFilter{Status: gopay.StatusPending},
+			expected: gopay.PaymentFilter{Status: gopay.StatusPending, Sort: gopay.SortCreatedDesc, Limit: 50},
+		},
+		{
+			name:     "limit is capped",
+			filter:   gopay.PaymentFilter{Sort: gopay.SortAmountAsc, Limit: 1000, Cursor: "cursor"},
+			expected: gopay.PaymentFilter{Sort: gopay.SortAmountAsc, Limit: 500, Cursor: "cursor"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			mf, pm := setupMocks(ctrl)
+
+			page := gopay.PaymentPage{Payments: []gopay.PaymentEntry{{ID: "1"}}, NextCursor: "next"}
+			mf.mockStorage.EXPECT().ListPayments(tt.expected).Return(page, nil).Times(1)
+
+			res, err := pm.ListPayments(tt.filter)
+
+			require.NoError(t, err)
+			assert.Equal(t, page, res)
+		})
+	}
+}
+
+func TestPaymentManager_GetPaymentsStatuses(t *testing.T) {
+	t.Parallel()
+
+	t.Run("without filters", func(t *testing.T) {
+		t.Parallel()
+
+		ctrl := gomock.NewController(t)
+		mf, pm := setupMocks(ctrl)
+
+		statuses := map[gopay.ID]gopay.Status{"1": gopay.StatusPending}
+		mf.mockStorage.EXPECT().GetStatuses().Return(statuses, nil).Times(1)
+
+		res, err := pm.GetPaymentsStatuses(gopay.PaymentFilter{Sort: gopay.SortAmountAsc, Limit: 10})
+
+		require.NoError(t, err)
+		assert.Equal(t, statuses, res)
+	})
+
+	t.Run("all pages", func(t *testing.T) {
+		t.Parallel()
+
+		ctrl := gomock.NewController(t)
+		mf, pm := setupMocks(ctrl)
+
+		filter := gopay.PaymentFilter{Email: "buyer@mail.com", Sort: gopay.SortCreatedAsc, Limit: 500}
+		next := filter
+		next.Cursor = "next"
+
+		gomock.InOrder(
+			mf.mockStorage.EXPECT().ListPayments(filter).Return(gopay.PaymentPage{
+				Payments:   []gopay.PaymentEntry{{ID: "1", Payment: gopay.Payment{Status: gopay.StatusPending}}},
+				NextCursor: "next",
+			}, nil).Times(1),
+			mf.mockStorage.EXPECT().ListPayments(next).Return(gopay.PaymentPage{
+				Payments: []gopay.PaymentEntry{{ID: "2", Payment: gopay.Payment{Status: gopay.StatusSucceeded}}},
+			}, nil).Times(1),
+		)
+
+		res, err := pm.GetPaymentsStatuses(gopay.PaymentFilter{Email: "buyer@mail.com", Limit: 1, Cursor: "cursor"})
+
+		require.NoError(t, err)
+		assert.Equal(t, map[gopay.ID]gopay.Status{"1": gopay.StatusPending, "2": gopay.StatusSucceeded}, res)
+	})
+
+	t.Run("error list payments", func(t *testing.T) {
+		t.Parallel()
+
+		ctrl := gomock.NewController(t)
+		mf, pm := setupMocks(ctrl)
+
+		mf.mockStorage.EXPECT().ListPayments(gomock.Any()).
+			Return(gopay.PaymentPage{}, errors.New("error list payments")).Times(1)
+
+		_, err := pm.GetPaymentsStatuses(gopay.PaymentFilter{Status: gopay.StatusPending})
+
+		require.EqualError(t, err, "error list payments")
+	})
+}
+
+func TestPaymentManager_GetPaymentDetails(t *testing.T) {
+	t.Parallel()
+
+	t.Run("error get payment", func(t *testing.T) {
+		t.Parallel()
+
+		ctrl := gomock.NewController(t)
+		mf, pm := setupMocks(ctrl)
+
+		mf.mockStorage.EXPECT().Get(gopay.ID("1")).Return(gopay.Payment{}, errors.New("error get payment")).Times(1)
+
+		_, err := pm.GetPaymentDetails("1")
+
+		require.EqualError(t, err, "error get payment")
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		ctrl := gomock.NewController(t)
+		mf, pm := setupMocks(ctrl)
+
+		payment := gopay.Payment{Amount: 100, Status: gopay.StatusSucceeded, ProviderID: "provider"}
+
+		mf.mockStorage.EXPECT().Get(gopay.ID("1")).Return(payment, nil).Times(1)
+		mf.mockStorage.EXPECT().GetLink(gopay.ID("1")).Return(gopay.Link("resource.link"), nil).Times(1)
+		mf.mockLinks.EXPECT().Link(gopay.ID("1")).Return(gopay.Link("https://redirect.com/1")).Times(1)
+
+		details, err := pm.GetPaymentDetails("1")
+
+		require.NoError(t, err)
+		assert.Equal(t, gopay.PaymentDetails{
+			ID:           "1",
+			Payment:      payment,
+			Link:         "https://redirect.com/1",
+			RedirectLink: "resource.link",
+		}, details)
+	})
+}
@@ -0,0 +1,189 @@
+package gopay
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// HeaderIdempotencyKey is the request header with the idempotency key of payment creation
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+// maxIdempotencyKeyLength is the limit of the payment service
+const maxIdempotencyKeyLength = 64
+
+var (
+	// ErrInvalidIdempotencyKey is returned for keys which can not be passed to the payment service
+	ErrInvalidIdempotencyKey = errors.New("invalid idempotency key")
+	// ErrIdempotencyKeyReused is returned when the idempotency key is sent with another request
+	ErrIdempotencyKeyReused = errors.New("idempotency key is used for another request")
+	// ErrRequestInProgress is returned when the request with the same idempotency key is not finished yet
+	ErrRequestInProgress = errors.New("request with the idempotency key is in progress")
+)
+
+// IdempotencyStorage keeps idempotency keys of payment creation requests until they expire
+type IdempotencyStorage interface {
+	// ReserveIdempotencyKey saves the record unless the key is already saved and not expired, the key
+	// released by the failed request is reserved again by the request with the same hash keeping the saved
+	// payment ID, returns the saved record and whether the key is reserved by the call
+	ReserveIdempotencyKey(key string, record IdempotencyRecord) (IdempotencyRecord, bool, error)
+	// CompleteIdempotencyKey saves ID of the payment created by the request with the key
+	CompleteIdempotencyKey(key string, id ID) error
+	// ReleaseIdempotencyKey marks the request with the key failed, so the request can be retried
+	ReleaseIdempotencyKey(key string) error
+}
+
+// IdempotencyState is the state of the request with the idempotency key
+type IdempotencyState string
+
+const (
+	IdempotencyInProgress IdempotencyState = "in_progress"
+	IdempotencyCompleted  IdempotencyState = "completed"
+	IdempotencyReleased   IdempotencyState = "released"
+)
+
+// IdempotencyRecord is saved for the idempotency key of the payment creation request
+type IdempotencyRecord struct {
+	// RequestHash is SHA-256 of the request, retries with the key must have the same hash
+	RequestHash string `json:"request_hash"`
+	// PaymentID is generated when the key is reserved, retries of the failed request create the payment
+	// with the same ID, so the payment service receives the same request with the key
+	PaymentID ID               `json:"payment_id,omitempty"`
+	State     IdempotencyState `json:"state"`
+	ExpiresAt time.Time        `json:"expires_at"`
+}
+
+// WithIdempotency saves idempotency keys of payment creation requests for ttl,
+// without it the keys are only passed to the payment service
+func WithIdempotency(storage IdempotencyStorage, ttl time.Duration) Option {
+	return func(pm *PaymentManager) {
+		pm.idempotency = storage
+		pm.idempotencyTTL = ttl
+	}
+}
+
+// CreatePaymentOnce creates payment like CreatePaymentDetails only once for the idempotency key,
+// retries of the request return the created payment and true, while the same key with another
+// request fails with ErrIdempotencyKeyReused, the payment is always created if the key is empty
+func (pm *PaymentManager) CreatePaymentOnce(key string, template PaymentTemplate, user User) (PaymentDetails, bool, error) {
+	if key != "" && !validIdempotencyKey(key) {
+		return PaymentDetails{}, false, ErrInvalidIdempotencyKey
+	}
+
+	id, link, err := pm.links.GenerateLink()
+	if err != nil {
+		return PaymentDetails{}, false, err
+	}
+
+	if key == "" || pm.idempotency == nil {
+		details, err := pm.createPayment(id, link, key, template, user)
+
+		return details, false, err
+	}
+
+	hash, err := requestHash(template, user)
+	if err != nil {
+		return PaymentDetails{}, false, err
+	}
+
+	saved, reserved, err := pm.idempotency.ReserveIdempotencyKey(key, IdempotencyRecord{
+		RequestHash: hash,
+		PaymentID:   id,
+		State:       IdempotencyInProgress,
+		ExpiresAt:   pm.now().UTC().Add(pm.idempotencyTTL),
+	})
+	if err != nil {
+		return PaymentDetails{}, false, err
+	}
+
+	if !reserved {
+		switch {
+		case saved.RequestHash != hash:
+			return PaymentDetails{}, false, ErrIdempotencyKeyReused
+		case saved.State != IdempotencyCompleted:
+			return PaymentDetails{}, false, ErrRequestInProgress
+		}
+
+		details, err := pm.GetPaymentDetails(saved.PaymentID)
+
+		return details, true, err
+	}
+
+	if saved.PaymentID != id {
+		// the failed request is retried with the payment ID of the first attempt,
+		// the payment may be already saved if only the key completion failed
+		details, err := pm.GetPaymentDetails(saved.PaymentID)
+		switch {
+		case err == nil:
+			pm.completeIdempotencyKey(key, details.ID)
+
+			return details, true, nil
+		case !errors.Is(err, ErrNotFound):
+			return PaymentDetails{}, false, errors.Join(err, pm.idempotency.ReleaseIdempotencyKey(key))
+		}
+
+		id, link = saved.PaymentID, pm.links.Link(saved.PaymentID)
+	}
+
+	details, err := pm.createPayment(id, link, key, template, user)
+	if err != nil {
+		// nothing is saved, so the request can be retried with the same key and payment ID
+		if releaseErr := pm.idempotency.ReleaseIdempotencyKey(key); releaseErr != nil {
+			err = errors.Join(err, releaseErr)
+		}
+
+		return PaymentDetails{}, false, err
+	}
+
+	pm.completeIdempotencyKey(key, details.ID)
+
+	return details, false, nil
+}
+
+// completeIdempotencyKey saves ID of the created payment, the payment is already created, so the key is released
+// if it can not be completed, otherwise retries would be rejected as in progress until the key expires,
+// the retry finds the saved payment by the payment ID of the key
+func (pm *PaymentManager) completeIdempotencyKey(key string, id ID) {
+	err := pm.idempotency.CompleteIdempotencyKey(key, id)
+	if err == nil {
+		return
+	}
+
+	if releaseErr := pm.idempotency.ReleaseIdempotencyKey(key); releaseErr != nil {
+		err = errors.Join(err, releaseErr)
+	}
+
+	slog.Default().Error("complete idempotency key failed", slog.String("id", string(id)), slog.Any("error", err))
+}
+
+// validIdempotencyKey allows printable ASCII characters only
+func validIdempotencyKey(key string) bool {
+	if len(key) > maxIdempotencyKeyLength {
+		return false
+	}
+
+	for _, r := range key {
+		if r < ' ' || r > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func requestHash(template PaymentTemplate, user User) (string, error) {
+	data, err := json.Marshal(struct {
+		Template PaymentTemplate `json:"template"`
+		User     User            `json:"user"`
+	}{template, user})
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(data)
+
+	return hex.EncodeToString(hash[:]), nil
+}
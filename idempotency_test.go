@@ -0,0 +1,269 @@
+package gopay_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/Anton-Kraev/gopay"
+	"github.com/Anton-Kraev/gopay/mocks"
+)
+
+const testKey = "key"
+
+func setupIdempotencyMocks(ctrl *gomock.Controller) (mockFields, *mocks.MockIdempotencyStorage, *gopay.PaymentManager) {
+	mf, _ := setupMocks(ctrl)
+	keys := mocks.NewMockIdempotencyStorage(ctrl)
+
+	pm := gopay.NewPaymentManager(mf.mockLinks, mf.mockStorage, mf.mockPayments,
+		gopay.WithClock(func() time.Time { return testNow }),
+		gopay.WithIdempotency(keys, time.Hour),
+	)
+
+	return mf, keys, pm
+}
+
+// expectGenerate expects the payment ID to be generated before the key is reserved
+func expectGenerate(f mockFields) {
+	f.mockLinks.EXPECT().GenerateLink().
+		Return(gopay.ID("uuid"), gopay.Link("https://redirect.com/uuid"), nil).Times(1)
+}
+
+// expectCreate expects the payment with the ID to be created with the key passed to the payment service
+func expectCreate(f mockFields, id gopay.ID, key string) {
+	f.mockPayments.EXPECT().CreatePayment(id, gopay.PaymentTemplate{}, key).
+		Return(&gopay.Payment{Status: gopay.StatusPending, PaymentLink: "payment"}, nil).Times(1)
+	expectUpdate(f)
+	f.mockTx.EXPECT().Set(id, gomock.Any()).Return(nil).Times(1)
+	f.mockTx.EXPECT().SetLink(id, gopay.Link("payment")).Return(nil).Times(1)
+}
+
+// reserveRecord reserves the key with the record of the request
+func reserveRecord(_ string, record gopay.IdempotencyRecord) (gopay.IdempotencyRecord, bool, error) {
+	return record, true, nil
+}
+
+// savedRecord returns the key saved by the previous request with the same body in the state
+func savedRecord(
+	id gopay.ID, state gopay.IdempotencyState, reserved bool,
+) func(string, gopay.IdempotencyRecord) (gopay.IdempotencyRecord, bool, error) {
+	return func(_ string, record gopay.IdempotencyRecord) (gopay.IdempotencyRecord, bool, error) {
+		return gopay.IdempotencyRecord{RequestHash: record.RequestHash, PaymentID: id, State: state}, reserved, nil
+	}
+}
+
+func TestPaymentManager_CreatePaymentOnce(t *testing.T) {
+	t.Parallel()
+
+	type expected struct {
+		id       gopay.ID
+		replayed bool
+		err      error
+	}
+
+	tests := []struct {
+		name       string
+		key        string
+		setupMocks func(f mockFields, keys *mocks.MockIdempotencyStorage)
+		expected   expected
+	}{
+		{
+			name: "without key",
+			setupMocks: func(f mockFields, _ *mocks.MockIdempotencyStorage) {
+				expectGenerate(f)
+				expectCreate(f, "uuid", "uuid")
+			},
+			expected: expected{id: "uuid"},
+		},
+		{
+			name:       "invalid key",
+			key:        strings.Repeat("k", 65),
+			setupMocks: func(mockFields, *mocks.MockIdempotencyStorage) {},
+			expected:   expected{err: gopay.ErrInvalidIdempotencyKey},
+		},
+		{
+			name: "first request",
+			key:  testKey,
+			setupMocks: func(f mockFields, keys *mocks.MockIdempotencyStorage) {
+				expectGenerate(f)
+				keys.EXPECT().ReserveIdempotencyKey(testKey, gomock.Any()).
+					DoAndReturn(func(_ string, record gopay.IdempotencyRecord) (gopay.IdempotencyRecord, bool, error) {
+						if record.RequestHash == "" || record.PaymentID != "uuid" ||
+							record.State != gopay.IdempotencyInProgress || !record.ExpiresAt.Equal(testNow.Add(time.Hour)) {
+							return gopay.IdempotencyRecord{}, false, errors.New("error reserve key")
+						}
+
+						return record, true, nil
+					}).Times(1)
+				expectCreate(f, "uuid", testKey)
+				keys.EXPECT().CompleteIdempotencyKey(testKey, gopay.ID("uuid")).Return(nil).Times(1)
+			},
+			expected: expected{id: "uuid"},
+		},
+		{
+			name: "failed request releases key",
+			key:  testKey,
+			setupMocks: func(f mockFields, keys *mocks.MockIdempotencyStorage) {
+				expectGenerate(f)
+				keys.EXPECT().ReserveIdempotencyKey(testKey, gomock.Any()).DoAndReturn(reserveRecord).Times(1)
+				f.mockPayments.EXPECT().CreatePayment(gopay.ID("uuid"), gopay.PaymentTemplate{}, testKey).
+					Return(nil, gopay.ErrProviderUnavailable).Times(1)
+				keys.EXPECT().ReleaseIdempotencyKey(testKey).Return(nil).Times(1)
+			},
+			expected: expected{err: gopay.ErrProviderUnavailable},
+		},
+		{
+			name: "failed completion releases key",
+			key:  testKey,
+			setupMocks: func(f mockFields, keys *mocks.MockIdempotencyStorage) {
+				expectGenerate(f)
+				keys.EXPECT().ReserveIdempotencyKey(testKey, gomock.Any()).DoAndReturn(reserveRecord).Times(1)
+				expectCreate(f, "uuid", testKey)
+				keys.EXPECT().CompleteIdempotencyKey(testKey, gopay.ID("uuid")).
+					Return(errors.New("error complete key")).Times(1)
+				keys.EXPECT().ReleaseIdempotencyKey(testKey).Return(nil).Times(1)
+			},
+			expected: expected{id: "uuid"},
+		},
+		{
+			name: "retry of failed request reuses payment ID",
+			key:  testKey,
+			setupMocks: func(f mockFields, keys *mocks.MockIdempotencyStorage) {
+				expectGenerate(f)
+				keys.EXPECT().ReserveIdempotencyKey(testKey, gomock.Any()).
+					DoAndReturn(savedRecord("first", gopay.IdempotencyInProgress, true)).Times(1)
+				f.mockStorage.EXPECT().Get(gopay.ID("first")).Return(gopay.Payment{}, gopay.ErrNotFound).Times(1)
+				f.mockLinks.EXPECT().Link(gopay.ID("first")).Return(gopay.Link("https://redirect.com/first")).Times(1)
+				expectCreate(f, "first", testKey)
+				keys.EXPECT().CompleteIdempotencyKey(testKey, gopay.ID("first")).Return(nil).Times(1)
+			},
+			expected: expected{id: "first"},
+		},
+		{
+			name: "retry after failed completion replays payment",
+			key:  testKey,
+			setupMocks: func(f mockFields, keys *mocks.MockIdempotencyStorage) {
+				expectGenerate(f)
+				keys.EXPECT().ReserveIdempotencyKey(testKey, gomock.Any()).
+					DoAndReturn(savedRecord("first", gopay.IdempotencyInProgress, true)).Times(1)
+				f.mockStorage.EXPECT().Get(gopay.ID("first")).
+					Return(gopay.Payment{Status: gopay.StatusPending, PaymentLink: "payment"}, nil).Times(1)
+				f.mockStorage.EXPECT().GetLink(gopay.ID("first")).Return(gopay.Link("payment"), nil).Times(1)
+				f.mockLinks.EXPECT().Link(gopay.ID("first")).Return(gopay.Link("https://redirect.com/first")).Times(1)
+				keys.EXPECT().CompleteIdempotencyKey(testKey, gopay.ID("first")).Return(nil).Times(1)
+			},
+			expected: expected{id: "first", replayed: true},
+		},
+		{
+			name: "retry replays payment",
+			key:  testKey,
+			setupMocks: func(f mockFields, keys *mocks.MockIdempotencyStorage) {
+				expectGenerate(f)
+				keys.EXPECT().ReserveIdempotencyKey(testKey, gomock.Any()).
+					DoAndReturn(savedRecord("uuid", gopay.IdempotencyCompleted, false)).Times(1)
+				f.mockStorage.EXPECT().Get(gopay.ID("uuid")).
+					Return(gopay.Payment{Status: gopay.StatusPending, PaymentLink: "payment"}, nil).Times(1)
+				f.mockStorage.EXPECT().GetLink(gopay.ID("uuid")).Return(gopay.Link("payment"), nil).Times(1)
+				f.mockLinks.EXPECT().Link(gopay.ID("uuid")).Return(gopay.Link("https://redirect.com/uuid")).Times(1)
+			},
+			expected: expected{id: "uuid", replayed: true},
+		},
+		{
+			name: "request in progress",
+			key:  testKey,
+			setupMocks: func(f mockFields, keys *mocks.MockIdempotencyStorage) {
+				expectGenerate(f)
+				keys.EXPECT().ReserveIdempotencyKey(testKey, gomock.Any()).
+					DoAndReturn(savedRecord("first", gopay.IdempotencyInProgress, false)).Times(1)
+			},
+			expected: expected{err: gopay.ErrRequestInProgress},
+		},
+		{
+			name: "key reused",
+			key:  testKey,
+			setupMocks: func(f mockFields, keys *mocks.MockIdempotencyStorage) {
+				expectGenerate(f)
+				keys.EXPECT().ReserveIdempotencyKey(testKey, gomock.Any()).
+					Return(gopay.IdempotencyRecord{RequestHash: "other", PaymentID: "first"}, false, nil).Times(1)
+			},
+			expected: expected{err: gopay.ErrIdempotencyKeyReused},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			mf, keys, pm := setupIdempotencyMocks(ctrl)
+			tt.setupMocks(mf, keys)
+
+			details, replayed, err := pm.CreatePaymentOnce(tt.key, gopay.PaymentTemplate{}, gopay.User{})
+
+			require.ErrorIs(t, err, tt.expected.err)
+			assert.Equal(t, tt.expected.id, details.ID)
+			assert.Equal(t, tt.expected.replayed, replayed)
+		})
+	}
+}
+
+func TestPaymentManager_CreatePaymentOnce_RetryAfterTimeout(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	mf, keys, pm := setupIdempotencyMocks(ctrl)
+
+	var saved gopay.IdempotencyRecord
+
+	// the storage keeps the record of the first attempt and reserves it again after the release
+	keys.EXPECT().ReserveIdempotencyKey(testKey, gomock.Any()).
+		DoAndReturn(func(_ string, record gopay.IdempotencyRecord) (gopay.IdempotencyRecord, bool, error) {
+			if saved.PaymentID == "" {
+				saved = record
+			}
+
+			saved.State = gopay.IdempotencyInProgress
+
+			return saved, true, nil
+		}).Times(2)
+	keys.EXPECT().ReleaseIdempotencyKey(testKey).DoAndReturn(func(string) error {
+		saved.State = gopay.IdempotencyReleased
+
+		return nil
+	}).Times(1)
+	keys.EXPECT().CompleteIdempotencyKey(testKey, gopay.ID("first")).Return(nil).Times(1)
+
+	gomock.InOrder(
+		mf.mockLinks.EXPECT().GenerateLink().
+			Return(gopay.ID("first"), gopay.Link("https://redirect.com/first"), nil),
+		mf.mockLinks.EXPECT().GenerateLink().
+			Return(gopay.ID("second"), gopay.Link("https://redirect.com/second"), nil),
+	)
+
+	// the payment service receives the same payment ID with the key both times
+	gomock.InOrder(
+		mf.mockPayments.EXPECT().CreatePayment(gopay.ID("first"), gopay.PaymentTemplate{}, testKey).
+			Return(nil, gopay.ErrProviderUnavailable),
+		mf.mockPayments.EXPECT().CreatePayment(gopay.ID("first"), gopay.PaymentTemplate{}, testKey).
+			Return(&gopay.Payment{Status: gopay.StatusPending, PaymentLink: "payment"}, nil),
+	)
+	mf.mockStorage.EXPECT().Get(gopay.ID("first")).Return(gopay.Payment{}, gopay.ErrNotFound).Times(1)
+	mf.mockLinks.EXPECT().Link(gopay.ID("first")).Return(gopay.Link("https://redirect.com/first")).Times(1)
+	expectUpdate(mf)
+	mf.mockTx.EXPECT().Set(gopay.ID("first"), gomock.Any()).Return(nil).Times(1)
+	mf.mockTx.EXPECT().SetLink(gopay.ID("first"), gopay.Link("payment")).Return(nil).Times(1)
+
+	_, _, err := pm.CreatePaymentOnce(testKey, gopay.PaymentTemplate{}, gopay.User{})
+	require.ErrorIs(t, err, gopay.ErrProviderUnavailable)
+
+	details, replayed, err := pm.CreatePaymentOnce(testKey, gopay.PaymentTemplate{}, gopay.User{})
+	require.NoError(t, err)
+	assert.Equal(t, gopay.ID("first"), details.ID)
+	assert.Equal(t, gopay.Link("https://redirect.com/first"), details.Link)
+	assert.False(t, replayed)
+}
@@ -1,9 +1,12 @@
 package minio
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
+	"net/http"
+	"path"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
@@ -11,6 +14,12 @@ import (
 	"github.com/Anton-Kraev/gopay"
 )
 
+const (
+	backupPrefix = "backups"
+	// backupPartSize limits memory used for upload of the backup with unknown size
+	backupPartSize = 16 << 20
+)
+
 type Client struct {
 	bucketName string
 	client     *minio.Client
@@ -44,8 +53,8 @@ func NewClient(ctx context.Context, config Config) (c Client, err error) {
 	return c, nil
 }
 
-func (c Client) GetData(ctx context.Context, id gopay.ID) ([]byte, error) {
-	obj, err := c.client.GetObject(ctx, c.bucketName, string(id)+".pdf", minio.GetObjectOptions{})
+func (c Client) GetData(ctx context.Context, id gopay.ID, version uint) ([]byte, error) {
+	obj, err := c.client.GetObject(ctx, c.bucketName, objectName(id, version), minio.GetObjectOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("minio.Client.GetData: %w", err)
 	}
@@ -57,3 +66,57 @@ func (c Client) GetData(ctx context.Context, id gopay.ID) ([]byte, error) {
 
 	return file, nil
 }
+
+func (c Client) PutData(ctx context.Context, id gopay.ID, version uint, data []byte) error {
+	_, err := c.client.PutObject(
+		ctx,
+		c.bucketName,
+		objectName(id, version),
+		bytes.NewReader(data),
+		int64(len(data)),
+		minio.PutObjectOptions{ContentType: "application/pdf"},
+	)
+	if err != nil {
+		return fmt.Errorf("minio.Client.PutData: %w", err)
+	}
+
+	return nil
+}
+
+func (c Client) Exists(ctx context.Context, id gopay.ID, version uint) (bool, error) {
+	_, err := c.client.StatObject(ctx, c.bucketName, objectName(id, version), minio.StatObjectOptions{})
+	if err == nil {
+		return true, nil
+	}
+
+	if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+
+	return false, fmt.Errorf("minio.Client.Exists: %w", err)
+}
+
+// PutBackup uploads database backup of unknown size under backups/ prefix
+func (c Client) PutBackup(ctx context.Context, name string, r io.Reader) error {
+	if _, err := c.client.PutObject(
+		ctx,
+		c.bucketName,
+		path.Join(backupPrefix, name),
+		r,
+		-1,
+		minio.PutObjectOptions{ContentType: "application/octet-stream", PartSize: backupPartSize},
+	); err != nil {
+		return fmt.Errorf("minio.Client.PutBackup: %w", err)
+	}
+
+	return nil
+}
+
+// objectName keeps the first version under the key used before file versioning
+func objectName(id gopay.ID, version uint) string {
+	if version <= 1 {
+		return string(id) + ".pdf"
+	}
+
+	return fmt.Sprintf("%s.v%d.pdf", id, version)
+}
@@ -0,0 +1,9 @@
+package smtp
+
+type Config struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	From     string
+}
@@ -5,7 +5,6 @@ import (
 	"net/http"
 
 	"github.com/go-resty/resty/v2"
-	"github.com/google/uuid"
 
 	"github.com/Anton-Kraev/gopay"
 )
@@ -13,6 +12,10 @@ import (
 const (
 	baseURL               = "https://api.yookassa.ru/v3"
 	createPaymentEndpoint = "/payments"
+	createRefundEndpoint  = "/refunds"
+
+	// refundCanceled is the status of refunds rejected by YooKassa, other statuses mean the refund is accepted
+	refundCanceled = "canceled"
 )
 
 type Client struct {
@@ -29,7 +32,11 @@ func NewClient(config Config) Client {
 	}
 }
 
-func (c Client) CreatePayment(id gopay.ID, template gopay.PaymentTemplate) (*gopay.Payment, error) {
+// CreatePayment creates payment with the idempotency key, YooKassa returns the same payment
+// for repeated requests with the key within 24 hours
+func (c Client) CreatePayment(
+	id gopay.ID, template gopay.PaymentTemplate, idempotencyKey string,
+) (*gopay.Payment, error) {
 	const op = "yookassa.Client.CreatePayment"
 
 	yookassaPayment := &Payment{
@@ -51,10 +58,15 @@ func (c Client) CreatePayment(id gopay.ID, template gopay.PaymentTemplate) (*gop
 	resp, err := c.http.R().
 		SetBody(yookassaPayment).
 		SetResult(yookassaPayment).
-		SetHeader("Idempotence-Key", uuid.New().String()).
+		SetHeader("Idempotence-Key", idempotencyKey).
 		Post(createPaymentEndpoint)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w: %w", op, gopay.ErrProviderUnavailable, err)
+	}
+
+	// client errors mean the request itself is wrong, only server errors mean the provider is unavailable
+	if resp.StatusCode() >= http.StatusInternalServerError {
+		return nil, fmt.Errorf("%s: %w: error response from API %s", op, gopay.ErrProviderUnavailable, resp.String())
 	}
 
 	if resp.StatusCode() != http.StatusOK {
@@ -65,6 +77,7 @@ func (c Client) CreatePayment(id gopay.ID, template gopay.PaymentTemplate) (*gop
 		Amount:      template.Amount,
 		Status:      gopay.Status(yookassaPayment.Status),
 		PaymentLink: gopay.Link(yookassaPayment.Confirmation.ConfirmationURL),
+		ProviderID:  yookassaPayment.ID,
 	}
 
 	if yookassaPayment.ID == "" {
@@ -81,3 +94,43 @@ func (c Client) CreatePayment(id gopay.ID, template gopay.PaymentTemplate) (*gop
 
 	return payment, nil
 }
+
+// RefundPayment refunds the full amount of the payment with the idempotency key
+func (c Client) RefundPayment(payment gopay.Payment, idempotencyKey string) error {
+	const op = "yookassa.Client.RefundPayment"
+
+	if payment.ProviderID == "" {
+		return fmt.Errorf("%s: empty payment ID", op)
+	}
+
+	refund := &Refund{
+		PaymentID: payment.ProviderID,
+		Amount: Amount{
+			Value:    fmt.Sprintf("%d", payment.Amount),
+			Currency: payment.Currency,
+		},
+	}
+
+	resp, err := c.http.R().
+		SetBody(refund).
+		SetResult(refund).
+		SetHeader("Idempotence-Key", idempotencyKey).
+		Post(createRefundEndpoint)
+	if err != nil {
+		return fmt.Errorf("%s: %w: %w", op, gopay.ErrProviderUnavailable, err)
+	}
+
+	if resp.StatusCode() >= http.StatusInternalServerError {
+		return fmt.Errorf("%s: %w: error response from API %s", op, gopay.ErrProviderUnavailable, resp.String())
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		return fmt.Errorf("%s: error response from API %s", op, resp.String())
+	}
+
+	if refund.Status == refundCanceled {
+		return fmt.Errorf("%s: refund %s is canceled", op, refund.ID)
+	}
+
+	return nil
+}
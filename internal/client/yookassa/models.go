@@ -24,3 +24,10 @@ type Payment struct {
 	Description  string       `json:"description"`
 	Capture      bool         `json:"capture"`
 }
+
+type Refund struct {
+	ID        string `json:"id,omitempty"`
+	PaymentID string `json:"payment_id"`
+	Status    string `json:"status,omitempty"`
+	Amount    Amount `json:"amount"`
+}
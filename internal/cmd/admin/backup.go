@@ -0,0 +1,173 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/urfave/cli/v3"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+type backupOptions struct {
+	serverURL  string
+	adminToken string
+	output     string
+	compress   bool
+}
+
+func newBackupCmd(admin *Admin) *cli.Command {
+	var opts backupOptions
+
+	return &cli.Command{
+		Name:  "backup",
+		Usage: "Save database snapshot to the file",
+		Description: "Snapshot is downloaded from the running API if server URL is set, " +
+			"otherwise it is read from the database file of the stopped API",
+		UsageText: "gopay backup [--server-url <url> --admin-token <token>] [--compress] [--output <path>]",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "server-url",
+				Usage:       "GoPay server base URL",
+				Sources:     cli.EnvVars("GOPAY_SERVER_URL"),
+				Destination: &opts.serverURL,
+			},
+			&cli.StringFlag{
+				Name:        "admin-token",
+				Usage:       "API key or admin token for admin routes of the server",
+				Sources:     cli.EnvVars("ADMIN_TOKEN"),
+				Destination: &opts.adminToken,
+			},
+			&cli.StringFlag{
+				Name:        "output",
+				Aliases:     []string{"o"},
+				Usage:       "Backup file path, - for stdout (default: gopay-<time>.db[.gz])",
+				Destination: &opts.output,
+			},
+			&cli.BoolFlag{
+				Name:        "compress",
+				Usage:       "Compress snapshot with gzip",
+				Destination: &opts.compress,
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			if err := admin.Backup(ctx, cmd, opts); err != nil {
+				return fmt.Errorf("Admin.Backup: %w", err)
+			}
+
+			return nil
+		},
+	}
+}
+
+func (a *Admin) Backup(ctx context.Context, cmd *cli.Command, opts backupOptions) (err error) {
+	write := func(w io.Writer) error { return a.localBackup(w, opts.compress) }
+	if opts.serverURL != "" {
+		write = func(w io.Writer) error { return remoteBackup(ctx, w, opts) }
+	}
+
+	if opts.output == "-" {
+		return write(cmd.Root().Writer)
+	}
+
+	if opts.output == "" {
+		opts.output = gopay.BackupName(time.Now(), opts.compress)
+	}
+
+	// partial snapshot must not be mistaken for a backup
+	tmp, err := os.CreateTemp(filepath.Dir(opts.output), ".backup-*")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, os.Remove(tmp.Name()))
+		}
+	}()
+
+	if err = errors.Join(write(tmp), tmp.Close()); err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmp.Name(), opts.output); err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(cmd.Root().Writer, "backup saved to %s\n", opts.output)
+
+	return err
+}
+
+// snapshot makes backups of the database opened by the command
+type snapshot struct {
+	db *bolt.DB
+}
+
+func (s snapshot) Backup(w io.Writer) (int64, error) {
+	var n int64
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+
+		n, err = tx.WriteTo(w)
+
+		return err
+	})
+
+	return n, err
+}
+
+func (a *Admin) localBackup(w io.Writer, compress bool) (err error) {
+	db, err := a.openDB(true)
+	if err != nil {
+		return err
+	}
+
+	defer func() { err = errors.Join(err, db.Close()) }()
+
+	return gopay.NewBackupManager(snapshot{db: db}, nil, gopay.BackupConfig{}).WriteBackup(w, compress)
+}
+
+func remoteBackup(ctx context.Context, w io.Writer, opts backupOptions) error {
+	baseURL, err := url.ParseRequestURI(opts.serverURL)
+	if err != nil {
+		return err
+	}
+
+	backupURL := baseURL.JoinPath("api", "admin", "backup")
+	backupURL.RawQuery = url.Values{"compress": {strconv.FormatBool(opts.compress)}}.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, backupURL.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+opts.adminToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+		return fmt.Errorf("error response from API %s: %s", resp.Status, body)
+	}
+
+	_, err = io.Copy(w, resp.Body)
+
+	return err
+}
@@ -0,0 +1,85 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/urfave/cli/v3"
+	bolt "go.etcd.io/bbolt"
+)
+
+// compactTxMaxSize limits the size of a single transaction used for copying, so large databases
+// are compacted without holding all the data in memory
+const compactTxMaxSize = 64 << 20
+
+func newCompactCmd(admin *Admin) *cli.Command {
+	return &cli.Command{
+		Name:  "compact",
+		Usage: "Rewrite database to release space left after deleted data",
+		Description: "The API must be stopped, bolt never shrinks the database file, " +
+			"so the command is meant to be run periodically, e.g. by cron",
+		UsageText: "gopay compact",
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			if err := admin.Compact(cmd); err != nil {
+				return fmt.Errorf("Admin.Compact: %w", err)
+			}
+
+			return nil
+		},
+	}
+}
+
+func (a *Admin) Compact(cmd *cli.Command) (err error) {
+	before, err := os.Stat(a.DBFilePath)
+	if err != nil {
+		return err
+	}
+
+	src, err := a.openDB(false)
+	if err != nil {
+		return err
+	}
+
+	defer func() { err = errors.Join(err, src.Close()) }()
+
+	tmp, err := os.CreateTemp(filepath.Dir(a.DBFilePath), ".compact-*")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, os.Remove(tmp.Name()))
+		}
+	}()
+
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	dst, err := bolt.Open(tmp.Name(), 0600, &bolt.Options{Timeout: a.DBOpenTimeout})
+	if err != nil {
+		return err
+	}
+
+	if err = errors.Join(bolt.Compact(dst, src, compactTxMaxSize), dst.Close()); err != nil {
+		return err
+	}
+
+	after, err := os.Stat(tmp.Name())
+	if err != nil {
+		return err
+	}
+
+	// the source stays locked until it is replaced
+	if err = os.Rename(tmp.Name(), a.DBFilePath); err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(cmd.Root().Writer, "database compacted: %d -> %d bytes\n", before.Size(), after.Size())
+
+	return err
+}
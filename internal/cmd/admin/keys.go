@@ -0,0 +1,156 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+func newKeysCmd(admin *Admin) *cli.Command {
+	var (
+		name          string
+		rolesOrScopes []string
+	)
+
+	return &cli.Command{
+		Name:        "keys",
+		Usage:       "Manage API keys for admin routes",
+		Description: "The API must be stopped, keys are stored hashed, so a key is shown only once when it is created",
+		UsageText:   "gopay keys <command>",
+		Commands: []*cli.Command{
+			{
+				Name:      "create",
+				Usage:     "Create API key and print it",
+				UsageText: "gopay keys create --name <name> --role <role|scope>[,<role|scope>]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:        "name",
+						Usage:       "Name of the key owner, e.g. bot",
+						Required:    true,
+						Destination: &name,
+					},
+					&cli.StringSliceFlag{
+						Name: "role",
+						Usage: "Roles viewer, support, editor, admin or scopes payments:read, payments:write, files, admin " +
+							"allowed to the key",
+						Required:    true,
+						Destination: &rolesOrScopes,
+					},
+				},
+				Action: func(_ context.Context, cmd *cli.Command) error {
+					if err := admin.CreateKey(cmd, name, rolesOrScopes); err != nil {
+						return fmt.Errorf("Admin.CreateKey: %w", err)
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:      "list",
+				Usage:     "List API keys",
+				UsageText: "gopay keys list",
+				Action: func(_ context.Context, cmd *cli.Command) error {
+					if err := admin.ListKeys(cmd); err != nil {
+						return fmt.Errorf("Admin.ListKeys: %w", err)
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:      "revoke",
+				Usage:     "Delete API key",
+				UsageText: "gopay keys revoke <id>",
+				Action: func(_ context.Context, cmd *cli.Command) error {
+					if cmd.Args().Len() != 1 {
+						return errors.New("Admin.RevokeKey: expected key ID")
+					}
+
+					if err := admin.RevokeKey(cmd, cmd.Args().First()); err != nil {
+						return fmt.Errorf("Admin.RevokeKey: %w", err)
+					}
+
+					return nil
+				},
+			},
+		},
+	}
+}
+
+func (a *Admin) CreateKey(cmd *cli.Command, name string, rolesOrScopes []string) error {
+	return a.withKeyStorage(func(repo keyStorage) error {
+		apiKey, key, err := gopay.NewAPIKeyManager(repo).CreateKey(name, rolesOrScopes)
+		if err != nil {
+			return err
+		}
+
+		// the key is printed first, so it is not lost if the action is not recorded
+		_, err = fmt.Fprintf(cmd.Root().Writer, "created key %s for %s, it is not shown again:\n%s\n", apiKey.ID, name, key)
+		if err != nil {
+			return err
+		}
+
+		apiKey.SecretHash = ""
+
+		return a.audit(repo, gopay.AuditAPIKeyCreate, "api_key:"+apiKey.ID, nil, apiKey)
+	})
+}
+
+func (a *Admin) ListKeys(cmd *cli.Command) error {
+	return a.withKeyStorage(func(repo keyStorage) error {
+		keys, err := gopay.NewAPIKeyManager(repo).ListKeys()
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(cmd.Root().Writer, 0, 0, 2, ' ', 0)
+
+		if _, err = fmt.Fprintln(w, "ID\tNAME\tSCOPES\tCREATED"); err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			scopes := make([]string, len(key.Scopes))
+			for i, scope := range key.Scopes {
+				scopes[i] = string(scope)
+			}
+
+			if _, err = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+				key.ID, key.Name, strings.Join(scopes, ","), key.CreatedAt.Format(time.RFC3339)); err != nil {
+				return err
+			}
+		}
+
+		return w.Flush()
+	})
+}
+
+func (a *Admin) RevokeKey(cmd *cli.Command, id string) error {
+	return a.withKeyStorage(func(repo keyStorage) error {
+		apiKey, err := repo.GetAPIKey(id)
+		if err != nil {
+			return err
+		}
+
+		if err = gopay.NewAPIKeyManager(repo).RevokeKey(id); err != nil {
+			return err
+		}
+
+		apiKey.SecretHash = ""
+
+		if err = a.audit(repo, gopay.AuditAPIKeyRevoke, "api_key:"+id, apiKey, nil); err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintf(cmd.Root().Writer, "key %s revoked\n", id)
+
+		return err
+	})
+}
@@ -0,0 +1,136 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/Anton-Kraev/gopay"
+	boltrepo "github.com/Anton-Kraev/gopay/internal/repository/bolt"
+)
+
+func newMigrateCmd(admin *Admin) *cli.Command {
+	var dryRun bool
+
+	return &cli.Command{
+		Name:      "migrate",
+		Usage:     "Upgrade database schema to the latest version",
+		UsageText: "gopay migrate [--dry-run]",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:        "dry-run",
+				Usage:       "Apply migrations and roll them back to check they succeed",
+				Destination: &dryRun,
+			},
+		},
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			if err := admin.Migrate(cmd, dryRun); err != nil {
+				return fmt.Errorf("Admin.Migrate: %w", err)
+			}
+
+			return nil
+		},
+		Commands: []*cli.Command{
+			{
+				Name:      "status",
+				Usage:     "Show database schema version and pending migrations",
+				UsageText: "gopay migrate status",
+				Action: func(_ context.Context, cmd *cli.Command) error {
+					if err := admin.MigrationStatus(cmd); err != nil {
+						return fmt.Errorf("Admin.MigrationStatus: %w", err)
+					}
+
+					return nil
+				},
+			},
+		},
+	}
+}
+
+func (a *Admin) Migrate(cmd *cli.Command, dryRun bool) (err error) {
+	db, err := a.openDB(false)
+	if err != nil {
+		return err
+	}
+
+	defer func() { err = errors.Join(err, db.Close()) }()
+
+	applied, err := boltrepo.Migrate(db, dryRun)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.Root().Writer
+
+	if len(applied) == 0 {
+		_, err = fmt.Fprintln(out, "database schema is up to date")
+
+		return err
+	}
+
+	action := "applied"
+	if dryRun {
+		action = "would apply"
+	}
+
+	for _, m := range applied {
+		if _, err = fmt.Fprintf(out, "%s %d: %s\n", action, m.Version, m.Name); err != nil {
+			return err
+		}
+	}
+
+	if dryRun {
+		return nil
+	}
+
+	repo, err := boltrepo.NewPaymentRepository(db)
+	if err != nil {
+		return err
+	}
+
+	return a.audit(repo, gopay.AuditDatabaseMigrate, "database",
+		schemaState{Version: applied[0].Version - 1},
+		schemaState{Version: applied[len(applied)-1].Version},
+	)
+}
+
+// schemaState is the state of the database in the audit log of migrations
+type schemaState struct {
+	Version uint64 `json:"schema_version"`
+}
+
+func (a *Admin) MigrationStatus(cmd *cli.Command) (err error) {
+	db, err := a.openDB(true)
+	if err != nil {
+		return err
+	}
+
+	defer func() { err = errors.Join(err, db.Close()) }()
+
+	version, pending, err := boltrepo.MigrationStatus(db)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.Root().Writer
+
+	if _, err = fmt.Fprintf(out, "schema version: %d\n", version); err != nil {
+		return err
+	}
+
+	if len(pending) == 0 {
+		_, err = fmt.Fprintln(out, "no pending migrations")
+
+		return err
+	}
+
+	for _, m := range pending {
+		if _, err = fmt.Fprintf(out, "pending %d: %s\n", m.Version, m.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
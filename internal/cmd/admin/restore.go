@@ -0,0 +1,156 @@
+package admin
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/urfave/cli/v3"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/Anton-Kraev/gopay"
+	boltrepo "github.com/Anton-Kraev/gopay/internal/repository/bolt"
+)
+
+// gzipMagic starts every gzip stream, so compressed backups are detected without relying on the file name
+var gzipMagic = []byte{0x1f, 0x8b}
+
+func newRestoreCmd(admin *Admin) *cli.Command {
+	var input string
+
+	return &cli.Command{
+		Name:  "restore",
+		Usage: "Replace database with the backup",
+		Description: "The API must be stopped, the backup is checked before replacing the database, " +
+			"the replaced database is kept with .bak suffix",
+		UsageText: "gopay restore --input <path>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "input",
+				Aliases:     []string{"i"},
+				Usage:       "Backup file path, compressed backups are detected automatically",
+				Required:    true,
+				Destination: &input,
+			},
+		},
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			if err := admin.Restore(cmd, input); err != nil {
+				return fmt.Errorf("Admin.Restore: %w", err)
+			}
+
+			return nil
+		},
+	}
+}
+
+func (a *Admin) Restore(cmd *cli.Command, input string) error {
+	if err := a.requireBolt(); err != nil {
+		return err
+	}
+
+	if err := a.restoreDB(input); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(cmd.Root().Writer, "database %s restored from %s\n", a.DBFilePath, input); err != nil {
+		return err
+	}
+
+	// the restored database keeps the audit log of the backup, the restore is appended to it,
+	// migrations which the API would apply on start are applied before
+	return a.withRepo(func(repo boltrepo.PaymentRepository) error {
+		return a.audit(repo, gopay.AuditDatabaseRestore, "database", nil, map[string]string{"backup": input})
+	})
+}
+
+// restoreDB replaces the database with the checked backup
+func (a *Admin) restoreDB(input string) (err error) {
+	backup, err := os.Open(input)
+	if err != nil {
+		return err
+	}
+
+	defer func() { err = errors.Join(err, backup.Close()) }()
+
+	tmp, err := os.CreateTemp(filepath.Dir(a.DBFilePath), ".restore-*")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, os.Remove(tmp.Name()))
+		}
+	}()
+
+	if err = errors.Join(decompress(tmp, backup), tmp.Close()); err != nil {
+		return err
+	}
+
+	if err = checkBackup(tmp.Name(), a.DBOpenTimeout); err != nil {
+		return fmt.Errorf("invalid backup: %w", err)
+	}
+
+	return a.replaceDB(tmp.Name())
+}
+
+func decompress(w io.Writer, r io.Reader) error {
+	br := bufio.NewReader(r)
+
+	if magic, _ := br.Peek(len(gzipMagic)); !bytes.Equal(magic, gzipMagic) {
+		_, err := io.Copy(w, br)
+
+		return err
+	}
+
+	gz, err := gzip.NewReader(br)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(w, gz); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
+
+// checkBackup makes sure the backup is a bolt database with schema supported by this version
+func checkBackup(path string, timeout time.Duration) error {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: timeout, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+
+	_, _, err = boltrepo.MigrationStatus(db)
+
+	return errors.Join(err, db.Close())
+}
+
+// replaceDB moves the file to the database path, the database is locked while it is replaced,
+// so it fails if the API is still running
+func (a *Admin) replaceDB(path string) (err error) {
+	if _, err = os.Stat(a.DBFilePath); errors.Is(err, os.ErrNotExist) {
+		return os.Rename(path, a.DBFilePath)
+	}
+
+	db, err := a.openDB(false)
+	if err != nil {
+		return fmt.Errorf("database is in use: %w", err)
+	}
+
+	defer func() { err = errors.Join(err, db.Close()) }()
+
+	if err = os.Rename(a.DBFilePath, a.DBFilePath+".bak"); err != nil {
+		return err
+	}
+
+	return os.Rename(path, a.DBFilePath)
+}
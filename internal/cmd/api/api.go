@@ -2,20 +2,28 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"time"
 
-	bolt "go.etcd.io/bbolt"
+	"golang.org/x/time/rate"
 
 	"github.com/Anton-Kraev/gopay"
 	"github.com/Anton-Kraev/gopay/internal/client/minio"
+	"github.com/Anton-Kraev/gopay/internal/client/smtp"
 	"github.com/Anton-Kraev/gopay/internal/client/yookassa"
 	"github.com/Anton-Kraev/gopay/internal/http/handler"
+	"github.com/Anton-Kraev/gopay/internal/http/handlerv2"
+	"github.com/Anton-Kraev/gopay/internal/http/ratelimit"
 	"github.com/Anton-Kraev/gopay/internal/http/server"
 	"github.com/Anton-Kraev/gopay/internal/links"
 	"github.com/Anton-Kraev/gopay/internal/logger"
-	repo "github.com/Anton-Kraev/gopay/internal/repository/bolt"
+	"github.com/Anton-Kraev/gopay/internal/notify"
+	"github.com/Anton-Kraev/gopay/internal/repository/cache"
+	"github.com/Anton-Kraev/gopay/internal/token"
+	"github.com/Anton-Kraev/gopay/internal/typeconv"
 	"github.com/Anton-Kraev/gopay/internal/validator"
 )
 
@@ -23,8 +31,13 @@ type API struct {
 	Env                 string
 	GopayHost           string
 	GopayPort           string
+	DBDriver            string
 	DBFilePath          string
 	DBOpenTimeout       time.Duration
+	DBDSN               string
+	DBMaxOpenConns      int
+	DBMaxIdleConns      int
+	DBConnMaxLifetime   time.Duration
 	YookassaCheckoutURL string
 	YookassaShopID      string
 	YookassaAPIToken    string
@@ -32,31 +45,66 @@ type API struct {
 	MinioURL            string
 	MinioUser           string
 	MinioPassword       string
+	SMTPHost            string
+	SMTPPort            string
+	SMTPUser            string
+	SMTPPassword        string
+	SMTPFrom            string
+	SMTPRetries         int
+	SMTPRetryDelay      time.Duration
+	MailTemplatesDir    string
+	TGBotToken          string
+	TokenSecret         string
+	ReminderDelay       time.Duration
+	ReminderMax         uint
+	ReminderInterval    time.Duration
+	UnsubscribeTTL      time.Duration
+	RecoverCooldown     time.Duration
+	MagicLinkTTL        time.Duration
+	SessionTTL          time.Duration
+	AdminToken          string
+	BackupInterval      time.Duration
+	BackupCompress      bool
+	CacheSize           int
+	CacheTTL            time.Duration
+	PaymentTTL          time.Duration
+	IdempotencyTTL      time.Duration
+	RateLimit           float64
+	RateBurst           int
+	FileRateLimit       float64
+	FileRateBurst       int
+	WebhookAllowlist    string
+	TrustedProxies      string
+}
+
+// LogValue hides secrets of the config in logs, DSN is hidden as it may contain the database password
+func (a *API) LogValue() slog.Value {
+	redacted := *a
+	redacted.DBDSN = logger.Redact(a.DBDSN)
+	redacted.YookassaAPIToken = logger.Redact(a.YookassaAPIToken)
+	redacted.MinioPassword = logger.Redact(a.MinioPassword)
+	redacted.SMTPPassword = logger.Redact(a.SMTPPassword)
+	redacted.TGBotToken = logger.Redact(a.TGBotToken)
+	redacted.TokenSecret = logger.Redact(a.TokenSecret)
+	redacted.AdminToken = logger.Redact(a.AdminToken)
+
+	return slog.AnyValue(redacted)
 }
 
 func (a *API) Start(ctx context.Context) error {
 	log := logger.Setup(a.Env)
 	log.Info("Config parsed", slog.Any("config", a))
 
-	db, err := bolt.Open(
-		a.DBFilePath,
-		0600,
-		&bolt.Options{Timeout: a.DBOpenTimeout},
-	)
+	paymentStorage, closeStorage, err := a.openStorage(ctx)
 	if err != nil {
 		return err
 	}
 
-	defer func(db *bolt.DB) {
-		if err = db.Close(); err != nil {
+	defer func() {
+		if err := closeStorage(); err != nil {
 			log.Error(err.Error())
 		}
-	}(db)
-
-	paymentStorage, err := repo.NewPaymentRepository(db)
-	if err != nil {
-		return err
-	}
+	}()
 
 	paymentService := yookassa.NewClient(yookassa.Config{
 		CheckoutURL: a.YookassaCheckoutURL,
@@ -64,13 +112,13 @@ func (a *API) Start(ctx context.Context) error {
 		APIToken:    a.YookassaAPIToken,
 	})
 
-	linkGenerator := links.NewGenerator(fmt.Sprintf("%s:%s", a.GopayHost, a.GopayPort))
+	// statuses and links are read on every status check and redirect, only these reads are cached
+	var storage gopay.Storage = paymentStorage
+	if a.CacheSize > 0 {
+		storage = cache.NewStorage(paymentStorage, cache.Config{Size: a.CacheSize, TTL: a.CacheTTL})
+	}
 
-	pm := gopay.NewPaymentManager(
-		linkGenerator,
-		paymentStorage,
-		paymentService,
-	)
+	linkGenerator := links.NewGenerator(fmt.Sprintf("%s:%s", a.GopayHost, a.GopayPort))
 
 	fileStorage, err := minio.NewClient(ctx, minio.Config{
 		BucketName: a.MinioBucketName,
@@ -82,15 +130,170 @@ func (a *API) Start(ctx context.Context) error {
 		return err
 	}
 
-	hndl := handler.NewHandler(pm, fileStorage)
+	mailTemplates, err := notify.NewTemplates(a.MailTemplatesDir)
+	if err != nil {
+		return err
+	}
+
+	var (
+		pmOpts        []gopay.Option
+		emailNotifier *notify.Email
+		rm            *gopay.ReminderManager
+		cm            *gopay.CustomerManager
+		bm            *gopay.BackupManager
+	)
+
+	auditStorage, ok := paymentStorage.(gopay.AuditStorage)
+	if !ok {
+		return fmt.Errorf("audit log is not supported by %s driver", a.DBDriver)
+	}
+
+	al := gopay.NewAuditLog(auditStorage)
+
+	// storage transactions append audit entries of payment changes together with the changes
+	pmOpts = append(pmOpts, gopay.WithPaymentTTL(a.PaymentTTL), gopay.WithAuditLog(al))
+
+	if a.IdempotencyTTL > 0 {
+		keyStorage, ok := paymentStorage.(gopay.IdempotencyStorage)
+		if !ok {
+			return fmt.Errorf("idempotency keys are not supported by %s driver", a.DBDriver)
+		}
+
+		pmOpts = append(pmOpts, gopay.WithIdempotency(keyStorage, a.IdempotencyTTL))
+	}
+
+	notifiers := make(gopay.Notifiers)
+
+	if a.SMTPHost != "" {
+		email := notify.NewEmail(
+			smtp.NewClient(smtp.Config{
+				Host:     a.SMTPHost,
+				Port:     a.SMTPPort,
+				User:     a.SMTPUser,
+				Password: a.SMTPPassword,
+				From:     a.SMTPFrom,
+			}),
+			mailTemplates,
+			notify.Config{
+				Retries:    a.SMTPRetries,
+				RetryDelay: a.SMTPRetryDelay,
+			},
+		)
+
+		emailNotifier = &email
+		notifiers[gopay.NotifyChannelEmail] = email
+		pmOpts = append(pmOpts, gopay.WithNotifier(email))
+	}
+
+	signer := token.NewSigner(a.TokenSecret)
+
+	if emailNotifier != nil {
+		// links sent to customers by email are signed
+		if a.TokenSecret == "" {
+			return errors.New("email delivery requires token secret")
+		}
+
+		cm = gopay.NewCustomerManager(storage, signer, linkGenerator, emailNotifier, gopay.CustomerConfig{
+			Cooldown:   a.RecoverCooldown,
+			LoginTTL:   a.MagicLinkTTL,
+			SessionTTL: a.SessionTTL,
+		})
+	}
+
+	if a.TGBotToken != "" {
+		tgNotifier, err := notify.NewTelegram(a.TGBotToken, mailTemplates)
+		if err != nil {
+			return err
+		}
+
+		notifiers[gopay.NotifyChannelTelegram] = tgNotifier
+	}
+
+	pm := gopay.NewPaymentManager(
+		linkGenerator,
+		storage,
+		paymentService,
+		pmOpts...,
+	)
+
+	fm := gopay.NewFileManager(fileStorage, storage, linkGenerator, notifiers)
+
+	if a.ReminderDelay > 0 {
+		if emailNotifier == nil {
+			return errors.New("payment reminders require SMTP server")
+		}
+
+		rm = gopay.NewReminderManager(
+			storage,
+			signer,
+			linkGenerator,
+			emailNotifier,
+			gopay.ReminderConfig{
+				Delay:          a.ReminderDelay,
+				MaxReminders:   a.ReminderMax,
+				UnsubscribeTTL: a.UnsubscribeTTL,
+			},
+		)
+
+		go rm.Start(ctx, a.ReminderInterval)
+	}
+
+	// only storages which can make snapshots while running support backups
+	if backupStorage, ok := paymentStorage.(interface {
+		Backup(w io.Writer) (int64, error)
+	}); ok {
+		bm = gopay.NewBackupManager(backupStorage, fileStorage, gopay.BackupConfig{Compress: a.BackupCompress})
+	}
+
+	if a.BackupInterval > 0 {
+		if bm == nil {
+			return fmt.Errorf("scheduled backups are not supported by %s driver", a.DBDriver)
+		}
+
+		go bm.Start(ctx, a.BackupInterval)
+	}
+
+	keyStorage, ok := paymentStorage.(gopay.APIKeyStorage)
+	if !ok {
+		return fmt.Errorf("API keys are not supported by %s driver", a.DBDriver)
+	}
+
+	apiKeys := gopay.NewAPIKeyManager(keyStorage)
+
+	hndl := handler.NewHandler(pm, fm, rm, cm, bm, al, mailTemplates)
+	hndlV2 := handlerv2.NewHandler(pm, fm, cm, al)
 
 	val, err := validator.NewValidator()
 	if err != nil {
 		return err
 	}
 
-	srv := server.NewServer(hndl, log, val)
+	limits, err := a.rateLimits()
+	if err != nil {
+		return err
+	}
+
+	srv := server.NewServer(hndl, hndlV2, log, val, a.AdminToken, apiKeys, al, limits)
 	echoSrv := srv.InitRoutes()
 
 	return echoSrv.Start(":" + a.GopayPort)
 }
+
+func (a *API) rateLimits() (server.RateLimits, error) {
+	webhookAllowlist, err := typeconv.StringToIPNets(a.WebhookAllowlist)
+	if err != nil {
+		return server.RateLimits{}, fmt.Errorf("invalid webhook allowlist: %w", err)
+	}
+
+	trustedProxies, err := typeconv.StringToIPNets(a.TrustedProxies)
+	if err != nil {
+		return server.RateLimits{}, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+
+	return server.RateLimits{
+		IP:               ratelimit.Limit{Rate: rate.Limit(a.RateLimit), Burst: a.RateBurst},
+		File:             ratelimit.Limit{Rate: rate.Limit(a.FileRateLimit), Burst: a.FileRateBurst},
+		WebhookAllowlist: webhookAllowlist,
+		TrustedProxies:   trustedProxies,
+	}, nil
+}
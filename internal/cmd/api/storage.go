@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	_ "github.com/jackc/pgx/v5/stdlib" // registers pgx driver for database/sql
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/Anton-Kraev/gopay"
+	boltrepo "github.com/Anton-Kraev/gopay/internal/repository/bolt"
+	"github.com/Anton-Kraev/gopay/internal/repository/postgres"
+	"github.com/Anton-Kraev/gopay/internal/repository/sqlite"
+)
+
+const (
+	driverBolt     = "bolt"
+	driverPostgres = "postgres"
+	driverSQLite   = "sqlite"
+)
+
+// openStorage connects to the database selected by driver flag, returned function closes the connection
+func (a *API) openStorage(ctx context.Context) (gopay.Storage, func() error, error) {
+	switch a.DBDriver {
+	case driverBolt:
+		db, err := bolt.Open(a.DBFilePath, 0600, &bolt.Options{Timeout: a.DBOpenTimeout})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		repo, err := boltrepo.NewPaymentRepository(db)
+		if err != nil {
+			return nil, nil, errors.Join(err, db.Close())
+		}
+
+		return repo, db.Close, nil
+	case driverPostgres:
+		db, err := sql.Open("pgx", a.DBDSN)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		db.SetMaxOpenConns(a.DBMaxOpenConns)
+		db.SetMaxIdleConns(a.DBMaxIdleConns)
+		db.SetConnMaxLifetime(a.DBConnMaxLifetime)
+
+		openCtx, cancel := context.WithTimeout(ctx, a.DBOpenTimeout)
+		defer cancel()
+
+		if err = db.PingContext(openCtx); err != nil {
+			return nil, nil, errors.Join(err, db.Close())
+		}
+
+		repo, err := postgres.NewPaymentRepository(openCtx, db)
+		if err != nil {
+			return nil, nil, errors.Join(err, db.Close())
+		}
+
+		return repo, db.Close, nil
+	case driverSQLite:
+		db, err := sqlite.Open(a.DBFilePath, a.DBOpenTimeout)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		repo, err := sqlite.NewPaymentRepository(ctx, db)
+		if err != nil {
+			return nil, nil, errors.Join(err, db.Close())
+		}
+
+		return repo, db.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown database driver %s", a.DBDriver)
+	}
+}
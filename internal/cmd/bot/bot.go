@@ -2,7 +2,11 @@ package bot
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/Anton-Kraev/gopay"
 	"github.com/Anton-Kraev/gopay/internal/logger"
@@ -13,8 +17,19 @@ import (
 type Bot struct {
 	Env            string
 	GopayServerURL string
+	GopayAPIKey    string
 	TGBotToken     string
 	TGAdminIDs     string
+	TGAdminRoles   string
+}
+
+// LogValue hides secrets of the config in logs
+func (b *Bot) LogValue() slog.Value {
+	redacted := *b
+	redacted.GopayAPIKey = logger.Redact(b.GopayAPIKey)
+	redacted.TGBotToken = logger.Redact(b.TGBotToken)
+
+	return slog.AnyValue(redacted)
 }
 
 func (b *Bot) Start(ctx context.Context) error {
@@ -26,14 +41,20 @@ func (b *Bot) Start(ctx context.Context) error {
 		return err
 	}
 
-	adminClient, err := gopay.NewAdminClient(b.GopayServerURL)
+	adminScopes, err := parseAdminRoles(b.TGAdminRoles, adminIDs)
+	if err != nil {
+		return err
+	}
+
+	adminClient, err := gopay.NewAdminClient(b.GopayServerURL, gopay.WithAPIKey(b.GopayAPIKey))
 	if err != nil {
 		return err
 	}
 
 	tg, err := telegram.New(telegram.Config{
-		BotToken: b.TGBotToken,
-		AdminIDs: adminIDs,
+		BotToken:    b.TGBotToken,
+		AdminIDs:    adminIDs,
+		AdminScopes: adminScopes,
 	}, adminClient, logger.Setup(b.Env))
 	if err != nil {
 		return err
@@ -43,3 +64,44 @@ func (b *Bot) Start(ctx context.Context) error {
 
 	return tg.Start(ctx)
 }
+
+// parseAdminRoles parses roles or scopes of admins in format <id1>:<role>,<id2>:<scope>,
+// an admin may be listed several times
+func parseAdminRoles(str string, adminIDs []int64) (map[int64][]gopay.Scope, error) {
+	if strings.TrimSpace(str) == "" {
+		return nil, nil
+	}
+
+	rolesOrScopes := make(map[int64][]string)
+
+	for _, pair := range strings.Split(str, ",") {
+		id, role, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok {
+			return nil, fmt.Errorf("bot.parseAdminRoles: expected <id>:<role>, got %q", pair)
+		}
+
+		adminID, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bot.parseAdminRoles: %w", err)
+		}
+
+		if !slices.Contains(adminIDs, adminID) {
+			return nil, fmt.Errorf("bot.parseAdminRoles: %d is not an admin", adminID)
+		}
+
+		rolesOrScopes[adminID] = append(rolesOrScopes[adminID], role)
+	}
+
+	scopes := make(map[int64][]gopay.Scope, len(rolesOrScopes))
+
+	for adminID, values := range rolesOrScopes {
+		adminScopes, err := gopay.ParseScopes(values)
+		if err != nil {
+			return nil, fmt.Errorf("bot.parseAdminRoles: %w", err)
+		}
+
+		scopes[adminID] = adminScopes
+	}
+
+	return scopes, nil
+}
@@ -14,7 +14,7 @@ func NewBotCmd() *cli.Command {
 		Name:        "bot",
 		Usage:       "Run GoPay Telegram Bot",
 		Description: "GoPay Telegram Bot",
-		UsageText:   "bot --tg-bot-token <token> --tg-admin-ids <id1>,<id2>",
+		UsageText:   "bot --gopay-api-key <key> --tg-bot-token <token> --tg-admin-ids <id1>,<id2>",
 		Action: func(ctx context.Context, _ *cli.Command) error {
 			if err := bot.Start(ctx); err != nil {
 				return fmt.Errorf("Bot.Start: %w", err)
@@ -37,6 +37,13 @@ func NewBotCmd() *cli.Command {
 				Sources:     cli.EnvVars("GOPAY_SERVER_URL"),
 				Destination: &bot.GopayServerURL,
 			},
+			&cli.StringFlag{
+				Name:        "gopay-api-key",
+				Usage:       "API key for admin routes of GoPay server, created with gopay keys create",
+				Required:    true,
+				Sources:     cli.EnvVars("GOPAY_API_KEY"),
+				Destination: &bot.GopayAPIKey,
+			},
 			&cli.StringFlag{
 				Name:        "tg-bot-token",
 				Usage:       "Token for Telegram bot API",
@@ -51,6 +58,13 @@ func NewBotCmd() *cli.Command {
 				Sources:     cli.EnvVars("TG_ADMIN_IDS"),
 				Destination: &bot.TGAdminIDs,
 			},
+			&cli.StringFlag{
+				Name: "tg-admin-roles",
+				Usage: "Roles or scopes of admins in format <id1>:<role>,<id2>:<scope>, " +
+					"admins without roles may use all commands allowed to the API key",
+				Sources:     cli.EnvVars("TG_ADMIN_ROLES"),
+				Destination: &bot.TGAdminRoles,
+			},
 		},
 	}
 
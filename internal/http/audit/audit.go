@@ -0,0 +1,41 @@
+// Package audit passes the actor of the request from the auth middleware to handlers,
+// which record their actions in the audit log
+package audit
+
+import (
+	"log/slog"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+const actorContextKey = "audit_actor"
+
+// SetActor is called by the auth middleware for requests to admin routes
+func SetActor(c echo.Context, actor gopay.Actor) {
+	c.Set(actorContextKey, actor)
+}
+
+// ActorOf returns the actor of the request, it is empty for public routes
+func ActorOf(c echo.Context) gopay.Actor {
+	actor, _ := c.Get(actorContextKey).(gopay.Actor)
+
+	return actor
+}
+
+// Record appends the action of the request actor to the log if the log is enabled, errors are only logged,
+// because the action is already done
+func Record(c echo.Context, log *gopay.AuditLog, action gopay.AuditAction, target string, before, after any) {
+	if log == nil {
+		return
+	}
+
+	if _, err := log.Record(ActorOf(c), action, target, before, after); err != nil {
+		slog.Default().Error(err.Error(),
+			slog.String("op", "audit.Record"),
+			slog.String("action", string(action)),
+			slog.String("request_id", c.Response().Header().Get(echo.HeaderXRequestID)),
+		)
+	}
+}
@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/Anton-Kraev/gopay"
+	"github.com/Anton-Kraev/gopay/internal/http/audit"
+	"github.com/Anton-Kraev/gopay/internal/http/problem"
+)
+
+type auditLogRequest struct {
+	Actor  string            `query:"actor"`
+	Action gopay.AuditAction `query:"action"`
+	Target string            `query:"target"`
+	From   time.Time         `query:"from"`
+	To     time.Time         `query:"to"`
+	Limit  int               `query:"limit" validate:"omitempty,min=1,max=500"`
+	Cursor string            `query:"cursor"`
+}
+
+// AuditLog gets audit log page
+// @Summary Get audit log
+// @Description Get page of mutating admin actions matching filters, newest actions go first,
+// @Description pass next_cursor from the response as cursor to get next page
+// @Tags admin
+// @Produce json
+// @Param actor query string false "Actor or API key it acted through, e.g. telegram:123 or api_key:<id>"
+// @Param action query string false "Action, e.g. payment.create"
+// @Param target query string false "Target, e.g. payment:<id>"
+// @Param from query string false "Made at or after, RFC 3339"
+// @Param to query string false "Made before, RFC 3339"
+// @Param limit query int false "Page size" minimum(1) maximum(500) default(50)
+// @Param cursor query string false "Cursor of the page"
+// @Security APIKey
+// @Success 200 {object} gopay.AuditPage
+// @Failure 400 {object} problem.Problem "Invalid request"
+// @Failure 401 {object} problem.Problem "Missing or invalid API key"
+// @Failure 403 {object} problem.Problem "API key has no required scope"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Failure 501 {object} problem.Problem "Audit log is not supported by the storage"
+// @Router /audit [get]
+func (h Handler) AuditLog(c echo.Context) error {
+	log := slog.Default().With(
+		slog.String("op", "Handler.AuditLog"),
+		slog.String("request_id", c.Response().Header().Get(echo.HeaderXRequestID)),
+	)
+
+	if h.auditLog == nil {
+		log.Error("audit log is not supported by the storage")
+
+		return problem.New(http.StatusNotImplemented, "audit log is not supported by the storage")
+	}
+
+	var req auditLogRequest
+	if err := c.Bind(&req); err != nil {
+		log.Error(err.Error())
+
+		return problem.New(http.StatusBadRequest, "invalid request")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		log.Error(err.Error())
+
+		return problem.Validation(err)
+	}
+
+	page, err := h.auditLog.List(gopay.AuditFilter(req))
+	if errors.Is(err, gopay.ErrInvalidCursor) {
+		log.Error(err.Error())
+
+		return problem.New(http.StatusBadRequest, "invalid cursor")
+	}
+
+	if err != nil {
+		log.Error(err.Error())
+
+		return problem.New(http.StatusInternalServerError, "get audit log failed")
+	}
+
+	log.Info("success get audit log")
+
+	return c.JSON(http.StatusOK, page)
+}
+
+type auditDenialRequest struct {
+	Command string      `json:"command" validate:"required,max=64"`
+	Scope   gopay.Scope `json:"scope" validate:"required"`
+}
+
+// RecordDenial records access denial reported by the client
+// @Summary Record access denial
+// @Description Record command denied by the client to the person it acts on behalf of, e.g. the bot admin
+// @Description without the role, the entry is recorded with the actor of the request
+// @Tags admin
+// @Accept json
+// @Param denial body auditDenialRequest true "Denied command and missing scope"
+// @Security APIKey
+// @Success 204
+// @Failure 400 {object} problem.Problem "Invalid request"
+// @Failure 401 {object} problem.Problem "Missing or invalid API key"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Failure 501 {object} problem.Problem "Audit log is not supported by the storage"
+// @Router /audit/denials [post]
+func (h Handler) RecordDenial(c echo.Context) error {
+	log := slog.Default().With(
+		slog.String("op", "Handler.RecordDenial"),
+		slog.String("request_id", c.Response().Header().Get(echo.HeaderXRequestID)),
+	)
+
+	if h.auditLog == nil {
+		log.Error("audit log is not supported by the storage")
+
+		return problem.New(http.StatusNotImplemented, "audit log is not supported by the storage")
+	}
+
+	var req auditDenialRequest
+	if err := c.Bind(&req); err != nil {
+		log.Error(err.Error())
+
+		return problem.New(http.StatusBadRequest, "invalid request")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		log.Error(err.Error())
+
+		return problem.Validation(err)
+	}
+
+	if _, err := h.auditLog.Record(
+		audit.ActorOf(c), gopay.AuditAccessDenied, "command:"+req.Command, nil, map[string]string{"scope": string(req.Scope)},
+	); err != nil {
+		log.Error(err.Error())
+
+		return problem.New(http.StatusInternalServerError, "record access denial failed")
+	}
+
+	log.Info("success record access denial")
+
+	return c.NoContent(http.StatusNoContent)
+}
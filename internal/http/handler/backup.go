@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/Anton-Kraev/gopay"
+	"github.com/Anton-Kraev/gopay/internal/http/problem"
+)
+
+// Backup streams database snapshot
+// @Summary Download database backup
+// @Description Stream consistent snapshot of the database made in a read transaction while the service is running
+// @Tags admin
+// @Produce application/octet-stream
+// @Param compress query bool false "Compress snapshot with gzip"
+// @Security APIKey
+// @Success 200 {file} binary "Database snapshot"
+// @Failure 400 {object} problem.Problem "Invalid request"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "API key has no required scope"
+// @Failure 501 {object} problem.Problem "Backups are not supported by the storage"
+// @Router /admin/backup [get]
+func (h Handler) Backup(c echo.Context) error {
+	log := slog.Default().With(
+		slog.String("op", "Handler.Backup"),
+		slog.String("request_id", c.Response().Header().Get(echo.HeaderXRequestID)),
+	)
+
+	if h.backupManager == nil {
+		log.Error("backups are not supported by the storage")
+
+		return problem.New(http.StatusNotImplemented, "backups are not supported by the storage")
+	}
+
+	var compress bool
+
+	if param := c.QueryParam("compress"); param != "" {
+		var err error
+
+		if compress, err = strconv.ParseBool(param); err != nil {
+			log.Error("invalid request: bad compress")
+
+			return problem.New(http.StatusBadRequest, "invalid request: bad compress")
+		}
+	}
+
+	name := gopay.BackupName(time.Now(), compress)
+
+	c.Response().Header().Set(echo.HeaderContentType, echo.MIMEOctetStream)
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", name))
+	c.Response().WriteHeader(http.StatusOK)
+
+	// the status is already sent, so the failure is visible to the client only as truncated body
+	if err := h.backupManager.WriteBackup(c.Response(), compress); err != nil {
+		log.Error(err.Error())
+
+		return nil
+	}
+
+	log.Info("success backup sent", slog.String("name", name))
+
+	return nil
+}
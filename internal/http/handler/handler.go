@@ -1,31 +1,60 @@
 package handler
 
 import (
-	"context"
+	"errors"
+	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 
 	"github.com/Anton-Kraev/gopay"
+	"github.com/Anton-Kraev/gopay/internal/http/audit"
+	"github.com/Anton-Kraev/gopay/internal/http/problem"
+	"github.com/Anton-Kraev/gopay/internal/notify"
 )
 
-type fileStorage interface {
-	GetData(ctx context.Context, id gopay.ID) ([]byte, error)
+// templatePreviewer renders notification templates of products with sample data
+type templatePreviewer interface {
+	Preview(productID gopay.ID, name string) (notify.TemplatePreview, error)
 }
 
 type Handler struct {
-	paymentManager *gopay.PaymentManager
-	fileStorage    fileStorage
+	paymentManager  *gopay.PaymentManager
+	fileManager     *gopay.FileManager
+	reminderManager *gopay.ReminderManager // nil if reminders are disabled
+	customerManager *gopay.CustomerManager // nil if email delivery is disabled
+	backupManager   *gopay.BackupManager   // nil if storage does not support backups
+	auditLog        *gopay.AuditLog        // nil if storage does not keep the audit log
+	templates       templatePreviewer
 }
 
-func NewHandler(paymentManager *gopay.PaymentManager, fileStorage fileStorage) Handler {
+func NewHandler(
+	paymentManager *gopay.PaymentManager,
+	fileManager *gopay.FileManager,
+	reminderManager *gopay.ReminderManager,
+	customerManager *gopay.CustomerManager,
+	backupManager *gopay.BackupManager,
+	auditLog *gopay.AuditLog,
+	templates templatePreviewer,
+) Handler {
 	return Handler{
-		paymentManager: paymentManager,
-		fileStorage:    fileStorage,
+		paymentManager:  paymentManager,
+		fileManager:     fileManager,
+		reminderManager: reminderManager,
+		customerManager: customerManager,
+		backupManager:   backupManager,
+		auditLog:        auditLog,
+		templates:       templates,
 	}
 }
 
+// headerIdempotentReplayed marks responses to retries of requests with idempotency key
+const headerIdempotentReplayed = "Idempotent-Replayed"
+
 type newPaymentRequest struct {
 	Template gopay.PaymentTemplate `json:"template" validate:"required"`
 	User     gopay.User            `json:"user" validate:"required"`
@@ -38,9 +67,17 @@ type newPaymentRequest struct {
 // @Accept json
 // @Produce plain
 // @Param request body newPaymentRequest true "Payment creation request"
+// @Param Idempotency-Key header string false "Key to retry the request without creating the payment twice"
+// @Security APIKey
 // @Success 200 {string} string "Payment link"
-// @Failure 400 {string} string "Invalid request"
-// @Failure 500 {string} string "Internal server error"
+// @Header 200 {string} Idempotent-Replayed "Set to true if the payment was created by the previous request with the key"
+// @Failure 400 {object} problem.Problem "Invalid request"
+// @Failure 401 {object} problem.Problem "Missing or invalid API key"
+// @Failure 403 {object} problem.Problem "API key has no required scope"
+// @Failure 409 {object} problem.Problem "Request with the same key is in progress"
+// @Failure 422 {object} problem.Problem "Idempotency key is used for another request"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Failure 502 {object} problem.Problem "Payment provider unavailable"
 // @Router /payments [post]
 func (h Handler) NewPayment(c echo.Context) error {
 	log := slog.Default().With(
@@ -52,25 +89,45 @@ func (h Handler) NewPayment(c echo.Context) error {
 	if err := c.Bind(&req); err != nil {
 		log.Error(err.Error())
 
-		return c.String(http.StatusBadRequest, "invalid request")
+		return problem.New(http.StatusBadRequest, "invalid request")
 	}
 
 	if err := c.Validate(&req); err != nil {
 		log.Error(err.Error())
 
-		return c.String(http.StatusBadRequest, "invalid request")
+		return problem.Validation(err)
 	}
 
-	link, err := h.paymentManager.CreatePayment(req.Template, req.User)
+	// the creation is recorded in the audit log in the transaction which saves the payment
+	details, replayed, err := h.paymentManager.As(audit.ActorOf(c)).CreatePaymentOnce(
+		c.Request().Header.Get(gopay.HeaderIdempotencyKey), req.Template, req.User,
+	)
 	if err != nil {
 		log.Error(err.Error())
 
-		return c.String(http.StatusInternalServerError, "create payment failed")
+		return problem.Failed(err, "create payment failed")
+	}
+
+	if replayed {
+		c.Response().Header().Set(headerIdempotentReplayed, "true")
 	}
 
-	log.Info("success payment created")
+	log.Info("success payment created", slog.Bool("replayed", replayed))
 
-	return c.String(http.StatusOK, string(link))
+	return c.String(http.StatusOK, string(details.Link))
+}
+
+type allPaymentRequest struct {
+	Status      gopay.Status      `query:"status" validate:"omitempty,status"`
+	Email       string            `query:"email" validate:"omitempty,email"`
+	ProductID   gopay.ID          `query:"product_id" validate:"omitempty,id"`
+	CreatedFrom time.Time         `query:"created_from"`
+	CreatedTo   time.Time         `query:"created_to"`
+	MinAmount   uint              `query:"min_amount"`
+	MaxAmount   uint              `query:"max_amount" validate:"omitempty,gtefield=MinAmount"`
+	Sort        gopay.PaymentSort `query:"sort" validate:"omitempty,oneof=created_at -created_at amount -amount"`
+	Limit       int               `query:"limit" validate:"omitempty,min=1,max=500"`
+	Cursor      string            `query:"cursor"`
 }
 
 type paymentStatus struct {
@@ -82,13 +139,29 @@ type allPaymentResponse struct {
 	Statuses []paymentStatus `json:"statuses"`
 }
 
-// AllPayment gets all payment statuses
-// @Summary Get all payment statuses
-// @Description Get statuses for all payments
+// AllPayment gets payment statuses or payments page
+// @Summary Get payments
+// @Description Get statuses of all payments matching filters. If limit or cursor is set, get page of payments instead,
+// @Description pass next_cursor from the response as cursor to get next page
 // @Tags payments
 // @Produce json
-// @Success 200 {object} allPaymentResponse
-// @Failure 500 {string} string "Internal server error"
+// @Param status query string false "Payment status"
+// @Param email query string false "Customer email"
+// @Param product_id query string false "Product ID"
+// @Param created_from query string false "Created at or after, RFC 3339"
+// @Param created_to query string false "Created before, RFC 3339"
+// @Param min_amount query int false "Minimum amount"
+// @Param max_amount query int false "Maximum amount"
+// @Param sort query string false "Sort order" Enums(created_at, -created_at, amount, -amount) default(-created_at)
+// @Param limit query int false "Page size" minimum(1) maximum(500) default(50)
+// @Param cursor query string false "Cursor of the page"
+// @Security APIKey
+// @Success 200 {object} allPaymentResponse "Statuses of payments without limit and cursor"
+// @Success 200 {object} gopay.PaymentPage "Page of payments with limit or cursor"
+// @Failure 400 {object} problem.Problem "Invalid request"
+// @Failure 401 {object} problem.Problem "Missing or invalid API key"
+// @Failure 403 {object} problem.Problem "API key has no required scope"
+// @Failure 500 {object} problem.Problem "Internal server error"
 // @Router /payments [get]
 func (h Handler) AllPayment(c echo.Context) error {
 	log := slog.Default().With(
@@ -96,11 +169,48 @@ func (h Handler) AllPayment(c echo.Context) error {
 		slog.String("request_id", c.Response().Header().Get(echo.HeaderXRequestID)),
 	)
 
-	statuses, err := h.paymentManager.GetAllPaymentsStatuses()
+	var req allPaymentRequest
+	if err := c.Bind(&req); err != nil {
+		log.Error(err.Error())
+
+		return problem.New(http.StatusBadRequest, "invalid request")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		log.Error(err.Error())
+
+		return problem.Validation(err)
+	}
+
+	// clients of the first API version get all statuses without pagination
+	if req.Limit == 0 && req.Cursor == "" {
+		return h.allPaymentStatuses(c, log, gopay.PaymentFilter(req))
+	}
+
+	page, err := h.paymentManager.ListPayments(gopay.PaymentFilter(req))
+	if errors.Is(err, gopay.ErrInvalidCursor) {
+		log.Error(err.Error())
+
+		return problem.New(http.StatusBadRequest, "invalid cursor")
+	}
+
+	if err != nil {
+		log.Error(err.Error())
+
+		return problem.New(http.StatusInternalServerError, "get payments failed")
+	}
+
+	log.Info("success get payments")
+
+	return c.JSON(http.StatusOK, page)
+}
+
+func (h Handler) allPaymentStatuses(c echo.Context, log *slog.Logger, filter gopay.PaymentFilter) error {
+	statuses, err := h.paymentManager.GetPaymentsStatuses(filter)
 	if err != nil {
 		log.Error(err.Error())
 
-		return c.String(http.StatusInternalServerError, "get payments statuses failed")
+		return problem.New(http.StatusInternalServerError, "get payments statuses failed")
 	}
 
 	log.Info("success get payments statuses")
@@ -123,9 +233,13 @@ func (h Handler) AllPayment(c echo.Context) error {
 // @Tags payments
 // @Produce plain
 // @Param id path string true "Payment ID"
+// @Security APIKey
 // @Success 200 {string} string "Payment status"
-// @Failure 400 {string} string "Invalid ID"
-// @Failure 500 {string} string "Internal server error"
+// @Failure 400 {object} problem.Problem "Invalid ID"
+// @Failure 401 {object} problem.Problem "Missing or invalid API key"
+// @Failure 403 {object} problem.Problem "API key has no required scope"
+// @Failure 404 {object} problem.Problem "Payment not found"
+// @Failure 500 {object} problem.Problem "Internal server error"
 // @Router /payments/{id} [get]
 func (h Handler) GetPayment(c echo.Context) error {
 	log := slog.Default().With(
@@ -137,14 +251,14 @@ func (h Handler) GetPayment(c echo.Context) error {
 	if !id.Validate() {
 		log.Error("invalid request: bad id")
 
-		return c.String(http.StatusBadRequest, "invalid request: bad id")
+		return problem.New(http.StatusBadRequest, "invalid request: bad id")
 	}
 
 	status, err := h.paymentManager.GetPaymentStatus(id)
 	if err != nil {
 		log.Error(err.Error())
 
-		return c.String(http.StatusInternalServerError, "get payment status failed")
+		return problem.Failed(err, "get payment status failed")
 	}
 
 	log.Info("success get payment status")
@@ -152,6 +266,88 @@ func (h Handler) GetPayment(c echo.Context) error {
 	return c.String(http.StatusOK, string(status))
 }
 
+// GetPaymentDetails gets payment details by ID
+// @Summary Get payment details by ID
+// @Description Get full payment information: amount, buyer, links, timestamps and provider payment ID
+// @Tags payments
+// @Produce json
+// @Param id path string true "Payment ID"
+// @Security APIKey
+// @Success 200 {object} gopay.PaymentDetails
+// @Failure 400 {object} problem.Problem "Invalid ID"
+// @Failure 401 {object} problem.Problem "Missing or invalid API key"
+// @Failure 403 {object} problem.Problem "API key has no required scope"
+// @Failure 404 {object} problem.Problem "Payment not found"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Router /payments/{id}/details [get]
+func (h Handler) GetPaymentDetails(c echo.Context) error {
+	log := slog.Default().With(
+		slog.String("op", "Handler.GetPaymentDetails"),
+		slog.String("request_id", c.Response().Header().Get(echo.HeaderXRequestID)),
+	)
+
+	id := gopay.ID(c.Param("id"))
+	if !id.Validate() {
+		log.Error("invalid request: bad id")
+
+		return problem.New(http.StatusBadRequest, "invalid request: bad id")
+	}
+
+	details, err := h.paymentManager.GetPaymentDetails(id)
+	if err != nil {
+		log.Error(err.Error())
+
+		return problem.Failed(err, "get payment details failed")
+	}
+
+	log.Info("success get payment details")
+
+	return c.JSON(http.StatusOK, details)
+}
+
+// RefundPayment refunds payment by ID
+// @Summary Refund payment
+// @Description Refund the full amount of the succeeded payment, the customer loses access to the paid resource.
+// @Description Repeated requests return the refunded payment
+// @Tags payments
+// @Produce json
+// @Param id path string true "Payment ID"
+// @Security APIKey
+// @Success 200 {object} gopay.Payment "Refunded payment"
+// @Failure 400 {object} problem.Problem "Invalid ID"
+// @Failure 401 {object} problem.Problem "Missing or invalid API key"
+// @Failure 403 {object} problem.Problem "API key has no required scope"
+// @Failure 404 {object} problem.Problem "Payment not found"
+// @Failure 409 {object} problem.Problem "Payment is not succeeded"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Failure 502 {object} problem.Problem "Payment provider unavailable"
+// @Router /payments/{id}/refund [post]
+func (h Handler) RefundPayment(c echo.Context) error {
+	requestID := c.Response().Header().Get(echo.HeaderXRequestID)
+	log := slog.Default().With(
+		slog.String("op", "Handler.RefundPayment"),
+		slog.String("request_id", requestID),
+	)
+
+	id := gopay.ID(c.Param("id"))
+	if !id.Validate() {
+		log.Error("invalid request: bad id")
+
+		return problem.New(http.StatusBadRequest, "invalid request: bad id")
+	}
+
+	payment, err := h.paymentManager.As(audit.ActorOf(c)).RefundPayment(id, requestID)
+	if err != nil {
+		log.Error(err.Error())
+
+		return problem.Failed(err, "refund payment failed")
+	}
+
+	log.Info("success payment refunded")
+
+	return c.JSON(http.StatusOK, payment)
+}
+
 // Redirect redirects to payment/delivery page
 // @Summary Redirect to payment/delivery page
 // @Description Redirect to payment page or delivery page depends on payment status by payment ID
@@ -159,8 +355,11 @@ func (h Handler) GetPayment(c echo.Context) error {
 // @Tags payments, files
 // @Param id path string true "Payment ID"
 // @Success 307 "Redirect to payment/delivery page URL"
-// @Failure 400 {string} string "Invalid ID"
-// @Failure 500 {string} string "Internal server error"
+// @Failure 400 {object} problem.Problem "Invalid ID"
+// @Failure 404 {object} problem.Problem "Payment not found"
+// @Failure 429 {object} problem.Problem "Too many requests"
+// @Header 429 {integer} Retry-After "Seconds until the next allowed request"
+// @Failure 500 {object} problem.Problem "Internal server error"
 // @Router /{id} [get]
 func (h Handler) Redirect(c echo.Context) error {
 	log := slog.Default().With(
@@ -172,14 +371,14 @@ func (h Handler) Redirect(c echo.Context) error {
 	if !id.Validate() {
 		log.Error("invalid request: bad id")
 
-		return c.String(http.StatusBadRequest, "invalid request: bad id")
+		return problem.New(http.StatusBadRequest, "invalid request: bad id")
 	}
 
 	link, err := h.paymentManager.GetRedirectLink(id)
 	if err != nil {
 		log.Error(err.Error())
 
-		return c.String(http.StatusInternalServerError, "get redirect link failed")
+		return problem.Failed(err, "get redirect link failed")
 	}
 
 	log.Info("success get redirect link")
@@ -203,8 +402,12 @@ type checkoutRequest struct {
 // @Accept json
 // @Param request body checkoutRequest true "Checkout request"
 // @Success 200 "Payment status updated"
-// @Failure 400 {string} string "Invalid request"
-// @Failure 500 {string} string "Internal server error"
+// @Failure 400 {object} problem.Problem "Invalid request"
+// @Failure 404 {object} problem.Problem "Payment not found"
+// @Failure 409 {object} problem.Problem "Payment is already finished"
+// @Failure 429 {object} problem.Problem "Too many requests"
+// @Header 429 {integer} Retry-After "Seconds until the next allowed request"
+// @Failure 500 {object} problem.Problem "Internal server error"
 // @Router /checkout [post]
 func (h Handler) Checkout(c echo.Context) error {
 	log := slog.Default().With(
@@ -216,19 +419,24 @@ func (h Handler) Checkout(c echo.Context) error {
 	if err := c.Bind(&req); err != nil {
 		log.Error(err.Error())
 
-		return c.String(http.StatusBadRequest, "invalid request")
+		return problem.New(http.StatusBadRequest, "invalid request")
 	}
 
 	if err := c.Validate(&req); err != nil {
 		log.Error(err.Error())
 
-		return c.String(http.StatusBadRequest, "invalid request")
+		return problem.Validation(err)
 	}
 
-	if err := h.paymentManager.UpdatePaymentStatus(req.Object.Metadata.ID, req.Object.Status); err != nil {
+	if err := h.paymentManager.UpdatePaymentStatusFrom(
+		req.Object.Metadata.ID,
+		req.Object.Status,
+		gopay.StatusSourceWebhook,
+		c.Response().Header().Get(echo.HeaderXRequestID),
+	); err != nil {
 		log.Error(err.Error())
 
-		return c.String(http.StatusInternalServerError, "update payment status failed")
+		return problem.Failed(err, "update payment status failed")
 	}
 
 	log.Info("success payment updated")
@@ -238,14 +446,18 @@ func (h Handler) Checkout(c echo.Context) error {
 
 // File gets file by ID
 // @Summary Get file by ID
-// @Description Get pdf-file content from MinIO by file ID
+// @Description Get pdf-file content from MinIO by file ID, the latest version is returned by default
 // @Description NOTE: Swagger UI does not support viewing pdf files
 // @Tags files
 // @Produce application/pdf
 // @Param id path string true "File ID"
+// @Param version query int false "File version"
 // @Success 200 {file} binary "File content"
-// @Failure 400 {string} string "Invalid ID"
-// @Failure 500 {string} string "Internal server error"
+// @Failure 400 {object} problem.Problem "Invalid ID"
+// @Failure 404 {object} problem.Problem "Unknown file version"
+// @Failure 429 {object} problem.Problem "Too many requests"
+// @Header 429 {integer} Retry-After "Seconds until the next allowed request"
+// @Failure 500 {object} problem.Problem "Internal server error"
 // @Router /files/{id} [get]
 func (h Handler) File(c echo.Context) error {
 	log := slog.Default().With(
@@ -257,17 +469,359 @@ func (h Handler) File(c echo.Context) error {
 	if !id.Validate() {
 		log.Error("invalid request: bad id")
 
-		return c.String(http.StatusBadRequest, "invalid request: bad id")
+		return problem.New(http.StatusBadRequest, "invalid request: bad id")
+	}
+
+	var version uint
+
+	if param := c.QueryParam("version"); param != "" {
+		v, err := strconv.ParseUint(param, 10, 32)
+		if err != nil || v == 0 {
+			log.Error("invalid request: bad version")
+
+			return problem.New(http.StatusBadRequest, "invalid request: bad version")
+		}
+
+		version = uint(v)
+	}
+
+	data, err := h.fileManager.GetFile(c.Request().Context(), id, version)
+	if errors.Is(err, gopay.ErrUnknownFileVersion) {
+		log.Error(err.Error())
+
+		return problem.New(http.StatusNotFound, "unknown file version")
 	}
 
-	data, err := h.fileStorage.GetData(c.Request().Context(), id)
 	if err != nil {
 		log.Error(err.Error())
 
-		return c.String(http.StatusInternalServerError, "get file failed")
+		return problem.Failed(err, "get file failed")
 	}
 
 	log.Info("success get file data")
 
 	return c.Blob(http.StatusOK, "application/pdf", data)
 }
+
+type fileVersionsResponse struct {
+	Versions []gopay.FileVersion `json:"versions"`
+}
+
+// FileVersions gets file versions by ID
+// @Summary Get file versions by ID
+// @Description Get version history of the file, the last one is served by default
+// @Tags files
+// @Produce json
+// @Param id path string true "File ID"
+// @Security APIKey
+// @Success 200 {object} fileVersionsResponse
+// @Failure 400 {object} problem.Problem "Invalid ID"
+// @Failure 401 {object} problem.Problem "Missing or invalid API key"
+// @Failure 403 {object} problem.Problem "API key has no required scope"
+// @Failure 404 {object} problem.Problem "File not found"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Router /files/{id}/versions [get]
+func (h Handler) FileVersions(c echo.Context) error {
+	log := slog.Default().With(
+		slog.String("op", "Handler.FileVersions"),
+		slog.String("request_id", c.Response().Header().Get(echo.HeaderXRequestID)),
+	)
+
+	id := gopay.ID(c.Param("id"))
+	if !id.Validate() {
+		log.Error("invalid request: bad id")
+
+		return problem.New(http.StatusBadRequest, "invalid request: bad id")
+	}
+
+	versions, err := h.fileManager.GetFileVersions(c.Request().Context(), id)
+	if errors.Is(err, gopay.ErrFileNotFound) {
+		log.Error(err.Error())
+
+		return problem.New(http.StatusNotFound, "file not found")
+	}
+
+	if err != nil {
+		log.Error(err.Error())
+
+		return problem.Failed(err, "get file versions failed")
+	}
+
+	log.Info("success get file versions")
+
+	return c.JSON(http.StatusOK, fileVersionsResponse{Versions: versions})
+}
+
+type uploadFileResponse struct {
+	Version  gopay.FileVersion `json:"version"`
+	Notified int               `json:"notified"`
+}
+
+// UploadFile publishes new file version
+// @Summary Upload new file version
+// @Description Upload pdf-file to MinIO as the new latest version of the file,
+// @Description optionally notify everyone who has succeeded payment for the product
+// @Tags files
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path string true "File ID"
+// @Param file formData file true "PDF file"
+// @Param comment formData string false "Version comment"
+// @Param notify formData string false "Notification channels separated by comma (email, telegram)"
+// @Security APIKey
+// @Success 200 {object} uploadFileResponse
+// @Failure 400 {object} problem.Problem "Invalid request"
+// @Failure 401 {object} problem.Problem "Missing or invalid API key"
+// @Failure 403 {object} problem.Problem "API key has no required scope"
+// @Failure 409 {object} problem.Problem "File version already exists"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Router /files/{id} [post]
+func (h Handler) UploadFile(c echo.Context) error {
+	log := slog.Default().With(
+		slog.String("op", "Handler.UploadFile"),
+		slog.String("request_id", c.Response().Header().Get(echo.HeaderXRequestID)),
+	)
+
+	id := gopay.ID(c.Param("id"))
+	if !id.Validate() {
+		log.Error("invalid request: bad id")
+
+		return problem.New(http.StatusBadRequest, "invalid request: bad id")
+	}
+
+	var channels []gopay.NotifyChannel
+
+	if param := c.FormValue("notify"); param != "" {
+		for _, channel := range strings.Split(param, ",") {
+			channel := gopay.NotifyChannel(strings.TrimSpace(channel))
+			if !channel.Validate() {
+				log.Error("invalid request: bad notify channel")
+
+				return problem.New(http.StatusBadRequest, "invalid request: bad notify channel")
+			}
+
+			channels = append(channels, channel)
+		}
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		log.Error(err.Error())
+
+		return problem.New(http.StatusBadRequest, "invalid request: no file")
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		log.Error(err.Error())
+
+		return problem.New(http.StatusBadRequest, "invalid request: bad file")
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		log.Error(err.Error())
+
+		return problem.New(http.StatusBadRequest, "invalid request: bad file")
+	}
+
+	version, err := h.fileManager.PublishVersion(c.Request().Context(), id, data, c.FormValue("comment"))
+	if err != nil {
+		log.Error(err.Error())
+
+		return problem.Failed(err, "upload file failed")
+	}
+
+	audit.Record(c, h.auditLog, gopay.AuditFileVersionPublish, "file:"+string(id), nil, version)
+
+	log.Info("success file version uploaded", slog.Uint64("version", uint64(version.Version)))
+
+	resp := uploadFileResponse{Version: version}
+	if len(channels) == 0 {
+		return c.JSON(http.StatusOK, resp)
+	}
+
+	// the version is already published, failed notifications are only reported
+	resp.Notified, err = h.fileManager.NotifyBuyers(c.Request().Context(), id, version, channels)
+	if err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("buyers notified about file version", slog.Int("notified", resp.Notified))
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// Unsubscribe stops payment reminders
+// @Summary Unsubscribe from payment reminders
+// @Description Stop reminders about pending payments for email address from the token sent in reminder
+// @Tags payments
+// @Produce plain
+// @Param token query string true "Unsubscribe token"
+// @Success 200 {string} string "Unsubscribed"
+// @Failure 400 {object} problem.Problem "Invalid or expired token"
+// @Failure 404 {object} problem.Problem "Reminders are disabled"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Router /unsubscribe [get]
+func (h Handler) Unsubscribe(c echo.Context) error {
+	log := slog.Default().With(
+		slog.String("op", "Handler.Unsubscribe"),
+		slog.String("request_id", c.Response().Header().Get(echo.HeaderXRequestID)),
+	)
+
+	if h.reminderManager == nil {
+		log.Error("reminders are disabled")
+
+		return problem.Disabled("reminders are disabled")
+	}
+
+	err := h.reminderManager.Unsubscribe(c.QueryParam("token"))
+	if errors.Is(err, gopay.ErrInvalidToken) || errors.Is(err, gopay.ErrTokenExpired) {
+		log.Error(err.Error())
+
+		return problem.New(http.StatusBadRequest, "invalid request: bad or expired token")
+	}
+
+	if err != nil {
+		log.Error(err.Error())
+
+		return problem.New(http.StatusInternalServerError, "unsubscribe failed")
+	}
+
+	log.Info("success unsubscribe from reminders")
+
+	return c.String(http.StatusOK, "вы отписались от напоминаний об оплате")
+}
+
+// RecoverForm shows purchases recovery form
+// @Summary Purchases recovery form
+// @Description HTML form for customers to get links to all their purchases by email
+// @Tags customers
+// @Produce html
+// @Success 200 {string} string "HTML page"
+// @Router /recover [get]
+func (h Handler) RecoverForm(c echo.Context) error {
+	return renderPage(c, http.StatusOK, "recover.html", recoverPage{})
+}
+
+type recoverRequest struct {
+	Email string `form:"email" validate:"required,email"`
+}
+
+// RecoverPurchases sends purchases links to customer
+// @Summary Recover purchases
+// @Description Send links to all purchases to the customer email, the response does not depend on
+// @Description whether the customer exists, repeated requests for the same email are ignored for a while
+// @Tags customers
+// @Accept x-www-form-urlencoded
+// @Produce html
+// @Param email formData string true "Customer email"
+// @Success 200 {string} string "HTML page"
+// @Failure 400 {string} string "HTML page with invalid email message"
+// @Failure 404 {object} problem.Problem "Email delivery is disabled"
+// @Failure 429 {object} problem.Problem "Too many requests"
+// @Router /recover [post]
+func (h Handler) RecoverPurchases(c echo.Context) error {
+	log := slog.Default().With(
+		slog.String("op", "Handler.RecoverPurchases"),
+		slog.String("request_id", c.Response().Header().Get(echo.HeaderXRequestID)),
+	)
+
+	if h.customerManager == nil {
+		log.Error("email delivery is disabled")
+
+		return problem.Disabled("email delivery is disabled")
+	}
+
+	var req recoverRequest
+	if err := c.Bind(&req); err != nil {
+		log.Error(err.Error())
+
+		return renderPage(c, http.StatusBadRequest, "recover.html", recoverPage{Message: "некорректный запрос"})
+	}
+
+	if err := c.Validate(&req); err != nil {
+		log.Error(err.Error())
+
+		return renderPage(c, http.StatusBadRequest, "recover.html", recoverPage{
+			Email:   req.Email,
+			Message: "некорректный email",
+		})
+	}
+
+	// sending in background keeps response time the same for known and unknown customers
+	go func() {
+		if _, err := h.customerManager.RecoverPurchases(req.Email); err != nil {
+			log.Error(err.Error())
+		}
+	}()
+
+	log.Info("purchases recovery requested")
+
+	return renderPage(c, http.StatusOK, "recover.html", recoverPage{
+		Message: "если по этому адресу есть оплаченные заказы, ссылки на них придут в письме в течение нескольких минут",
+	})
+}
+
+type resendRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type resendResponse struct {
+	Purchases int `json:"purchases"`
+}
+
+// ResendPurchases sends purchases links to customer
+// @Summary Resend purchases links
+// @Description Send links to all succeeded payments of the customer to the customer email
+// @Tags customers
+// @Accept json
+// @Produce json
+// @Param request body resendRequest true "Resend request"
+// @Security APIKey
+// @Success 200 {object} resendResponse "Number of sent purchases, email is not sent if zero"
+// @Failure 400 {object} problem.Problem "Invalid request"
+// @Failure 401 {object} problem.Problem "Missing or invalid API key"
+// @Failure 403 {object} problem.Problem "API key has no required scope"
+// @Failure 404 {object} problem.Problem "Email delivery is disabled"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Router /customers/resend [post]
+func (h Handler) ResendPurchases(c echo.Context) error {
+	log := slog.Default().With(
+		slog.String("op", "Handler.ResendPurchases"),
+		slog.String("request_id", c.Response().Header().Get(echo.HeaderXRequestID)),
+	)
+
+	if h.customerManager == nil {
+		log.Error("email delivery is disabled")
+
+		return problem.Disabled("email delivery is disabled")
+	}
+
+	var req resendRequest
+	if err := c.Bind(&req); err != nil {
+		log.Error(err.Error())
+
+		return problem.New(http.StatusBadRequest, "invalid request")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		log.Error(err.Error())
+
+		return problem.Validation(err)
+	}
+
+	sent, err := h.customerManager.ResendPurchases(req.Email)
+	if err != nil {
+		log.Error(err.Error())
+
+		return problem.Failed(err, "resend purchases failed")
+	}
+
+	audit.Record(c, h.auditLog, gopay.AuditPurchasesResend, "customer:"+req.Email, nil, resendResponse{Purchases: sent})
+
+	log.Info("success purchases resent", slog.Int("purchases", sent))
+
+	return c.JSON(http.StatusOK, resendResponse{Purchases: sent})
+}
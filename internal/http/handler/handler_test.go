@@ -0,0 +1,87 @@
+package handler_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Anton-Kraev/gopay"
+	"github.com/Anton-Kraev/gopay/internal/http/handler"
+	"github.com/Anton-Kraev/gopay/internal/http/problem"
+	"github.com/Anton-Kraev/gopay/memory"
+)
+
+// unsubscribeSigner verifies any token with the result of the signer used to issue it
+type unsubscribeSigner struct {
+	email string
+	err   error
+}
+
+func (s unsubscribeSigner) Sign(string, string, time.Duration) (string, error) {
+	return "token", nil
+}
+
+func (s unsubscribeSigner) Verify(string, string) (string, error) {
+	return s.email, s.err
+}
+
+func TestHandler_Unsubscribe(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		signer unsubscribeSigner
+		status int
+	}{
+		{
+			name:   "valid token",
+			signer: unsubscribeSigner{email: "user@example.com"},
+			status: http.StatusOK,
+		},
+		{
+			name:   "invalid token",
+			signer: unsubscribeSigner{err: fmt.Errorf("token.Signer.Verify: %w", gopay.ErrInvalidToken)},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "expired token",
+			signer: unsubscribeSigner{err: fmt.Errorf("token.Signer.Verify: %w", gopay.ErrTokenExpired)},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "unknown error",
+			signer: unsubscribeSigner{err: errors.New("error verify token")},
+			status: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			storage := memory.NewStorage()
+			rm := gopay.NewReminderManager(storage, tt.signer, nil, nil, gopay.ReminderConfig{})
+			h := handler.NewHandler(nil, nil, rm, nil, nil, nil, nil)
+
+			e := echo.New()
+			e.HTTPErrorHandler = problem.HandleError
+			e.GET("/api/unsubscribe", h.Unsubscribe)
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/unsubscribe?token=token", nil))
+
+			require.Equal(t, tt.status, rec.Code)
+
+			unsubscribed, err := storage.IsUnsubscribed("user@example.com")
+			require.NoError(t, err)
+			assert.Equal(t, tt.status == http.StatusOK, unsubscribed)
+		})
+	}
+}
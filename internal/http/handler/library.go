@@ -0,0 +1,250 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/Anton-Kraev/gopay"
+	"github.com/Anton-Kraev/gopay/internal/http/problem"
+)
+
+const sessionCookie = "gopay_session"
+
+// Library shows customer purchases
+// @Summary Customer library page
+// @Description HTML page with all customer payments and download links, shows login form without session
+// @Tags customers
+// @Produce html
+// @Success 200 {string} string "HTML page"
+// @Failure 404 {object} problem.Problem "Email delivery is disabled"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Router /library [get]
+func (h Handler) Library(c echo.Context) error {
+	log := slog.Default().With(
+		slog.String("op", "Handler.Library"),
+		slog.String("request_id", c.Response().Header().Get(echo.HeaderXRequestID)),
+	)
+
+	if h.customerManager == nil {
+		log.Error("email delivery is disabled")
+
+		return problem.Disabled("email delivery is disabled")
+	}
+
+	email, ok := h.authenticate(c)
+	if !ok {
+		return renderPage(c, http.StatusOK, "library.html", libraryPage{})
+	}
+
+	purchases, err := h.customerManager.GetPayments(email)
+	if err != nil {
+		log.Error(err.Error())
+
+		return problem.New(http.StatusInternalServerError, "get purchases failed")
+	}
+
+	log.Info("success get library")
+
+	return renderPage(c, http.StatusOK, "library.html", libraryPage{
+		Email:     email,
+		Purchases: purchases,
+	})
+}
+
+type libraryResponse struct {
+	Email     string           `json:"email"`
+	Purchases []gopay.Purchase `json:"purchases"`
+}
+
+// LibraryPurchases gets customer purchases
+// @Summary Get customer purchases
+// @Description Get all customer payments with statuses and links, session is taken from cookie
+// @Description or "Authorization: Bearer <session>" header
+// @Tags customers
+// @Produce json
+// @Success 200 {object} libraryResponse
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 404 {object} problem.Problem "Email delivery is disabled"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Router /library/purchases [get]
+func (h Handler) LibraryPurchases(c echo.Context) error {
+	log := slog.Default().With(
+		slog.String("op", "Handler.LibraryPurchases"),
+		slog.String("request_id", c.Response().Header().Get(echo.HeaderXRequestID)),
+	)
+
+	if h.customerManager == nil {
+		log.Error("email delivery is disabled")
+
+		return problem.Disabled("email delivery is disabled")
+	}
+
+	email, ok := h.authenticate(c)
+	if !ok {
+		log.Error("unauthorized")
+
+		return problem.New(http.StatusUnauthorized, "unauthorized")
+	}
+
+	purchases, err := h.customerManager.GetPayments(email)
+	if err != nil {
+		log.Error(err.Error())
+
+		return problem.New(http.StatusInternalServerError, "get purchases failed")
+	}
+
+	log.Info("success get library purchases")
+
+	return c.JSON(http.StatusOK, libraryResponse{Email: email, Purchases: purchases})
+}
+
+// LibraryRequestLogin sends magic link
+// @Summary Request customer library login
+// @Description Send one-time magic link for login into the customer library, the response does not depend on
+// @Description whether the customer exists
+// @Tags customers
+// @Accept x-www-form-urlencoded
+// @Produce html
+// @Param email formData string true "Customer email"
+// @Success 200 {string} string "HTML page"
+// @Failure 400 {string} string "HTML page with invalid email message"
+// @Failure 404 {object} problem.Problem "Email delivery is disabled"
+// @Failure 429 {object} problem.Problem "Too many requests"
+// @Router /library/login [post]
+func (h Handler) LibraryRequestLogin(c echo.Context) error {
+	log := slog.Default().With(
+		slog.String("op", "Handler.LibraryRequestLogin"),
+		slog.String("request_id", c.Response().Header().Get(echo.HeaderXRequestID)),
+	)
+
+	if h.customerManager == nil {
+		log.Error("email delivery is disabled")
+
+		return problem.Disabled("email delivery is disabled")
+	}
+
+	var req recoverRequest
+	if err := c.Bind(&req); err != nil {
+		log.Error(err.Error())
+
+		return renderPage(c, http.StatusBadRequest, "library.html", libraryPage{Message: "некорректный запрос"})
+	}
+
+	if err := c.Validate(&req); err != nil {
+		log.Error(err.Error())
+
+		return renderPage(c, http.StatusBadRequest, "library.html", libraryPage{Message: "некорректный email"})
+	}
+
+	// sending in background keeps response time the same for known and unknown customers
+	go func() {
+		if err := h.customerManager.RequestLogin(req.Email); err != nil {
+			log.Error(err.Error())
+		}
+	}()
+
+	log.Info("library login requested")
+
+	return renderPage(c, http.StatusOK, "library.html", libraryPage{
+		Message: "если по этому адресу есть заказы, ссылка для входа придет в письме в течение нескольких минут",
+	})
+}
+
+// LibraryLoginPage shows login confirmation for magic link
+// @Summary Customer library login page
+// @Description HTML page with the form which exchanges magic link token for the session, the token is not spent
+// @Description by GET requests, so it is not used up by email link scanners
+// @Tags customers
+// @Produce html
+// @Param token query string true "Magic link token"
+// @Success 200 {string} string "HTML page"
+// @Failure 404 {object} problem.Problem "Email delivery is disabled"
+// @Router /library/login [get]
+func (h Handler) LibraryLoginPage(c echo.Context) error {
+	if h.customerManager == nil {
+		slog.Default().Error("email delivery is disabled",
+			slog.String("op", "Handler.LibraryLoginPage"),
+			slog.String("request_id", c.Response().Header().Get(echo.HeaderXRequestID)),
+		)
+
+		return problem.Disabled("email delivery is disabled")
+	}
+
+	return renderPage(c, http.StatusOK, "library.html", libraryPage{LoginToken: c.QueryParam("token")})
+}
+
+// LibraryLogin logs customer in by magic link
+// @Summary Customer library login
+// @Description Exchange one-time magic link token for the session cookie and redirect to the library page
+// @Tags customers
+// @Accept x-www-form-urlencoded
+// @Param token formData string true "Magic link token"
+// @Success 303 "Redirect to the library page"
+// @Failure 400 {string} string "HTML page with invalid link message"
+// @Failure 404 {object} problem.Problem "Email delivery is disabled"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Router /library/session [post]
+func (h Handler) LibraryLogin(c echo.Context) error {
+	log := slog.Default().With(
+		slog.String("op", "Handler.LibraryLogin"),
+		slog.String("request_id", c.Response().Header().Get(echo.HeaderXRequestID)),
+	)
+
+	if h.customerManager == nil {
+		log.Error("email delivery is disabled")
+
+		return problem.Disabled("email delivery is disabled")
+	}
+
+	session, err := h.customerManager.Login(c.FormValue("token"))
+	if errors.Is(err, gopay.ErrInvalidToken) || errors.Is(err, gopay.ErrTokenExpired) {
+		log.Error(err.Error())
+
+		return renderPage(c, http.StatusBadRequest, "library.html", libraryPage{
+			Message: "ссылка для входа недействительна или устарела, запросите новую",
+		})
+	}
+
+	if err != nil {
+		log.Error(err.Error())
+
+		return problem.New(http.StatusInternalServerError, "login failed")
+	}
+
+	c.SetCookie(&http.Cookie{
+		Name:     sessionCookie,
+		Value:    session,
+		Path:     "/api/library",
+		HttpOnly: true,
+		Secure:   c.IsTLS(),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	log.Info("success library login")
+
+	return c.Redirect(http.StatusSeeOther, "/api/library")
+}
+
+func (h Handler) authenticate(c echo.Context) (string, bool) {
+	var session string
+
+	if cookie, err := c.Cookie(sessionCookie); err == nil {
+		session = cookie.Value
+	}
+
+	if bearer, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer "); ok {
+		session = bearer
+	}
+
+	if session == "" {
+		return "", false
+	}
+
+	email, err := h.customerManager.Authenticate(session)
+
+	return email, err == nil
+}
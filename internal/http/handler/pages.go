@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+//go:embed pages
+var pagesFS embed.FS
+
+var pages = template.Must(template.ParseFS(pagesFS, "pages/*.html"))
+
+type recoverPage struct {
+	Email   string
+	Message string
+}
+
+type libraryPage struct {
+	Email   string
+	Message string
+	// LoginToken shows the form which exchanges the magic link token for the session
+	LoginToken string
+	Purchases  []gopay.Purchase
+}
+
+func renderPage(c echo.Context, code int, name string, data any) error {
+	var buf bytes.Buffer
+	if err := pages.ExecuteTemplate(&buf, name, data); err != nil {
+		return err
+	}
+
+	return c.HTMLBlob(code, buf.Bytes())
+}
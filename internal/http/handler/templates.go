@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/Anton-Kraev/gopay"
+	"github.com/Anton-Kraev/gopay/internal/http/problem"
+)
+
+// PreviewTemplate renders notification template of the product
+// @Summary Preview notification template
+// @Description Render notification template of the product with sample data, product overrides are used if present
+// @Tags templates
+// @Produce json
+// @Param product_id path string true "Product ID"
+// @Param name path string true "Template name, e.g. payment_succeeded"
+// @Security APIKey
+// @Success 200 {object} notify.TemplatePreview "Rendered notification"
+// @Failure 400 {object} problem.Problem "Invalid product ID"
+// @Failure 401 {object} problem.Problem "Missing or invalid API key"
+// @Failure 403 {object} problem.Problem "API key has no required scope"
+// @Failure 404 {object} problem.Problem "Template not found"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Router /templates/{product_id}/{name} [get]
+func (h Handler) PreviewTemplate(c echo.Context) error {
+	log := slog.Default().With(
+		slog.String("op", "Handler.PreviewTemplate"),
+		slog.String("request_id", c.Response().Header().Get(echo.HeaderXRequestID)),
+	)
+
+	productID := gopay.ID(c.Param("product_id"))
+	if !productID.Validate() {
+		log.Error("invalid request: bad product id")
+
+		return problem.New(http.StatusBadRequest, "invalid request: bad product id")
+	}
+
+	preview, err := h.templates.Preview(productID, c.Param("name"))
+	if err != nil {
+		log.Error(err.Error())
+
+		return problem.Failed(err, "preview template failed")
+	}
+
+	log.Info("success template preview")
+
+	return c.JSON(http.StatusOK, preview)
+}
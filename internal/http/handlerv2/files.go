@@ -0,0 +1,203 @@
+package handlerv2
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/Anton-Kraev/gopay"
+	"github.com/Anton-Kraev/gopay/internal/http/audit"
+	"github.com/Anton-Kraev/gopay/internal/http/problem"
+)
+
+type fileVersionsResponse struct {
+	Versions []gopay.FileVersion `json:"versions"`
+}
+
+// ListFileVersions gets file versions
+// @Summary Get file versions
+// @Description Get version history of the file, the last one is the current version
+// @Tags files
+// @Produce json
+// @Param id path string true "File ID"
+// @Security APIKey
+// @Success 200 {object} fileVersionsResponse
+// @Failure 400 {object} problem.Problem "Invalid ID"
+// @Failure 401 {object} problem.Problem "Missing or invalid API key"
+// @Failure 403 {object} problem.Problem "API key has no required scope"
+// @Failure 404 {object} problem.Problem "File not found"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Router /files/{id}/versions [get]
+func (h Handler) ListFileVersions(c echo.Context) error {
+	log := logger(c, "handlerv2.Handler.ListFileVersions")
+
+	id, err := pathID(c, log)
+	if err != nil {
+		return err
+	}
+
+	versions, err := h.fileManager.GetFileVersions(c.Request().Context(), id)
+	if err != nil {
+		log.Error(err.Error())
+
+		return problem.Failed(err, "get file versions failed")
+	}
+
+	log.Info("success get file versions")
+
+	return c.JSON(http.StatusOK, fileVersionsResponse{Versions: versions})
+}
+
+// GetFileVersion gets file content
+// @Summary Get file version content
+// @Description Get pdf-file content of the file version, use "latest" to get the current version
+// @Tags files
+// @Produce application/pdf
+// @Param id path string true "File ID"
+// @Param version path string true "File version or latest"
+// @Success 200 {file} binary "File content"
+// @Failure 400 {object} problem.Problem "Invalid request"
+// @Failure 404 {object} problem.Problem "File version not found"
+// @Failure 429 {object} problem.Problem "Too many requests"
+// @Header 429 {integer} Retry-After "Seconds until the next allowed request"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Router /files/{id}/versions/{version} [get]
+func (h Handler) GetFileVersion(c echo.Context) error {
+	log := logger(c, "handlerv2.Handler.GetFileVersion")
+
+	id, err := pathID(c, log)
+	if err != nil {
+		return err
+	}
+
+	// zero version means the latest one for the file manager
+	var version uint
+
+	if param := c.Param("version"); param != "latest" {
+		v, err := strconv.ParseUint(param, 10, 32)
+		if err != nil || v == 0 {
+			log.Error("invalid request: bad version")
+
+			return problem.New(http.StatusBadRequest, "invalid request: bad version")
+		}
+
+		version = uint(v)
+	}
+
+	data, err := h.fileManager.GetFile(c.Request().Context(), id, version)
+	if err != nil {
+		log.Error(err.Error())
+
+		return problem.Failed(err, "get file failed")
+	}
+
+	log.Info("success get file data")
+
+	return c.Blob(http.StatusOK, "application/pdf", data)
+}
+
+type publishFileVersionResponse struct {
+	Version gopay.FileVersion `json:"version"`
+	// Notified is the number of notified buyers
+	Notified int `json:"notified"`
+}
+
+// PublishFileVersion publishes new file version
+// @Summary Publish new file version
+// @Description Upload pdf-file as the new latest version of the file,
+// @Description optionally notify everyone who has succeeded payment for the product
+// @Tags files
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path string true "File ID"
+// @Param file formData file true "PDF file"
+// @Param comment formData string false "Version comment"
+// @Param notify formData string false "Notification channels separated by comma (email, telegram)"
+// @Security APIKey
+// @Success 201 {object} publishFileVersionResponse
+// @Header 201 {string} Location "URL of the published version"
+// @Failure 400 {object} problem.Problem "Invalid request"
+// @Failure 401 {object} problem.Problem "Missing or invalid API key"
+// @Failure 403 {object} problem.Problem "API key has no required scope"
+// @Failure 409 {object} problem.Problem "File version already exists"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Router /files/{id}/versions [post]
+func (h Handler) PublishFileVersion(c echo.Context) error {
+	log := logger(c, "handlerv2.Handler.PublishFileVersion")
+
+	id, err := pathID(c, log)
+	if err != nil {
+		return err
+	}
+
+	var channels []gopay.NotifyChannel
+
+	if param := c.FormValue("notify"); param != "" {
+		for _, channel := range strings.Split(param, ",") {
+			channel := gopay.NotifyChannel(strings.TrimSpace(channel))
+			if !channel.Validate() {
+				log.Error("invalid request: bad notify channel")
+
+				return problem.New(http.StatusBadRequest, "invalid request: bad notify channel")
+			}
+
+			channels = append(channels, channel)
+		}
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		log.Error(err.Error())
+
+		return problem.New(http.StatusBadRequest, "invalid request: no file")
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		log.Error(err.Error())
+
+		return problem.New(http.StatusBadRequest, "invalid request: bad file")
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		log.Error(err.Error())
+
+		return problem.New(http.StatusBadRequest, "invalid request: bad file")
+	}
+
+	version, err := h.fileManager.PublishVersion(c.Request().Context(), id, data, c.FormValue("comment"))
+	if err != nil {
+		log.Error(err.Error())
+
+		return problem.Failed(err, "publish file version failed")
+	}
+
+	audit.Record(c, h.auditLog, gopay.AuditFileVersionPublish, "file:"+string(id), nil, version)
+
+	log.Info("success file version published", slog.Uint64("version", uint64(version.Version)))
+
+	resp := publishFileVersionResponse{Version: version}
+
+	// the version is already published, failed notifications are only reported
+	if len(channels) != 0 {
+		resp.Notified, err = h.fileManager.NotifyBuyers(c.Request().Context(), id, version, channels)
+		if err != nil {
+			log.Error(err.Error())
+		}
+
+		log.Info("buyers notified about file version", slog.Int("notified", resp.Notified))
+	}
+
+	c.Response().Header().Set(
+		echo.HeaderLocation,
+		c.Echo().Reverse("v2.fileVersion", id, strconv.FormatUint(uint64(version.Version), 10)),
+	)
+
+	return c.JSON(http.StatusCreated, resp)
+}
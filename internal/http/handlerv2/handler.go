@@ -0,0 +1,341 @@
+// Package handlerv2 implements version 2 of the API, all requests and responses except file contents are JSON
+// and resources are addressed by URL, handlers of version 1 are kept unchanged in package handler
+package handlerv2
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/Anton-Kraev/gopay"
+	"github.com/Anton-Kraev/gopay/internal/http/audit"
+	"github.com/Anton-Kraev/gopay/internal/http/problem"
+)
+
+type Handler struct {
+	paymentManager  *gopay.PaymentManager
+	fileManager     *gopay.FileManager
+	customerManager *gopay.CustomerManager // nil if email delivery is disabled
+	auditLog        *gopay.AuditLog        // nil if storage does not keep the audit log
+}
+
+func NewHandler(
+	paymentManager *gopay.PaymentManager,
+	fileManager *gopay.FileManager,
+	customerManager *gopay.CustomerManager,
+	auditLog *gopay.AuditLog,
+) Handler {
+	return Handler{
+		paymentManager:  paymentManager,
+		fileManager:     fileManager,
+		customerManager: customerManager,
+		auditLog:        auditLog,
+	}
+}
+
+func logger(c echo.Context, op string) *slog.Logger {
+	return slog.Default().With(
+		slog.String("op", op),
+		slog.String("request_id", c.Response().Header().Get(echo.HeaderXRequestID)),
+	)
+}
+
+// pathID returns valid ID from the path parameter
+func pathID(c echo.Context, log *slog.Logger) (gopay.ID, error) {
+	id := gopay.ID(c.Param("id"))
+	if !id.Validate() {
+		log.Error("invalid request: bad id")
+
+		return "", problem.New(http.StatusBadRequest, "invalid request: bad id")
+	}
+
+	return id, nil
+}
+
+// headerIdempotentReplayed marks responses to retries of requests with idempotency key
+const headerIdempotentReplayed = "Idempotent-Replayed"
+
+type createPaymentRequest struct {
+	Template gopay.PaymentTemplate `json:"template" validate:"required"`
+	User     gopay.User            `json:"user" validate:"required"`
+}
+
+type createPaymentResponse struct {
+	ID gopay.ID `json:"id"`
+	// Link is given to the buyer, it leads to the payment page and to the resource after payment
+	Link gopay.Link `json:"link"`
+	// PaymentLink is the payment page of the payment service
+	PaymentLink gopay.Link   `json:"payment_link"`
+	Status      gopay.Status `json:"status"`
+	// ExpiresAt is omitted if the time to pay is not limited
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// CreatePayment creates a new payment
+// @Summary Create a new payment
+// @Description Create a new payment, the response contains payment ID, link for the buyer and expiration time
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Param request body createPaymentRequest true "Payment creation request"
+// @Param Idempotency-Key header string false "Key to retry the request without creating the payment twice"
+// @Security APIKey
+// @Success 201 {object} createPaymentResponse
+// @Header 201 {string} Location "URL of the created payment"
+// @Header 201 {string} Idempotent-Replayed "Set to true if the payment was created by the previous request with the key"
+// @Failure 400 {object} problem.Problem "Invalid request"
+// @Failure 401 {object} problem.Problem "Missing or invalid API key"
+// @Failure 403 {object} problem.Problem "API key has no required scope"
+// @Failure 409 {object} problem.Problem "Request with the same key is in progress"
+// @Failure 422 {object} problem.Problem "Idempotency key is used for another request"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Failure 502 {object} problem.Problem "Payment provider unavailable"
+// @Router /payments [post]
+func (h Handler) CreatePayment(c echo.Context) error {
+	log := logger(c, "handlerv2.Handler.CreatePayment")
+
+	var req createPaymentRequest
+	if err := c.Bind(&req); err != nil {
+		log.Error(err.Error())
+
+		return problem.New(http.StatusBadRequest, "invalid request")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		log.Error(err.Error())
+
+		return problem.Validation(err)
+	}
+
+	// the creation is recorded in the audit log in the transaction which saves the payment
+	details, replayed, err := h.paymentManager.As(audit.ActorOf(c)).CreatePaymentOnce(
+		c.Request().Header.Get(gopay.HeaderIdempotencyKey), req.Template, req.User,
+	)
+	if err != nil {
+		log.Error(err.Error())
+
+		return problem.Failed(err, "create payment failed")
+	}
+
+	// the retry gets the same response as the request which created the payment
+	if replayed {
+		c.Response().Header().Set(headerIdempotentReplayed, "true")
+	}
+
+	log.Info("success payment created", slog.String("id", string(details.ID)), slog.Bool("replayed", replayed))
+
+	resp := createPaymentResponse{
+		ID:          details.ID,
+		Link:        details.Link,
+		PaymentLink: details.Payment.PaymentLink,
+		Status:      details.Payment.Status,
+	}
+
+	if !details.Payment.ExpiresAt.IsZero() {
+		resp.ExpiresAt = &details.Payment.ExpiresAt
+	}
+
+	c.Response().Header().Set(echo.HeaderLocation, c.Echo().Reverse("v2.payment", details.ID))
+
+	return c.JSON(http.StatusCreated, resp)
+}
+
+type listPaymentsRequest struct {
+	Status      gopay.Status      `query:"status" validate:"omitempty,status"`
+	Email       string            `query:"email" validate:"omitempty,email"`
+	ProductID   gopay.ID          `query:"product_id" validate:"omitempty,id"`
+	CreatedFrom time.Time         `query:"created_from"`
+	CreatedTo   time.Time         `query:"created_to"`
+	MinAmount   uint              `query:"min_amount"`
+	MaxAmount   uint              `query:"max_amount" validate:"omitempty,gtefield=MinAmount"`
+	Sort        gopay.PaymentSort `query:"sort" validate:"omitempty,oneof=created_at -created_at amount -amount"`
+	Limit       int               `query:"limit" validate:"omitempty,min=1,max=500"`
+	Cursor      string            `query:"cursor"`
+}
+
+// ListPayments gets payments page
+// @Summary Get payments
+// @Description Get page of payments matching filters, pass next_cursor from the response as cursor to get next page
+// @Tags payments
+// @Produce json
+// @Param status query string false "Payment status"
+// @Param email query string false "Customer email"
+// @Param product_id query string false "Product ID"
+// @Param created_from query string false "Created at or after, RFC 3339"
+// @Param created_to query string false "Created before, RFC 3339"
+// @Param min_amount query int false "Minimum amount"
+// @Param max_amount query int false "Maximum amount"
+// @Param sort query string false "Sort order" Enums(created_at, -created_at, amount, -amount) default(-created_at)
+// @Param limit query int false "Page size" minimum(1) maximum(500) default(50)
+// @Param cursor query string false "Cursor of the page"
+// @Security APIKey
+// @Success 200 {object} gopay.PaymentPage
+// @Failure 400 {object} problem.Problem "Invalid request"
+// @Failure 401 {object} problem.Problem "Missing or invalid API key"
+// @Failure 403 {object} problem.Problem "API key has no required scope"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Router /payments [get]
+func (h Handler) ListPayments(c echo.Context) error {
+	log := logger(c, "handlerv2.Handler.ListPayments")
+
+	var req listPaymentsRequest
+	if err := c.Bind(&req); err != nil {
+		log.Error(err.Error())
+
+		return problem.New(http.StatusBadRequest, "invalid request")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		log.Error(err.Error())
+
+		return problem.Validation(err)
+	}
+
+	page, err := h.paymentManager.ListPayments(gopay.PaymentFilter(req))
+	if err != nil {
+		log.Error(err.Error())
+
+		return problem.Failed(err, "get payments failed")
+	}
+
+	log.Info("success get payments")
+
+	return c.JSON(http.StatusOK, page)
+}
+
+// GetPayment gets payment by ID
+// @Summary Get payment by ID
+// @Description Get full payment information: amount, buyer, links, timestamps, status history and provider payment ID
+// @Tags payments
+// @Produce json
+// @Param id path string true "Payment ID"
+// @Security APIKey
+// @Success 200 {object} gopay.PaymentDetails
+// @Failure 400 {object} problem.Problem "Invalid ID"
+// @Failure 401 {object} problem.Problem "Missing or invalid API key"
+// @Failure 403 {object} problem.Problem "API key has no required scope"
+// @Failure 404 {object} problem.Problem "Payment not found"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Router /payments/{id} [get]
+func (h Handler) GetPayment(c echo.Context) error {
+	log := logger(c, "handlerv2.Handler.GetPayment")
+
+	id, err := pathID(c, log)
+	if err != nil {
+		return err
+	}
+
+	details, err := h.paymentManager.GetPaymentDetails(id)
+	if err != nil {
+		log.Error(err.Error())
+
+		return problem.Failed(err, "get payment failed")
+	}
+
+	log.Info("success get payment")
+
+	return c.JSON(http.StatusOK, details)
+}
+
+type paymentStatusResponse struct {
+	ID     gopay.ID     `json:"id"`
+	Status gopay.Status `json:"status"`
+}
+
+// GetPaymentStatus gets payment status by ID
+// @Summary Get payment status by ID
+// @Description Get status of the payment, it is cheaper than getting the whole payment
+// @Tags payments
+// @Produce json
+// @Param id path string true "Payment ID"
+// @Security APIKey
+// @Success 200 {object} paymentStatusResponse
+// @Failure 400 {object} problem.Problem "Invalid ID"
+// @Failure 401 {object} problem.Problem "Missing or invalid API key"
+// @Failure 403 {object} problem.Problem "API key has no required scope"
+// @Failure 404 {object} problem.Problem "Payment not found"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Router /payments/{id}/status [get]
+func (h Handler) GetPaymentStatus(c echo.Context) error {
+	log := logger(c, "handlerv2.Handler.GetPaymentStatus")
+
+	id, err := pathID(c, log)
+	if err != nil {
+		return err
+	}
+
+	status, err := h.paymentManager.GetPaymentStatus(id)
+	if err != nil {
+		log.Error(err.Error())
+
+		return problem.Failed(err, "get payment status failed")
+	}
+
+	log.Info("success get payment status")
+
+	return c.JSON(http.StatusOK, paymentStatusResponse{ID: id, Status: status})
+}
+
+type resendPurchasesRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type resendPurchasesResponse struct {
+	// Purchases is the number of sent purchases, email is not sent if zero
+	Purchases int `json:"purchases"`
+}
+
+// ResendPurchases sends purchases links to customer
+// @Summary Resend purchases links
+// @Description Send links to all succeeded payments of the customer to the customer email
+// @Tags customers
+// @Accept json
+// @Produce json
+// @Param request body resendPurchasesRequest true "Resend request"
+// @Security APIKey
+// @Success 200 {object} resendPurchasesResponse
+// @Failure 400 {object} problem.Problem "Invalid request"
+// @Failure 401 {object} problem.Problem "Missing or invalid API key"
+// @Failure 403 {object} problem.Problem "API key has no required scope"
+// @Failure 404 {object} problem.Problem "Email delivery is disabled"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Router /customers/resend-purchases [post]
+func (h Handler) ResendPurchases(c echo.Context) error {
+	log := logger(c, "handlerv2.Handler.ResendPurchases")
+
+	if h.customerManager == nil {
+		log.Error("email delivery is disabled")
+
+		return problem.Disabled("email delivery is disabled")
+	}
+
+	var req resendPurchasesRequest
+	if err := c.Bind(&req); err != nil {
+		log.Error(err.Error())
+
+		return problem.New(http.StatusBadRequest, "invalid request")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		log.Error(err.Error())
+
+		return problem.Validation(err)
+	}
+
+	sent, err := h.customerManager.ResendPurchases(req.Email)
+	if err != nil {
+		log.Error(err.Error())
+
+		return problem.Failed(err, "resend purchases failed")
+	}
+
+	resp := resendPurchasesResponse{Purchases: sent}
+
+	audit.Record(c, h.auditLog, gopay.AuditPurchasesResend, "customer:"+req.Email, nil, resp)
+
+	log.Info("success purchases resent", slog.Int("purchases", sent))
+
+	return c.JSON(http.StatusOK, resp)
+}
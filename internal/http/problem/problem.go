@@ -0,0 +1,182 @@
+// Package problem writes API errors as RFC 7807 problem details, handlers return errors made by
+// the package and HandleError, set as echo.HTTPErrorHandler, writes them
+package problem
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+// MIMEJSON is the content type of error responses
+const MIMEJSON = "application/problem+json"
+
+// Problem is the body of all error responses, see RFC 7807
+type Problem struct {
+	Type   string `json:"type" example:"about:blank"`
+	Title  string `json:"title" example:"Not Found"`
+	Status int    `json:"status" example:"404"`
+	// Code identifies the error for clients, it is more specific than the status
+	Code      string       `json:"code" example:"not_found"`
+	Detail    string       `json:"detail" example:"get payment status failed: not found"`
+	RequestID string       `json:"request_id,omitempty" example:"vRKkBjXWNsdHw6ZuNcpkT8SLHGBOvbET"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describes invalid field of the request, field is named as in the request
+type FieldError struct {
+	Field   string `json:"field" example:"user.email"`
+	Message string `json:"message" example:"must be a valid email"`
+}
+
+// problemError is returned by handlers wrapped in echo.HTTPError, so middlewares see the status
+type problemError struct {
+	status int
+	code   string
+	detail string
+	fields []FieldError
+}
+
+func (e *problemError) Error() string {
+	return e.detail
+}
+
+func (e *problemError) httpError() *echo.HTTPError {
+	return &echo.HTTPError{Code: e.status, Message: e.detail, Internal: e}
+}
+
+// New returns error described by the status only, e.g. "bad_request"
+func New(status int, detail string) *echo.HTTPError {
+	return (&problemError{status: status, code: statusCode(status), detail: detail}).httpError()
+}
+
+// Disabled is returned by handlers of features which are turned off in the configuration
+func Disabled(detail string) *echo.HTTPError {
+	return (&problemError{status: http.StatusNotFound, code: "disabled", detail: detail}).httpError()
+}
+
+// domainErrors maps errors of the gopay package to response codes, the first matching one is used
+var domainErrors = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{gopay.ErrNotFound, http.StatusNotFound, "not_found"},
+	{gopay.ErrAlreadyExists, http.StatusConflict, "already_exists"},
+	{gopay.ErrInvalidTransition, http.StatusConflict, "invalid_transition"},
+	{gopay.ErrProviderUnavailable, http.StatusBadGateway, "provider_unavailable"},
+	{gopay.ErrInvalidIdempotencyKey, http.StatusBadRequest, "invalid_idempotency_key"},
+	{gopay.ErrIdempotencyKeyReused, http.StatusUnprocessableEntity, "idempotency_key_reused"},
+	{gopay.ErrRequestInProgress, http.StatusConflict, "request_in_progress"},
+}
+
+// Failed describes the failed operation, the reason is added to the detail for known errors
+// of the gopay package, other errors are internal
+func Failed(err error, detail string) *echo.HTTPError {
+	for _, e := range domainErrors {
+		if errors.Is(err, e.err) {
+			return (&problemError{status: e.status, code: e.code, detail: detail + ": " + e.err.Error()}).httpError()
+		}
+	}
+
+	return New(http.StatusInternalServerError, detail)
+}
+
+// Validation lists fields of the request which failed validation
+func Validation(err error) *echo.HTTPError {
+	p := &problemError{status: http.StatusBadRequest, code: "validation_failed", detail: "invalid request"}
+
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return p.httpError()
+	}
+
+	for _, fe := range errs {
+		// the namespace starts with the request type name
+		_, field, _ := strings.Cut(fe.Namespace(), ".")
+
+		p.fields = append(p.fields, FieldError{Field: field, Message: fieldMessage(fe)})
+	}
+
+	return p.httpError()
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email"
+	case "id":
+		return "must be a valid ID"
+	case "status":
+		return "must be a valid payment status"
+	case "oneof":
+		return "must be one of: " + fe.Param()
+	case "min", "gte":
+		return "must be at least " + fe.Param()
+	case "max", "lte":
+		return "must be at most " + fe.Param()
+	default:
+		return fmt.Sprintf("must satisfy %s=%s", fe.Tag(), fe.Param())
+	}
+}
+
+// statusCode returns code for errors which are described by the status only, e.g. "too_many_requests"
+func statusCode(status int) string {
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
+
+// HandleError writes errors returned by handlers and middlewares as Problem
+func HandleError(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	var p *problemError
+	if !errors.As(err, &p) {
+		p = &problemError{status: http.StatusInternalServerError, detail: "internal server error"}
+
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			p = &problemError{status: httpErr.Code, detail: fmt.Sprint(httpErr.Message)}
+		}
+
+		p.code = statusCode(p.status)
+
+		// handlers log their errors, errors of middlewares are logged here unless they are caused by the client
+		if p.status >= http.StatusInternalServerError {
+			slog.Default().Error(err.Error(),
+				slog.String("op", "problem.HandleError"),
+				slog.String("request_id", c.Response().Header().Get(echo.HeaderXRequestID)),
+			)
+		}
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(p.status)
+	} else {
+		c.Response().Header().Set(echo.HeaderContentType, MIMEJSON)
+
+		err = c.JSON(p.status, Problem{
+			Type:      "about:blank",
+			Title:     http.StatusText(p.status),
+			Status:    p.status,
+			Code:      p.code,
+			Detail:    p.detail,
+			RequestID: c.Response().Header().Get(echo.HeaderXRequestID),
+			Errors:    p.fields,
+		})
+	}
+
+	if err != nil {
+		slog.Default().Error(err.Error(), slog.String("op", "problem.HandleError"))
+	}
+}
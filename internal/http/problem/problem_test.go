@@ -0,0 +1,322 @@
+package problem_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Anton-Kraev/gopay"
+	"github.com/Anton-Kraev/gopay/internal/http/problem"
+	"github.com/Anton-Kraev/gopay/internal/validator"
+)
+
+// serve returns the response to the request of the handler, errors are written by problem.HandleError
+func serve(t *testing.T, method string, h echo.HandlerFunc) *httptest.ResponseRecorder {
+	t.Helper()
+
+	e := echo.New()
+	e.HTTPErrorHandler = problem.HandleError
+	e.Use(middleware.RequestIDWithConfig(middleware.RequestIDConfig{Generator: func() string { return "generated" }}))
+	e.Any("/", h)
+
+	req := httptest.NewRequest(method, "/", nil)
+	req.Header.Set(echo.HeaderXRequestID, "request")
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	return rec
+}
+
+// decode checks that the response is problem details and returns them
+func decode(t *testing.T, rec *httptest.ResponseRecorder) problem.Problem {
+	t.Helper()
+
+	assert.Equal(t, problem.MIMEJSON, rec.Header().Get(echo.HeaderContentType))
+
+	var p problem.Problem
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &p))
+
+	assert.Equal(t, "about:blank", p.Type)
+	assert.Equal(t, rec.Code, p.Status)
+	assert.Equal(t, http.StatusText(rec.Code), p.Title)
+	assert.Equal(t, "request", p.RequestID)
+
+	return p
+}
+
+func TestFailed(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+		detail string
+	}{
+		{
+			name:   "not found",
+			err:    fmt.Errorf("storage.Get: %w", gopay.ErrNotFound),
+			status: http.StatusNotFound,
+			code:   "not_found",
+			detail: "get payment failed: " + gopay.ErrNotFound.Error(),
+		},
+		{
+			name:   "already exists",
+			err:    gopay.ErrAlreadyExists,
+			status: http.StatusConflict,
+			code:   "already_exists",
+			detail: "get payment failed: " + gopay.ErrAlreadyExists.Error(),
+		},
+		{
+			name:   "invalid transition",
+			err:    fmt.Errorf("%w: succeeded -> pending", gopay.ErrInvalidTransition),
+			status: http.StatusConflict,
+			code:   "invalid_transition",
+			detail: "get payment failed: " + gopay.ErrInvalidTransition.Error(),
+		},
+		{
+			name:   "provider unavailable",
+			err:    gopay.ErrProviderUnavailable,
+			status: http.StatusBadGateway,
+			code:   "provider_unavailable",
+			detail: "get payment failed: " + gopay.ErrProviderUnavailable.Error(),
+		},
+		{
+			name:   "invalid idempotency key",
+			err:    gopay.ErrInvalidIdempotencyKey,
+			status: http.StatusBadRequest,
+			code:   "invalid_idempotency_key",
+			detail: "get payment failed: " + gopay.ErrInvalidIdempotencyKey.Error(),
+		},
+		{
+			name:   "idempotency key reused",
+			err:    gopay.ErrIdempotencyKeyReused,
+			status: http.StatusUnprocessableEntity,
+			code:   "idempotency_key_reused",
+			detail: "get payment failed: " + gopay.ErrIdempotencyKeyReused.Error(),
+		},
+		{
+			name:   "request in progress",
+			err:    gopay.ErrRequestInProgress,
+			status: http.StatusConflict,
+			code:   "request_in_progress",
+			detail: "get payment failed: " + gopay.ErrRequestInProgress.Error(),
+		},
+		{
+			name:   "unknown error",
+			err:    errors.New("connection refused"),
+			status: http.StatusInternalServerError,
+			code:   "internal_server_error",
+			detail: "get payment failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			httpErr := problem.Failed(tt.err, "get payment failed")
+			assert.Equal(t, tt.status, httpErr.Code)
+
+			rec := serve(t, http.MethodGet, func(echo.Context) error { return httpErr })
+			require.Equal(t, tt.status, rec.Code)
+
+			p := decode(t, rec)
+			assert.Equal(t, tt.code, p.Code)
+			assert.Equal(t, tt.detail, p.Detail)
+			assert.Empty(t, p.Errors)
+		})
+	}
+}
+
+type validationRequest struct {
+	User struct {
+		Email string `json:"email" validate:"required,email"`
+	} `json:"user"`
+	ID     string `query:"id" validate:"id"`
+	Status string `json:"status" validate:"omitempty,status"`
+	Sort   string `json:"sort" validate:"oneof=asc desc"`
+	Limit  int    `form:"limit" validate:"min=1,max=100"`
+	Note   string `validate:"len=3"`
+}
+
+func TestValidation(t *testing.T) {
+	t.Parallel()
+
+	v, err := validator.NewValidator()
+	require.NoError(t, err)
+
+	valid := validationRequest{ID: "bc1f9e3e-8f6e-4b49-8f7a-4bb0b9a1e3a5", Status: "pending", Sort: "asc", Limit: 10, Note: "abc"}
+	valid.User.Email = "user@example.com"
+
+	tests := []struct {
+		name     string
+		update   func(r *validationRequest)
+		expected []problem.FieldError
+	}{
+		{
+			name:   "required",
+			update: func(r *validationRequest) { r.User.Email = "" },
+			expected: []problem.FieldError{
+				{Field: "user.email", Message: "is required"},
+			},
+		},
+		{
+			name: "custom validations",
+			update: func(r *validationRequest) {
+				r.User.Email = "user"
+				r.ID = "1"
+				r.Status = "paid"
+			},
+			expected: []problem.FieldError{
+				{Field: "user.email", Message: "must be a valid email"},
+				{Field: "id", Message: "must be a valid ID"},
+				{Field: "status", Message: "must be a valid payment status"},
+			},
+		},
+		{
+			name: "parameters",
+			update: func(r *validationRequest) {
+				r.Sort = "random"
+				r.Limit = 1000
+				r.Note = "a"
+			},
+			expected: []problem.FieldError{
+				{Field: "sort", Message: "must be one of: asc desc"},
+				{Field: "limit", Message: "must be at most 100"},
+				{Field: "Note", Message: "must satisfy len=3"},
+			},
+		},
+		{
+			name:   "minimum",
+			update: func(r *validationRequest) { r.Limit = 0 },
+			expected: []problem.FieldError{
+				{Field: "limit", Message: "must be at least 1"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := valid
+			tt.update(&req)
+
+			rec := serve(t, http.MethodPost, func(echo.Context) error { return problem.Validation(v.Validate(req)) })
+			require.Equal(t, http.StatusBadRequest, rec.Code)
+
+			p := decode(t, rec)
+			assert.Equal(t, "validation_failed", p.Code)
+			assert.Equal(t, "invalid request", p.Detail)
+			assert.Equal(t, tt.expected, p.Errors)
+		})
+	}
+
+	t.Run("not validation error", func(t *testing.T) {
+		t.Parallel()
+
+		rec := serve(t, http.MethodPost, func(echo.Context) error { return problem.Validation(errors.New("bad json")) })
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+
+		p := decode(t, rec)
+		assert.Equal(t, "validation_failed", p.Code)
+		assert.Empty(t, p.Errors)
+	})
+}
+
+func TestHandleError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+		detail string
+	}{
+		{
+			name:   "problem",
+			err:    problem.New(http.StatusTooManyRequests, "too many requests, retry later"),
+			status: http.StatusTooManyRequests,
+			code:   "too_many_requests",
+			detail: "too many requests, retry later",
+		},
+		{
+			name:   "wrapped problem",
+			err:    fmt.Errorf("middleware: %w", problem.Disabled("files are disabled")),
+			status: http.StatusNotFound,
+			code:   "disabled",
+			detail: "files are disabled",
+		},
+		{
+			name:   "echo error",
+			err:    echo.NewHTTPError(http.StatusUnauthorized, "missing key in request header"),
+			status: http.StatusUnauthorized,
+			code:   "unauthorized",
+			detail: "missing key in request header",
+		},
+		{
+			name:   "echo sentinel error",
+			err:    echo.ErrMethodNotAllowed,
+			status: http.StatusMethodNotAllowed,
+			code:   "method_not_allowed",
+			detail: "Method Not Allowed",
+		},
+		{
+			name:   "unknown error",
+			err:    errors.New("connection refused"),
+			status: http.StatusInternalServerError,
+			code:   "internal_server_error",
+			detail: "internal server error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := serve(t, http.MethodGet, func(echo.Context) error { return tt.err })
+			require.Equal(t, tt.status, rec.Code)
+
+			p := decode(t, rec)
+			assert.Equal(t, tt.code, p.Code)
+			assert.Equal(t, tt.detail, p.Detail)
+
+			// the error does not leak to the client
+			assert.NotContains(t, rec.Body.String(), "connection refused")
+		})
+	}
+
+	t.Run("head request", func(t *testing.T) {
+		t.Parallel()
+
+		rec := serve(t, http.MethodHead, func(echo.Context) error { return problem.New(http.StatusNotFound, "not found") })
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Empty(t, rec.Body.String())
+	})
+
+	t.Run("committed response", func(t *testing.T) {
+		t.Parallel()
+
+		rec := serve(t, http.MethodGet, func(c echo.Context) error {
+			if err := c.String(http.StatusOK, "partial"); err != nil {
+				return err
+			}
+
+			return errors.New("write failed")
+		})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "partial", rec.Body.String())
+	})
+}
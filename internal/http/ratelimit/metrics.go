@@ -0,0 +1,63 @@
+package ratelimit
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Metrics counts requests rejected by limiters, it is shared by all limiters of the server
+type Metrics struct {
+	mu       sync.Mutex
+	rejected map[string]uint64
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{rejected: make(map[string]uint64)}
+}
+
+// Rejected returns numbers of rejected requests by limiter names
+func (m *Metrics) Rejected() map[string]uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return maps.Clone(m.rejected)
+}
+
+// Handler writes metrics in Prometheus text format
+func (m *Metrics) Handler(c echo.Context) error {
+	rejected := m.Rejected()
+
+	var sb strings.Builder
+
+	sb.WriteString("# HELP gopay_rate_limited_requests_total Requests rejected by rate limits.\n")
+	sb.WriteString("# TYPE gopay_rate_limited_requests_total counter\n")
+
+	for _, name := range slices.Sorted(maps.Keys(rejected)) {
+		fmt.Fprintf(&sb, "gopay_rate_limited_requests_total{limiter=%q} %d\n", name, rejected[name])
+	}
+
+	return c.Blob(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(sb.String()))
+}
+
+// register adds the limiter with zero counter, so it is exported before the first rejection
+func (m *Metrics) register(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.rejected[name]; !ok {
+		m.rejected[name] = 0
+	}
+}
+
+func (m *Metrics) reject(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.rejected[name]++
+}
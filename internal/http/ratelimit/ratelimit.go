@@ -0,0 +1,157 @@
+// Package ratelimit limits requests with token buckets kept in memory, rejected requests get
+// 429 response with Retry-After header and are counted in Metrics
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/time/rate"
+
+	"github.com/Anton-Kraev/gopay/internal/http/problem"
+)
+
+// defaultExpiresIn is the time after which buckets of inactive keys are deleted
+const defaultExpiresIn = 10 * time.Minute
+
+// Limit is a token bucket, Rate tokens are added per second up to Burst, every request takes one token
+type Limit struct {
+	Rate  rate.Limit
+	Burst int
+}
+
+// Enabled reports whether the limit is set, zero rate disables it
+func (l Limit) Enabled() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
+
+type Config struct {
+	// Name identifies the limiter in metrics
+	Name  string
+	Limit Limit
+	// Key returns the key of the bucket, e.g. client IP or resource ID
+	Key func(c echo.Context) string
+	// Skip allows requests without taking tokens
+	Skip func(c echo.Context) bool
+	// ExpiresIn is the time after which buckets of inactive keys are deleted, 10 minutes by default
+	ExpiresIn time.Duration
+	// Now returns the current time, time.Now by default
+	Now func() time.Time
+}
+
+// ByIP keys buckets by client IP, it is extracted by echo.Echo.IPExtractor
+func ByIP(c echo.Context) string {
+	return c.RealIP()
+}
+
+// ByParam keys buckets by path parameter, e.g. ID of the resource
+func ByParam(name string) func(c echo.Context) string {
+	return func(c echo.Context) string {
+		return c.Param(name)
+	}
+}
+
+// New returns middleware which rejects requests when the bucket of their key is empty,
+// it allows all requests if the limit is not enabled
+func New(config Config, metrics *Metrics) echo.MiddlewareFunc {
+	if !config.Limit.Enabled() {
+		return func(next echo.HandlerFunc) echo.HandlerFunc { return next }
+	}
+
+	if config.ExpiresIn <= 0 {
+		config.ExpiresIn = defaultExpiresIn
+	}
+
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+
+	s := newStore(config.Limit, config.ExpiresIn, config.Now)
+	metrics.register(config.Name)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skip != nil && config.Skip(c) {
+				return next(c)
+			}
+
+			if wait, ok := s.take(config.Key(c)); !ok {
+				metrics.reject(config.Name)
+
+				c.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+
+				return problem.New(http.StatusTooManyRequests, "too many requests, retry later")
+			}
+
+			return next(c)
+		}
+	}
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+type store struct {
+	mu          sync.Mutex
+	limit       Limit
+	expiresIn   time.Duration
+	buckets     map[string]*bucket
+	lastCleanup time.Time
+	now         func() time.Time
+}
+
+func newStore(limit Limit, expiresIn time.Duration, now func() time.Time) *store {
+	return &store{
+		limit:       limit,
+		expiresIn:   expiresIn,
+		buckets:     make(map[string]*bucket),
+		lastCleanup: now(),
+		now:         now,
+	}
+}
+
+// take takes a token from the bucket of the key or returns the time until the next token
+func (s *store) take(key string) (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(s.limit.Rate, s.limit.Burst)}
+		s.buckets[key] = b
+	}
+
+	b.lastSeen = now
+
+	if now.Sub(s.lastCleanup) > s.expiresIn {
+		s.cleanup(now)
+	}
+
+	r := b.limiter.ReserveN(now, 1)
+	if wait := r.DelayFrom(now); wait > 0 {
+		// the token is returned, so rejected requests do not delay the following ones
+		r.CancelAt(now)
+
+		return wait, false
+	}
+
+	return 0, true
+}
+
+func (s *store) cleanup(now time.Time) {
+	for key, b := range s.buckets {
+		if now.Sub(b.lastSeen) > s.expiresIn {
+			delete(s.buckets, key)
+		}
+	}
+
+	s.lastCleanup = now
+}
@@ -0,0 +1,161 @@
+package ratelimit_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+
+	"github.com/Anton-Kraev/gopay/internal/http/problem"
+	"github.com/Anton-Kraev/gopay/internal/http/ratelimit"
+)
+
+// request is sent after the clock is advanced by wait, retryAfter is checked if it is not empty
+type request struct {
+	wait       time.Duration
+	key        string
+	status     int
+	retryAfter string
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	hourly := ratelimit.Limit{Rate: rate.Every(time.Hour), Burst: 1}
+
+	tests := []struct {
+		name      string
+		limit     ratelimit.Limit
+		expiresIn time.Duration
+		requests  []request
+	}{
+		{
+			name:  "burst",
+			limit: ratelimit.Limit{Rate: 1, Burst: 3},
+			requests: []request{
+				{key: "a", status: http.StatusOK},
+				{key: "a", status: http.StatusOK},
+				{key: "a", status: http.StatusOK},
+				{key: "a", status: http.StatusTooManyRequests, retryAfter: "1"},
+			},
+		},
+		{
+			name:  "refill",
+			limit: ratelimit.Limit{Rate: 0.5, Burst: 1},
+			requests: []request{
+				{key: "a", status: http.StatusOK},
+				{key: "a", status: http.StatusTooManyRequests, retryAfter: "2"},
+				{wait: 500 * time.Millisecond, key: "a", status: http.StatusTooManyRequests, retryAfter: "2"},
+				{wait: time.Second, key: "a", status: http.StatusTooManyRequests, retryAfter: "1"},
+				// rejected requests do not take tokens
+				{wait: 500 * time.Millisecond, key: "a", status: http.StatusOK},
+				{wait: 10 * time.Second, key: "a", status: http.StatusOK},
+				{key: "a", status: http.StatusTooManyRequests, retryAfter: "2"},
+			},
+		},
+		{
+			name:  "keys have own buckets",
+			limit: hourly,
+			requests: []request{
+				{key: "a", status: http.StatusOK},
+				{key: "b", status: http.StatusOK},
+				{key: "a", status: http.StatusTooManyRequests, retryAfter: "3600"},
+			},
+		},
+		{
+			name:      "inactive buckets are evicted",
+			limit:     hourly,
+			expiresIn: time.Minute,
+			requests: []request{
+				{key: "a", status: http.StatusOK},
+				{key: "a", status: http.StatusTooManyRequests},
+				// request of another key deletes the bucket of the inactive key
+				{wait: 2 * time.Minute, key: "b", status: http.StatusOK},
+				{key: "a", status: http.StatusOK},
+			},
+		},
+		{
+			name:      "active buckets are kept",
+			limit:     hourly,
+			expiresIn: time.Minute,
+			requests: []request{
+				{key: "a", status: http.StatusOK},
+				{wait: 50 * time.Second, key: "a", status: http.StatusTooManyRequests},
+				{wait: 50 * time.Second, key: "b", status: http.StatusOK},
+				{key: "a", status: http.StatusTooManyRequests, retryAfter: "3500"},
+			},
+		},
+		{
+			name:  "skipped requests",
+			limit: hourly,
+			requests: []request{
+				{key: "skip", status: http.StatusOK},
+				{key: "skip", status: http.StatusOK},
+			},
+		},
+		{
+			name:  "disabled",
+			limit: ratelimit.Limit{Rate: 0, Burst: 1},
+			requests: []request{
+				{key: "a", status: http.StatusOK},
+				{key: "a", status: http.StatusOK},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			now := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+			metrics := ratelimit.NewMetrics()
+
+			e := echo.New()
+			e.HTTPErrorHandler = problem.HandleError
+			e.GET("/", func(c echo.Context) error { return c.NoContent(http.StatusOK) },
+				ratelimit.New(ratelimit.Config{
+					Name:      "test",
+					Limit:     tt.limit,
+					Key:       func(c echo.Context) string { return c.Request().Header.Get("X-Key") },
+					Skip:      func(c echo.Context) bool { return c.Request().Header.Get("X-Key") == "skip" },
+					ExpiresIn: tt.expiresIn,
+					Now:       func() time.Time { return now },
+				}, metrics))
+
+			var rejected uint64
+
+			for i, r := range tt.requests {
+				now = now.Add(r.wait)
+
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("X-Key", r.key)
+				rec := httptest.NewRecorder()
+
+				e.ServeHTTP(rec, req)
+
+				require.Equal(t, r.status, rec.Code, "request %d", i)
+
+				if r.status == http.StatusTooManyRequests {
+					rejected++
+
+					assert.NotEmpty(t, rec.Header().Get("Retry-After"), "request %d", i)
+				}
+
+				if r.retryAfter != "" {
+					assert.Equal(t, r.retryAfter, rec.Header().Get("Retry-After"), "request %d", i)
+				}
+			}
+
+			if tt.limit.Enabled() {
+				assert.Equal(t, map[string]uint64{"test": rejected}, metrics.Rejected())
+			} else {
+				assert.Empty(t, metrics.Rejected())
+			}
+		})
+	}
+}
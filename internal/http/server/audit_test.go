@@ -0,0 +1,101 @@
+package server_test
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/Anton-Kraev/gopay"
+	"github.com/Anton-Kraev/gopay/internal/http/handler"
+	"github.com/Anton-Kraev/gopay/internal/http/handlerv2"
+	"github.com/Anton-Kraev/gopay/internal/http/server"
+	"github.com/Anton-Kraev/gopay/internal/validator"
+	"github.com/Anton-Kraev/gopay/mocks"
+)
+
+// botKeys authenticates the bot key which has no admin scope
+type botKeys struct{}
+
+func (botKeys) Authenticate(key string) (gopay.APIKey, bool, error) {
+	return gopay.APIKey{ID: "bot", Scopes: []gopay.Scope{gopay.ScopePaymentsRead}}, key == "secret", nil
+}
+
+func TestServer_RecordDenial(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		key    string
+		body   string
+		status int
+	}{
+		{
+			name:   "recorded for any key",
+			key:    "secret",
+			body:   `{"command":"/new","scope":"payments:write"}`,
+			status: http.StatusNoContent,
+		},
+		{
+			name:   "invalid request",
+			key:    "secret",
+			body:   `{"command":"/new"}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "missing key",
+			body:   `{"command":"/new","scope":"payments:write"}`,
+			status: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			storage := mocks.NewMockAuditStorage(ctrl)
+
+			if tt.status == http.StatusNoContent {
+				storage.EXPECT().AppendAuditEntry(gomock.Any()).
+					DoAndReturn(func(entry gopay.AuditEntry) (gopay.AuditEntry, error) {
+						assert.Equal(t, gopay.Actor{Name: "api_key:bot", OnBehalfOf: "telegram:1"}, entry.Actor)
+						assert.Equal(t, gopay.AuditAccessDenied, entry.Action)
+						assert.Equal(t, "command:/new", entry.Target)
+						assert.JSONEq(t, `{"scope":"payments:write"}`, string(entry.After))
+
+						return entry, nil
+					}).Times(1)
+			}
+
+			v, err := validator.NewValidator()
+			require.NoError(t, err)
+
+			auditLog := gopay.NewAuditLog(storage)
+			srv := server.NewServer(
+				handler.NewHandler(nil, nil, nil, nil, nil, auditLog, nil), handlerv2.Handler{},
+				slog.New(slog.NewTextHandler(io.Discard, nil)), v, "", botKeys{}, auditLog, server.RateLimits{},
+			)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/audit/denials", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			req.Header.Set(gopay.HeaderActor, "telegram:1")
+
+			if tt.key != "" {
+				req.Header.Set(echo.HeaderAuthorization, "Bearer "+tt.key)
+			}
+
+			rec := httptest.NewRecorder()
+			srv.InitRoutes().ServeHTTP(rec, req)
+
+			require.Equal(t, tt.status, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"net"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/time/rate"
+
+	"github.com/Anton-Kraev/gopay/internal/http/ratelimit"
+)
+
+// purchases recovery and library login forms send emails, so they are limited per client IP
+var recoverLimit = ratelimit.Limit{Rate: rate.Limit(1.0 / 60), Burst: 3}
+
+// RateLimits configures limits of public routes, disabled limits allow everything
+type RateLimits struct {
+	// IP limits requests of each client to each group of public routes: redirects, files and webhooks
+	IP ratelimit.Limit
+	// File limits requests of all clients to each file, file contents are proxied from MinIO
+	File ratelimit.Limit
+	// WebhookAllowlist contains networks of the payment provider, its webhooks are not limited
+	WebhookAllowlist []*net.IPNet
+	// TrustedProxies may pass client IP in X-Forwarded-For, otherwise the IP of the connection is used
+	TrustedProxies []*net.IPNet
+}
+
+// publicLimits are middlewares of public route groups, file limits are shared by API versions
+type publicLimits struct {
+	redirect []echo.MiddlewareFunc
+	checkout []echo.MiddlewareFunc
+	file     []echo.MiddlewareFunc
+}
+
+func (s Server) newPublicLimits() publicLimits {
+	return publicLimits{
+		redirect: []echo.MiddlewareFunc{
+			s.newRateLimiter("redirect_ip", s.limits.IP, ratelimit.ByIP, nil),
+		},
+		checkout: []echo.MiddlewareFunc{
+			s.newRateLimiter("checkout_ip", s.limits.IP, ratelimit.ByIP, s.isWebhookAllowed),
+		},
+		file: []echo.MiddlewareFunc{
+			s.newRateLimiter("file_ip", s.limits.IP, ratelimit.ByIP, nil),
+			s.newRateLimiter("file", s.limits.File, ratelimit.ByParam("id"), nil),
+		},
+	}
+}
+
+func (s Server) newRecoverRateLimiter(name string) echo.MiddlewareFunc {
+	return ratelimit.New(ratelimit.Config{
+		Name:      name,
+		Limit:     recoverLimit,
+		Key:       ratelimit.ByIP,
+		ExpiresIn: time.Hour,
+	}, s.metrics)
+}
+
+func (s Server) newRateLimiter(
+	name string,
+	limit ratelimit.Limit,
+	key func(c echo.Context) string,
+	skip func(c echo.Context) bool,
+) echo.MiddlewareFunc {
+	return ratelimit.New(ratelimit.Config{
+		Name:  name,
+		Limit: limit,
+		Key:   key,
+		Skip:  skip,
+	}, s.metrics)
+}
+
+func (s Server) isWebhookAllowed(c echo.Context) bool {
+	ip := net.ParseIP(c.RealIP())
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range s.limits.WebhookAllowlist {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ipExtractor trusts X-Forwarded-For only from trusted proxies, so clients can not bypass limits by the header
+func (s Server) ipExtractor() echo.IPExtractor {
+	if len(s.limits.TrustedProxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+
+	options := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+
+	for _, network := range s.limits.TrustedProxies {
+		options = append(options, echo.TrustIPRange(network))
+	}
+
+	return echo.ExtractIPFromXFFHeader(options...)
+}
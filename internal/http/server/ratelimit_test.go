@@ -0,0 +1,169 @@
+package server_test
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+
+	"github.com/Anton-Kraev/gopay/internal/http/handler"
+	"github.com/Anton-Kraev/gopay/internal/http/handlerv2"
+	"github.com/Anton-Kraev/gopay/internal/http/ratelimit"
+	"github.com/Anton-Kraev/gopay/internal/http/server"
+	"github.com/Anton-Kraev/gopay/internal/validator"
+)
+
+// publicHandlers answers public routes without dependencies, other routes are not requested
+type publicHandlers struct {
+	handler.Handler
+}
+
+func (publicHandlers) Redirect(c echo.Context) error { return c.NoContent(http.StatusOK) }
+
+func (publicHandlers) Checkout(c echo.Context) error { return c.NoContent(http.StatusOK) }
+
+type clientRequest struct {
+	remoteAddr string
+	xff        string
+	status     int
+}
+
+func TestServer_RateLimits(t *testing.T) {
+	t.Parallel()
+
+	parseCIDR := func(s string) *net.IPNet {
+		_, network, err := net.ParseCIDR(s)
+		require.NoError(t, err)
+
+		return network
+	}
+
+	provider := []*net.IPNet{parseCIDR("185.71.76.0/27")}
+	proxies := []*net.IPNet{parseCIDR("10.0.0.0/8")}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		proxies  []*net.IPNet
+		requests []clientRequest
+	}{
+		{
+			name:   "webhook from allowlist",
+			method: http.MethodPost,
+			path:   "/api/checkout",
+			requests: []clientRequest{
+				{remoteAddr: "185.71.76.1:1000", status: http.StatusOK},
+				{remoteAddr: "185.71.76.1:1000", status: http.StatusOK},
+			},
+		},
+		{
+			name:   "webhook from other network",
+			method: http.MethodPost,
+			path:   "/api/checkout",
+			requests: []clientRequest{
+				{remoteAddr: "203.0.113.1:1000", status: http.StatusOK},
+				{remoteAddr: "203.0.113.1:1000", status: http.StatusTooManyRequests},
+			},
+		},
+		{
+			name:   "allowlist bypasses only webhooks",
+			method: http.MethodGet,
+			path:   "/api/link",
+			requests: []clientRequest{
+				{remoteAddr: "185.71.76.1:1000", status: http.StatusOK},
+				{remoteAddr: "185.71.76.1:1000", status: http.StatusTooManyRequests},
+			},
+		},
+		{
+			name:   "X-Forwarded-For without trusted proxies",
+			method: http.MethodGet,
+			path:   "/api/link",
+			requests: []clientRequest{
+				{remoteAddr: "127.0.0.1:1000", xff: "203.0.113.1", status: http.StatusOK},
+				{remoteAddr: "127.0.0.1:1000", xff: "203.0.113.2", status: http.StatusTooManyRequests},
+			},
+		},
+		{
+			name:    "X-Forwarded-For from untrusted client",
+			method:  http.MethodGet,
+			path:    "/api/link",
+			proxies: proxies,
+			requests: []clientRequest{
+				{remoteAddr: "203.0.113.1:1000", xff: "198.51.100.1", status: http.StatusOK},
+				{remoteAddr: "203.0.113.1:1000", xff: "198.51.100.2", status: http.StatusTooManyRequests},
+			},
+		},
+		{
+			name:    "X-Forwarded-For from trusted proxy",
+			method:  http.MethodGet,
+			path:    "/api/link",
+			proxies: proxies,
+			requests: []clientRequest{
+				{remoteAddr: "10.0.0.1:1000", xff: "198.51.100.1", status: http.StatusOK},
+				{remoteAddr: "10.0.0.1:1000", xff: "198.51.100.2", status: http.StatusOK},
+				{remoteAddr: "10.0.0.2:1000", xff: "198.51.100.1", status: http.StatusTooManyRequests},
+			},
+		},
+		{
+			name:    "webhook through trusted proxy",
+			method:  http.MethodPost,
+			path:    "/api/checkout",
+			proxies: proxies,
+			requests: []clientRequest{
+				{remoteAddr: "10.0.0.1:1000", xff: "185.71.76.1", status: http.StatusOK},
+				{remoteAddr: "10.0.0.1:1000", xff: "185.71.76.1", status: http.StatusOK},
+			},
+		},
+		{
+			name:    "webhook spoofed by untrusted client",
+			method:  http.MethodPost,
+			path:    "/api/checkout",
+			proxies: proxies,
+			requests: []clientRequest{
+				{remoteAddr: "203.0.113.1:1000", xff: "185.71.76.1", status: http.StatusOK},
+				{remoteAddr: "203.0.113.1:1000", xff: "185.71.76.1", status: http.StatusTooManyRequests},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			v, err := validator.NewValidator()
+			require.NoError(t, err)
+
+			srv := server.NewServer(
+				publicHandlers{}, handlerv2.Handler{}, slog.New(slog.NewTextHandler(io.Discard, nil)), v, "", nil, nil,
+				server.RateLimits{
+					IP:               ratelimit.Limit{Rate: rate.Every(time.Hour), Burst: 1},
+					WebhookAllowlist: provider,
+					TrustedProxies:   tt.proxies,
+				},
+			)
+			e := srv.InitRoutes()
+
+			for i, r := range tt.requests {
+				req := httptest.NewRequest(tt.method, tt.path, nil)
+				req.RemoteAddr = r.remoteAddr
+
+				if r.xff != "" {
+					req.Header.Set(echo.HeaderXForwardedFor, r.xff)
+				}
+
+				rec := httptest.NewRecorder()
+				e.ServeHTTP(rec, req)
+
+				require.Equal(t, r.status, rec.Code, "request %d", i)
+			}
+		})
+	}
+}
@@ -1,15 +1,23 @@
 package server
 
 import (
+	"crypto/subtle"
+	"errors"
 	"log/slog"
+	"net/http"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	slogecho "github.com/samber/slog-echo"
 	swagecho "github.com/swaggo/echo-swagger"
 
+	"github.com/Anton-Kraev/gopay"
 	// Register generated Swagger docs
 	_ "github.com/Anton-Kraev/gopay/docs"
+	_ "github.com/Anton-Kraev/gopay/docs/v2"
+	"github.com/Anton-Kraev/gopay/internal/http/audit"
+	"github.com/Anton-Kraev/gopay/internal/http/problem"
+	"github.com/Anton-Kraev/gopay/internal/http/ratelimit"
 	"github.com/Anton-Kraev/gopay/internal/validator"
 )
 
@@ -17,53 +25,235 @@ type handlers interface {
 	NewPayment(c echo.Context) error
 	AllPayment(c echo.Context) error
 	GetPayment(c echo.Context) error
+	GetPaymentDetails(c echo.Context) error
+	RefundPayment(c echo.Context) error
 	Redirect(c echo.Context) error
 	Checkout(c echo.Context) error
 	File(c echo.Context) error
+	FileVersions(c echo.Context) error
+	UploadFile(c echo.Context) error
+	Unsubscribe(c echo.Context) error
+	RecoverForm(c echo.Context) error
+	RecoverPurchases(c echo.Context) error
+	ResendPurchases(c echo.Context) error
+	Library(c echo.Context) error
+	LibraryPurchases(c echo.Context) error
+	LibraryRequestLogin(c echo.Context) error
+	LibraryLoginPage(c echo.Context) error
+	LibraryLogin(c echo.Context) error
+	Backup(c echo.Context) error
+	AuditLog(c echo.Context) error
+	RecordDenial(c echo.Context) error
+	PreviewTemplate(c echo.Context) error
+}
+
+type handlersV2 interface {
+	CreatePayment(c echo.Context) error
+	ListPayments(c echo.Context) error
+	GetPayment(c echo.Context) error
+	GetPaymentStatus(c echo.Context) error
+	ResendPurchases(c echo.Context) error
+	ListFileVersions(c echo.Context) error
+	GetFileVersion(c echo.Context) error
+	PublishFileVersion(c echo.Context) error
+}
+
+type apiKeyAuthenticator interface {
+	Authenticate(key string) (gopay.APIKey, bool, error)
 }
 
 type Server struct {
-	handlers  handlers
-	logger    *slog.Logger
-	validator *validator.Validator
+	handlers   handlers
+	handlersV2 handlersV2
+	logger     *slog.Logger
+	validator  *validator.Validator
+	adminToken string
+	apiKeys    apiKeyAuthenticator // nil if the storage does not keep API keys
+	auditLog   *gopay.AuditLog     // nil if the storage does not keep the audit log
+	limits     RateLimits
+	metrics    *ratelimit.Metrics
 }
 
-func NewServer(handlers handlers, logger *slog.Logger, validator *validator.Validator) Server {
+// NewServer creates server, admin routes are accessible only with API keys or adminToken,
+// the token is not accepted if it is empty, denied requests are recorded in auditLog,
+// public routes are limited by limits
+func NewServer(
+	handlers handlers,
+	handlersV2 handlersV2,
+	logger *slog.Logger,
+	validator *validator.Validator,
+	adminToken string,
+	apiKeys apiKeyAuthenticator,
+	auditLog *gopay.AuditLog,
+	limits RateLimits,
+) Server {
 	return Server{
-		handlers:  handlers,
-		logger:    logger,
-		validator: validator,
+		handlers:   handlers,
+		handlersV2: handlersV2,
+		logger:     logger,
+		validator:  validator,
+		adminToken: adminToken,
+		apiKeys:    apiKeys,
+		auditLog:   auditLog,
+		limits:     limits,
+		metrics:    ratelimit.NewMetrics(),
 	}
 }
 
 // InitRoutes init API routes and middlewares
 // @title GoPay API
 // @version 1.0
-// @description API for payment processing and digital goods access management
+// @description API for payment processing and digital goods access management.
+// @description Errors are returned as application/problem+json (RFC 7807) with the machine-readable code,
+// @description invalid fields of the request and the request ID.
 // @license.name MIT license
 // @license.url https://opensource.org/licenses/MIT
 // @contact.name Author's contact
 // @contact.url https://t.me/iksvayai
 // @BasePath /api
+// @securityDefinitions.apikey APIKey
+// @in header
+// @name Authorization
+// @description API key or admin token with "Bearer " prefix, API keys allow only routes of their scopes
 func (s Server) InitRoutes() *echo.Echo {
 	e := echo.New()
 
 	e.Validator = s.validator
+	e.HTTPErrorHandler = problem.HandleError
+	e.IPExtractor = s.ipExtractor()
 
 	e.Use(middleware.Recover())
 	e.Use(middleware.RequestID())
 	e.Use(slogecho.New(s.logger))
 
+	e.GET("/swagger/v2/*", swagecho.EchoWrapHandler(swagecho.InstanceName("v2")))
 	e.GET("/swagger/*", swagecho.WrapHandler)
 
 	g := e.Group("/api")
+	auth := s.adminAuth()
+	allow := func(scope gopay.Scope) echo.MiddlewareFunc { return s.requireScope(auth, scope) }
+	limits := s.newPublicLimits()
+
+	g.POST("/payments", s.handlers.NewPayment, allow(gopay.ScopePaymentsWrite))
+	g.GET("/payments", s.handlers.AllPayment, allow(gopay.ScopePaymentsRead))
+	g.GET("/payments/:id", s.handlers.GetPayment, allow(gopay.ScopePaymentsRead))
+	g.GET("/payments/:id/details", s.handlers.GetPaymentDetails, allow(gopay.ScopePaymentsRead))
+	g.POST("/payments/:id/refund", s.handlers.RefundPayment, allow(gopay.ScopeRefunds))
+	g.GET("/unsubscribe", s.handlers.Unsubscribe)
+	g.GET("/recover", s.handlers.RecoverForm)
+	g.POST("/recover", s.handlers.RecoverPurchases, s.newRecoverRateLimiter("recover_ip"))
+	g.POST("/customers/resend", s.handlers.ResendPurchases, allow(gopay.ScopePurchasesResend))
+	g.GET("/library", s.handlers.Library)
+	g.GET("/library/purchases", s.handlers.LibraryPurchases)
+	g.POST("/library/login", s.handlers.LibraryRequestLogin, s.newRecoverRateLimiter("library_login_ip"))
+	g.GET("/library/login", s.handlers.LibraryLoginPage)
+	g.POST("/library/session", s.handlers.LibraryLogin)
+	g.GET("/:id", s.handlers.Redirect, limits.redirect...)
+	g.POST("/checkout", s.handlers.Checkout, limits.checkout...)
+	g.GET("/files/:id", s.handlers.File, limits.file...)
+	g.GET("/files/:id/versions", s.handlers.FileVersions, allow(gopay.ScopeFiles))
+	g.POST("/files/:id", s.handlers.UploadFile, allow(gopay.ScopeFiles))
+	g.GET("/templates/:product_id/:name", s.handlers.PreviewTemplate, allow(gopay.ScopeTemplates))
+
+	g.GET("/audit", s.handlers.AuditLog, allow(gopay.ScopeAdmin))
+	g.POST("/audit/denials", s.handlers.RecordDenial, allow(""))
+
+	admin := g.Group("/admin", allow(gopay.ScopeAdmin))
+	admin.GET("/backup", s.handlers.Backup)
+	admin.GET("/metrics", s.metrics.Handler)
 
-	g.POST("/payments", s.handlers.NewPayment)
-	g.GET("/payments", s.handlers.AllPayment)
-	g.GET("/payments/:id", s.handlers.GetPayment)
-	g.GET("/:id", s.handlers.Redirect)
-	g.POST("/checkout", s.handlers.Checkout)
-	g.GET("/files/:id", s.handlers.File)
+	s.initRoutesV2(g.Group("/v2"), allow, limits)
 
 	return e
 }
+
+// apiKeyContextKey keeps gopay.APIKey of the request, it is not set for requests with admin token
+const apiKeyContextKey = "api_key"
+
+// adminAuth accepts requests with admin token or API key in Authorization header
+func (s Server) adminAuth() echo.MiddlewareFunc {
+	return middleware.KeyAuthWithConfig(middleware.KeyAuthConfig{
+		Validator: func(key string, c echo.Context) (bool, error) {
+			if s.adminToken != "" && subtle.ConstantTimeCompare([]byte(key), []byte(s.adminToken)) == 1 {
+				return true, nil
+			}
+
+			if s.apiKeys == nil {
+				return false, nil
+			}
+
+			apiKey, ok, err := s.apiKeys.Authenticate(key)
+			if err != nil {
+				return false, problem.Failed(err, "check API key failed")
+			}
+
+			if ok {
+				c.Set(apiKeyContextKey, apiKey)
+			}
+
+			return ok, nil
+		},
+		ErrorHandler: func(err error, c echo.Context) error {
+			var httpErr *echo.HTTPError
+			if errors.As(err, &httpErr) {
+				return httpErr
+			}
+
+			c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+
+			var missingErr *middleware.ErrKeyAuthMissing
+			if errors.As(err, &missingErr) {
+				return problem.New(http.StatusUnauthorized, "missing API key")
+			}
+
+			return problem.New(http.StatusUnauthorized, "invalid API key")
+		},
+	})
+}
+
+// requireScope checks the scope of the API key after auth, the admin token allows everything and the empty
+// scope allows any key, the actor of the request is set for handlers and denials are written to the audit log
+func (s Server) requireScope(auth echo.MiddlewareFunc, scope gopay.Scope) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return auth(func(c echo.Context) error {
+			apiKey, isKey := c.Get(apiKeyContextKey).(gopay.APIKey)
+
+			actor := gopay.Actor{Name: gopay.ActorAdminToken}
+			if isKey {
+				actor.Name = gopay.ActorAPIKey(apiKey.ID)
+			}
+
+			// clients like the bot act on behalf of people, the header is only an annotation of the authenticated actor
+			if name := c.Request().Header.Get(gopay.HeaderActor); name != "" {
+				if !gopay.ValidateActor(name) {
+					return problem.New(http.StatusBadRequest, "invalid request: bad "+gopay.HeaderActor+" header")
+				}
+
+				actor.OnBehalfOf = name
+			}
+
+			audit.SetActor(c, actor)
+
+			if !isKey || scope == "" || apiKey.HasScope(scope) {
+				return next(c)
+			}
+
+			s.logger.Warn("access denied",
+				slog.String("audit", "access_denied"),
+				slog.String("key_id", apiKey.ID),
+				slog.String("key_name", apiKey.Name),
+				slog.String("actor", actor.Name),
+				slog.String("on_behalf_of", actor.OnBehalfOf),
+				slog.String("scope", string(scope)),
+				slog.String("method", c.Request().Method),
+				slog.String("route", c.Path()),
+				slog.String("request_id", c.Response().Header().Get(echo.HeaderXRequestID)),
+			)
+
+			audit.Record(c, s.auditLog, gopay.AuditAccessDenied, "route:"+c.Request().Method+" "+c.Path(), nil,
+				map[string]string{"scope": string(scope)})
+
+			return problem.New(http.StatusForbidden, "API key has no scope "+string(scope))
+		})
+	}
+}
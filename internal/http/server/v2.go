@@ -0,0 +1,35 @@
+package server
+
+import (
+	"github.com/labstack/echo/v4"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+// initRoutesV2 init routes of API version 2, its docs are generated separately from this file,
+// like in version 1 only file contents are public
+// @title GoPay API
+// @version 2.0
+// @description API for payment processing and digital goods access management.
+// @description Requests and responses are JSON except file contents, resources are addressed by URL.
+// @description Errors are returned as application/problem+json (RFC 7807) with the machine-readable code,
+// @description invalid fields of the request and the request ID.
+// @license.name MIT license
+// @license.url https://opensource.org/licenses/MIT
+// @contact.name Author's contact
+// @contact.url https://t.me/iksvayai
+// @BasePath /api/v2
+// @securityDefinitions.apikey APIKey
+// @in header
+// @name Authorization
+// @description API key or admin token with "Bearer " prefix, API keys allow only routes of their scopes
+func (s Server) initRoutesV2(g *echo.Group, allow func(scope gopay.Scope) echo.MiddlewareFunc, limits publicLimits) {
+	g.POST("/payments", s.handlersV2.CreatePayment, allow(gopay.ScopePaymentsWrite))
+	g.GET("/payments", s.handlersV2.ListPayments, allow(gopay.ScopePaymentsRead))
+	g.GET("/payments/:id", s.handlersV2.GetPayment, allow(gopay.ScopePaymentsRead)).Name = "v2.payment"
+	g.GET("/payments/:id/status", s.handlersV2.GetPaymentStatus, allow(gopay.ScopePaymentsRead))
+	g.POST("/customers/resend-purchases", s.handlersV2.ResendPurchases, allow(gopay.ScopePurchasesResend))
+	g.GET("/files/:id/versions", s.handlersV2.ListFileVersions, allow(gopay.ScopeFiles))
+	g.GET("/files/:id/versions/:version", s.handlersV2.GetFileVersion, limits.file...).Name = "v2.fileVersion"
+	g.POST("/files/:id/versions", s.handlersV2.PublishFileVersion, allow(gopay.ScopeFiles))
+}
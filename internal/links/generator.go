@@ -3,6 +3,7 @@ package links
 import (
 	"errors"
 	"fmt"
+	"net/url"
 
 	"github.com/google/uuid"
 
@@ -27,10 +28,25 @@ func (g Generator) GenerateLink() (gopay.ID, gopay.Link, error) {
 		return "", "", fmt.Errorf("%s: %w", op, err)
 	}
 
-	link := gopay.Link(fmt.Sprintf("%s/api/%s", g.baseURL, id))
+	link := g.Link(gopay.ID(id.String()))
 	if !link.Validate() {
 		return "", "", fmt.Errorf("%s: %w", op, errGenerateLink)
 	}
 
 	return gopay.ID(id.String()), link, nil
 }
+
+// Link returns redirect link for the existing payment
+func (g Generator) Link(id gopay.ID) gopay.Link {
+	return gopay.Link(fmt.Sprintf("%s/api/%s", g.baseURL, id))
+}
+
+// UnsubscribeLink returns link for unsubscribing from payment reminders
+func (g Generator) UnsubscribeLink(token string) gopay.Link {
+	return gopay.Link(fmt.Sprintf("%s/api/unsubscribe?token=%s", g.baseURL, url.QueryEscape(token)))
+}
+
+// LoginLink returns magic link for login into the customer library
+func (g Generator) LoginLink(token string) gopay.Link {
+	return gopay.Link(fmt.Sprintf("%s/api/library/login?token=%s", g.baseURL, url.QueryEscape(token)))
+}
@@ -20,3 +20,12 @@ func Setup(env string) (logger *slog.Logger) {
 
 	return
 }
+
+// Redact hides the secret in logs, empty secret is kept to show that it is not set
+func Redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+
+	return "[REDACTED]"
+}
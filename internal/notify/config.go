@@ -0,0 +1,8 @@
+package notify
+
+import "time"
+
+type Config struct {
+	Retries    int
+	RetryDelay time.Duration
+}
@@ -0,0 +1,186 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Anton-Kraev/gopay"
+	"github.com/Anton-Kraev/gopay/internal/client/smtp"
+)
+
+type mailSender interface {
+	Send(msg smtp.Message) error
+}
+
+type Email struct {
+	sender     mailSender
+	templates  *Templates
+	retries    int
+	retryDelay time.Duration
+}
+
+func NewEmail(sender mailSender, templates *Templates, config Config) Email {
+	return Email{
+		sender:     sender,
+		templates:  templates,
+		retries:    config.Retries,
+		retryDelay: config.RetryDelay,
+	}
+}
+
+func (e Email) NotifyFileUpdate(ctx context.Context, user gopay.User, update gopay.FileUpdate) error {
+	const op = "notify.Email.NotifyFileUpdate"
+
+	if user.Email == "" {
+		return gopay.ErrNoRecipient
+	}
+
+	msg, err := e.message(user.Email, update.ProductID, tmplFileUpdate, templateData{User: user, Update: update})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = e.send(ctx, msg); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (e Email) NotifyPaymentCreated(event gopay.PaymentEvent) error {
+	return e.notifyPayment("notify.Email.NotifyPaymentCreated", tmplPaymentCreated, event)
+}
+
+func (e Email) NotifyPaymentSucceeded(event gopay.PaymentEvent) error {
+	return e.notifyPayment("notify.Email.NotifyPaymentSucceeded", tmplPaymentSucceeded, event)
+}
+
+// NotifyPaymentReminder sends reminder synchronously, as reminders are sent by background job
+func (e Email) NotifyPaymentReminder(event gopay.PaymentEvent, unsubscribeLink gopay.Link) error {
+	const op = "notify.Email.NotifyPaymentReminder"
+
+	user := event.Payment.User
+	if user.Email == "" {
+		return fmt.Errorf("%s: %w", op, gopay.ErrNoRecipient)
+	}
+
+	msg, err := e.message(user.Email, event.Payment.ProductID, tmplPaymentReminder, templateData{
+		User:            user,
+		ID:              event.ID,
+		Payment:         event.Payment,
+		Link:            event.Link,
+		UnsubscribeLink: unsubscribeLink,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = e.send(context.Background(), msg); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (e Email) NotifyPurchases(user gopay.User, purchases []gopay.Purchase) error {
+	const op = "notify.Email.NotifyPurchases"
+
+	if user.Email == "" {
+		return fmt.Errorf("%s: %w", op, gopay.ErrNoRecipient)
+	}
+
+	msg, err := e.message(user.Email, "", tmplPurchases, templateData{User: user, Purchases: purchases})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = e.send(context.Background(), msg); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (e Email) NotifyLoginLink(user gopay.User, link gopay.Link) error {
+	const op = "notify.Email.NotifyLoginLink"
+
+	if user.Email == "" {
+		return fmt.Errorf("%s: %w", op, gopay.ErrNoRecipient)
+	}
+
+	msg, err := e.message(user.Email, "", tmplLoginLink, templateData{User: user, Link: link})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = e.send(context.Background(), msg); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// notifyPayment renders message synchronously and sends it in background
+// to not delay API responses and provider webhooks with SMTP retries
+func (e Email) notifyPayment(op, tmplName string, event gopay.PaymentEvent) error {
+	user := event.Payment.User
+	if user.Email == "" {
+		return fmt.Errorf("%s: %w", op, gopay.ErrNoRecipient)
+	}
+
+	msg, err := e.message(user.Email, event.Payment.ProductID, tmplName, templateData{
+		User:    user,
+		ID:      event.ID,
+		Payment: event.Payment,
+		Link:    event.Link,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	go func() {
+		if err := e.send(context.Background(), msg); err != nil {
+			slog.Default().Error(
+				fmt.Errorf("%s: %w", op, err).Error(),
+				slog.String("payment_id", string(event.ID)),
+			)
+		}
+	}()
+
+	return nil
+}
+
+func (e Email) message(to string, productID gopay.ID, tmplName string, data templateData) (smtp.Message, error) {
+	m, err := e.templates.render(productID, tmplName, data)
+	if err != nil {
+		return smtp.Message{}, err
+	}
+
+	return smtp.Message{
+		To:      to,
+		Subject: m.Subject,
+		Text:    m.Text,
+		HTML:    m.HTML,
+	}, nil
+}
+
+// send makes up to retries+1 attempts with exponentially growing delay between them
+func (e Email) send(ctx context.Context, msg smtp.Message) error {
+	delay := e.retryDelay
+
+	for attempt := 0; ; attempt++ {
+		err := e.sender.Send(msg)
+		if err == nil || attempt >= e.retries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+			delay *= 2
+		}
+	}
+}
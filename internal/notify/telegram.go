@@ -0,0 +1,44 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+type Telegram struct {
+	bot       *telego.Bot
+	templates *Templates
+}
+
+func NewTelegram(botToken string, templates *Templates) (Telegram, error) {
+	bot, err := telego.NewBot(botToken)
+	if err != nil {
+		return Telegram{}, fmt.Errorf("notify.NewTelegram: %w", err)
+	}
+
+	return Telegram{bot: bot, templates: templates}, nil
+}
+
+func (t Telegram) NotifyFileUpdate(ctx context.Context, user gopay.User, update gopay.FileUpdate) error {
+	const op = "notify.Telegram.NotifyFileUpdate"
+
+	if user.TelegramID == 0 {
+		return gopay.ErrNoRecipient
+	}
+
+	msg, err := t.templates.render(update.ProductID, tmplFileUpdate, templateData{User: user, Update: update})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err = t.bot.SendMessage(ctx, tu.Message(tu.ID(user.TelegramID), msg.Text)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,231 @@
+package notify
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+const (
+	tmplPaymentCreated   = "payment_created"
+	tmplPaymentSucceeded = "payment_succeeded"
+	tmplPaymentReminder  = "payment_reminder"
+	tmplFileUpdate       = "file_update"
+	tmplPurchases        = "purchases"
+	tmplLoginLink        = "login_link"
+)
+
+//go:embed templates
+var defaultTemplates embed.FS
+
+type templateData struct {
+	User    gopay.User
+	ID      gopay.ID
+	Payment gopay.Payment
+	Link    gopay.Link
+	Update  gopay.FileUpdate
+
+	UnsubscribeLink gopay.Link
+	Purchases       []gopay.Purchase
+}
+
+type mail struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+type templateSet struct {
+	text map[string]*texttemplate.Template
+	html map[string]*htmltemplate.Template
+}
+
+// Templates renders notifications, every product can override default templates
+// with files "<dir>/<product_id>/<name>.txt" and "<dir>/<product_id>/<name>.html"
+type Templates struct {
+	defaults templateSet
+	products map[gopay.ID]templateSet
+}
+
+func NewTemplates(dir string) (*Templates, error) {
+	const op = "notify.NewTemplates"
+
+	templatesFS, err := fs.Sub(defaultTemplates, "templates")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	defaults, err := parseTemplateSet(templatesFS)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	t := &Templates{
+		defaults: defaults,
+		products: make(map[gopay.ID]templateSet),
+	}
+
+	if dir == "" {
+		return t, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, entry := range entries {
+		productID := gopay.ID(entry.Name())
+		if !entry.IsDir() || !productID.Validate() {
+			continue
+		}
+
+		set, err := parseTemplateSet(os.DirFS(filepath.Join(dir, entry.Name())))
+		if err != nil {
+			return nil, fmt.Errorf("%s: product %s: %w", op, productID, err)
+		}
+
+		t.products[productID] = set
+	}
+
+	return t, nil
+}
+
+func parseTemplateSet(fsys fs.FS) (templateSet, error) {
+	set := templateSet{
+		text: make(map[string]*texttemplate.Template),
+		html: make(map[string]*htmltemplate.Template),
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return templateSet{}, err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+
+		switch filepath.Ext(name) {
+		case ".txt":
+			tmpl, err := texttemplate.ParseFS(fsys, name)
+			if err != nil {
+				return templateSet{}, err
+			}
+
+			set.text[strings.TrimSuffix(name, ".txt")] = tmpl
+		case ".html":
+			tmpl, err := htmltemplate.ParseFS(fsys, name)
+			if err != nil {
+				return templateSet{}, err
+			}
+
+			set.html[strings.TrimSuffix(name, ".html")] = tmpl
+		}
+	}
+
+	return set, nil
+}
+
+func (t *Templates) render(productID gopay.ID, name string, data templateData) (mail, error) {
+	const op = "notify.Templates.render"
+
+	textTmpl, ok := t.products[productID].text[name]
+	if !ok {
+		textTmpl, ok = t.defaults.text[name]
+	}
+
+	if !ok {
+		return mail{}, fmt.Errorf("%s: %w", op, errors.New("unknown template "+name))
+	}
+
+	htmlTmpl, ok := t.products[productID].html[name]
+	if !ok {
+		htmlTmpl = t.defaults.html[name]
+	}
+
+	var (
+		res mail
+		buf bytes.Buffer
+	)
+
+	if textTmpl.Lookup("subject") != nil {
+		if err := textTmpl.ExecuteTemplate(&buf, "subject", data); err != nil {
+			return mail{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		res.Subject = strings.TrimSpace(buf.String())
+		buf.Reset()
+	}
+
+	if err := textTmpl.Execute(&buf, data); err != nil {
+		return mail{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res.Text = buf.String()
+	buf.Reset()
+
+	if htmlTmpl != nil {
+		if err := htmlTmpl.Execute(&buf, data); err != nil {
+			return mail{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		res.HTML = buf.String()
+	}
+
+	return res, nil
+}
+
+// TemplatePreview is the notification rendered with sample data
+type TemplatePreview struct {
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+	HTML    string `json:"html,omitempty"`
+}
+
+// Preview renders the template of the product with sample data, so overrides of the product can be checked
+// before customers receive them, the error wraps gopay.ErrNotFound for unknown templates
+func (t *Templates) Preview(productID gopay.ID, name string) (TemplatePreview, error) {
+	const op = "notify.Templates.Preview"
+
+	if _, ok := t.defaults.text[name]; !ok {
+		if _, ok = t.products[productID].text[name]; !ok {
+			return TemplatePreview{}, fmt.Errorf("%s: template %s %w", op, name, gopay.ErrNotFound)
+		}
+	}
+
+	payment := gopay.Payment{
+		User:        gopay.User{ID: "user", Name: "Иван Иванов", Email: "customer@example.com"},
+		Amount:      100,
+		Status:      gopay.StatusSucceeded,
+		PaymentLink: "https://example.com/payment",
+		ProductID:   productID,
+		Currency:    "RUB",
+		Description: "Пример товара",
+	}
+
+	res, err := t.render(productID, name, templateData{
+		User:    payment.User,
+		ID:      "payment",
+		Payment: payment,
+		Link:    "https://example.com/link",
+		Update: gopay.FileUpdate{
+			ProductID: productID, Version: 2, Comment: "Пример обновления", Link: "https://example.com/file",
+		},
+		UnsubscribeLink: "https://example.com/unsubscribe",
+		Purchases:       []gopay.Purchase{{ID: "payment", Payment: payment, Link: "https://example.com/link"}},
+	})
+	if err != nil {
+		return TemplatePreview{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return TemplatePreview{Subject: res.Subject, Text: res.Text, HTML: res.HTML}, nil
+}
@@ -0,0 +1,79 @@
+package bolt
+
+import (
+	"encoding/json"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+func (r PaymentRepository) SetAPIKey(key gopay.APIKey) error {
+	binKey, err := json.Marshal(key)
+	if err != nil {
+		return fmt.Errorf("bolt.PaymentRepository.SetAPIKey: %w", err)
+	}
+
+	if err = r.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(apiKeyBucket).Put([]byte(key.ID), binKey)
+	}); err != nil {
+		return fmt.Errorf("bolt.PaymentRepository.SetAPIKey: %w", err)
+	}
+
+	return nil
+}
+
+func (r PaymentRepository) GetAPIKey(id string) (gopay.APIKey, error) {
+	var key gopay.APIKey
+
+	if err := r.db.View(func(tx *bolt.Tx) error {
+		binKey := tx.Bucket(apiKeyBucket).Get([]byte(id))
+		if binKey == nil {
+			return errAPIKeyNotFound
+		}
+
+		return json.Unmarshal(binKey, &key)
+	}); err != nil {
+		return gopay.APIKey{}, fmt.Errorf("bolt.PaymentRepository.GetAPIKey: %w", err)
+	}
+
+	return key, nil
+}
+
+// ListAPIKeys returns keys ordered by ID
+func (r PaymentRepository) ListAPIKeys() ([]gopay.APIKey, error) {
+	var keys []gopay.APIKey
+
+	if err := r.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(apiKeyBucket).ForEach(func(_, v []byte) error {
+			var key gopay.APIKey
+			if err := json.Unmarshal(v, &key); err != nil {
+				return err
+			}
+
+			keys = append(keys, key)
+
+			return nil
+		})
+	}); err != nil {
+		return nil, fmt.Errorf("bolt.PaymentRepository.ListAPIKeys: %w", err)
+	}
+
+	return keys, nil
+}
+
+func (r PaymentRepository) DeleteAPIKey(id string) error {
+	if err := r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(apiKeyBucket)
+		if b.Get([]byte(id)) == nil {
+			return errAPIKeyNotFound
+		}
+
+		return b.Delete([]byte(id))
+	}); err != nil {
+		return fmt.Errorf("bolt.PaymentRepository.DeleteAPIKey: %w", err)
+	}
+
+	return nil
+}
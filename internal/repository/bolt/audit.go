@@ -0,0 +1,121 @@
+package bolt
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+// AppendAuditEntry saves the entry with the next sequence number of the bucket as ID
+func (r PaymentRepository) AppendAuditEntry(entry gopay.AuditEntry) (gopay.AuditEntry, error) {
+	if err := r.db.Update(func(tx *bolt.Tx) error {
+		var err error
+
+		entry, err = appendAuditEntry(tx, entry)
+
+		return err
+	}); err != nil {
+		return gopay.AuditEntry{}, fmt.Errorf("bolt.PaymentRepository.AppendAuditEntry: %w", err)
+	}
+
+	return entry, nil
+}
+
+// AppendAuditEntry saves the entry in the transaction, so it is committed together with the change
+func (t paymentTx) AppendAuditEntry(entry gopay.AuditEntry) (gopay.AuditEntry, error) {
+	entry, err := appendAuditEntry(t.tx, entry)
+	if err != nil {
+		return gopay.AuditEntry{}, fmt.Errorf("bolt.paymentTx.AppendAuditEntry: %w", err)
+	}
+
+	return entry, nil
+}
+
+func appendAuditEntry(tx *bolt.Tx, entry gopay.AuditEntry) (gopay.AuditEntry, error) {
+	b := tx.Bucket(auditBucket)
+
+	id, err := b.NextSequence()
+	if err != nil {
+		return gopay.AuditEntry{}, err
+	}
+
+	entry.ID = id
+
+	binEntry, err := json.Marshal(entry)
+	if err != nil {
+		return gopay.AuditEntry{}, err
+	}
+
+	return entry, b.Put(auditKey(id), binEntry)
+}
+
+// ListAuditEntries walks entries from the newest one, the cursor is the key of the last returned entry
+func (r PaymentRepository) ListAuditEntries(filter gopay.AuditFilter) (gopay.AuditPage, error) {
+	const op = "bolt.PaymentRepository.ListAuditEntries"
+
+	var before []byte
+
+	if filter.Cursor != "" {
+		var err error
+
+		before, err = base64.RawURLEncoding.DecodeString(filter.Cursor)
+		if err != nil || len(before) != 8 {
+			return gopay.AuditPage{}, fmt.Errorf("%s: %w", op, gopay.ErrInvalidCursor)
+		}
+	}
+
+	page := gopay.AuditPage{Entries: []gopay.AuditEntry{}}
+
+	if err := r.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(auditBucket).Cursor()
+
+		k, v := c.Last()
+		if before != nil {
+			// the cursor entry itself is on the previous page
+			if k, v = c.Seek(before); k == nil {
+				k, v = c.Last()
+			} else {
+				k, v = c.Prev()
+			}
+		}
+
+		for ; k != nil; k, v = c.Prev() {
+			var entry gopay.AuditEntry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return err
+			}
+
+			// entries are appended in time order, so older ones can not match
+			if !filter.From.IsZero() && entry.At.Before(filter.From) {
+				break
+			}
+
+			if !filter.Match(entry) {
+				continue
+			}
+
+			if len(page.Entries) == filter.Limit {
+				page.NextCursor = base64.RawURLEncoding.EncodeToString(auditKey(page.Entries[len(page.Entries)-1].ID))
+
+				break
+			}
+
+			page.Entries = append(page.Entries, entry)
+		}
+
+		return nil
+	}); err != nil {
+		return gopay.AuditPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return page, nil
+}
+
+func auditKey(id uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, id)
+}
@@ -0,0 +1,25 @@
+package bolt
+
+import (
+	"fmt"
+	"io"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Backup writes consistent snapshot of the database in a read transaction, so writers are not blocked
+func (r PaymentRepository) Backup(w io.Writer) (int64, error) {
+	var n int64
+
+	if err := r.db.View(func(tx *bolt.Tx) error {
+		var err error
+
+		n, err = tx.WriteTo(w)
+
+		return err
+	}); err != nil {
+		return 0, fmt.Errorf("bolt.PaymentRepository.Backup: %w", err)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,59 @@
+package bolt
+
+import (
+	"encoding/json"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+func (r PaymentRepository) GetFileVersions(id gopay.ID) ([]gopay.FileVersion, error) {
+	var versions []gopay.FileVersion
+
+	if err := r.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(fileBucket)
+
+		binVersions := b.Get([]byte(id))
+		if len(binVersions) == 0 {
+			return nil
+		}
+
+		return json.Unmarshal(binVersions, &versions)
+	}); err != nil {
+		return nil, fmt.Errorf("bolt.PaymentRepository.GetFileVersions: %w", err)
+	}
+
+	return versions, nil
+}
+
+func (r PaymentRepository) AddFileVersions(id gopay.ID, versions ...gopay.FileVersion) error {
+	if err := r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(fileBucket)
+
+		var stored []gopay.FileVersion
+
+		if binVersions := b.Get([]byte(id)); len(binVersions) != 0 {
+			if err := json.Unmarshal(binVersions, &stored); err != nil {
+				return err
+			}
+		}
+
+		// concurrent uploads must not get the same version number
+		if len(stored) != 0 && len(versions) != 0 && versions[0].Version <= stored[len(stored)-1].Version {
+			return errFileVersionConflict
+		}
+
+		binVersions, err := json.Marshal(append(stored, versions...))
+		if err != nil {
+			return err
+		}
+
+		return b.Put([]byte(id), binVersions)
+	}); err != nil {
+		return fmt.Errorf("bolt.PaymentRepository.AddFileVersions: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package bolt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+// ReserveIdempotencyKey saves the record unless the key is already saved and not expired, the released key
+// is reserved again by the request with the same hash keeping the saved payment ID
+func (r PaymentRepository) ReserveIdempotencyKey(
+	key string, record gopay.IdempotencyRecord,
+) (gopay.IdempotencyRecord, bool, error) {
+	var (
+		saved    gopay.IdempotencyRecord
+		reserved bool
+	)
+
+	if err := r.db.Update(func(tx *bolt.Tx) error {
+		if err := purgeIdempotencyKeys(tx, time.Now()); err != nil {
+			return err
+		}
+
+		b := tx.Bucket(idempotencyBucket)
+
+		if v := b.Get([]byte(key)); v != nil {
+			if err := json.Unmarshal(v, &saved); err != nil {
+				return err
+			}
+
+			if saved.State != gopay.IdempotencyReleased || saved.RequestHash != record.RequestHash {
+				return nil
+			}
+
+			saved.State = gopay.IdempotencyInProgress
+		} else {
+			saved = record
+		}
+
+		reserved = true
+
+		return putIdempotencyKey(tx, key, saved)
+	}); err != nil {
+		return gopay.IdempotencyRecord{}, false, fmt.Errorf("bolt.PaymentRepository.ReserveIdempotencyKey: %w", err)
+	}
+
+	return saved, reserved, nil
+}
+
+// CompleteIdempotencyKey saves ID of the payment created by the request with the key
+func (r PaymentRepository) CompleteIdempotencyKey(key string, id gopay.ID) error {
+	if err := r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(idempotencyBucket)
+
+		v := b.Get([]byte(key))
+		if v == nil {
+			return errIdempotencyKeyNotFound
+		}
+
+		var rec gopay.IdempotencyRecord
+		if err := json.Unmarshal(v, &rec); err != nil {
+			return err
+		}
+
+		rec.PaymentID = id
+		rec.State = gopay.IdempotencyCompleted
+
+		binRecord, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+
+		return b.Put([]byte(key), binRecord)
+	}); err != nil {
+		return fmt.Errorf("bolt.PaymentRepository.CompleteIdempotencyKey: %w", err)
+	}
+
+	return nil
+}
+
+// ReleaseIdempotencyKey marks the request with the key failed, so the request can be retried
+func (r PaymentRepository) ReleaseIdempotencyKey(key string) error {
+	if err := r.db.Update(func(tx *bolt.Tx) error {
+		v := tx.Bucket(idempotencyBucket).Get([]byte(key))
+		if v == nil {
+			return nil
+		}
+
+		var rec gopay.IdempotencyRecord
+		if err := json.Unmarshal(v, &rec); err != nil {
+			return err
+		}
+
+		rec.State = gopay.IdempotencyReleased
+
+		return putIdempotencyKey(tx, key, rec)
+	}); err != nil {
+		return fmt.Errorf("bolt.PaymentRepository.ReleaseIdempotencyKey: %w", err)
+	}
+
+	return nil
+}
+
+// putIdempotencyKey saves the record and adds the key to the expiry index
+func putIdempotencyKey(tx *bolt.Tx, key string, record gopay.IdempotencyRecord) error {
+	binRecord, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Bucket(idempotencyBucket).Put([]byte(key), binRecord); err != nil {
+		return err
+	}
+
+	return tx.Bucket(idempotencyExpiryBucket).Put(expiryKey(record.ExpiresAt, key), []byte(key))
+}
+
+// purgeIdempotencyKeys deletes keys expired before now, only expired entries of the index are visited
+func purgeIdempotencyKeys(tx *bolt.Tx, now time.Time) error {
+	b := tx.Bucket(idempotencyBucket)
+	index := tx.Bucket(idempotencyExpiryBucket)
+	bound := expiryKey(now, "")
+
+	c := index.Cursor()
+
+	// the cursor is moved to the first key again because deletion invalidates its position
+	for k, v := c.First(); k != nil && bytes.Compare(k, bound) < 0; k, v = c.First() {
+		if err := b.Delete(v); err != nil {
+			return err
+		}
+
+		if err := index.Delete(k); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// expiryKey orders idempotency keys by expiration time, the key suffix keeps entries unique
+func expiryKey(expiresAt time.Time, key string) []byte {
+	var nanos uint64
+	if expiresAt.UnixNano() > 0 {
+		nanos = uint64(expiresAt.UnixNano())
+	}
+
+	return append(binary.BigEndian.AppendUint64(nil, nanos), key...)
+}
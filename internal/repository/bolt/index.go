@@ -0,0 +1,84 @@
+package bolt
+
+import (
+	"encoding/binary"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+// indexPayment adds payment to all secondary indexes, must be called in the same transaction as the payment write
+func indexPayment(tx *bolt.Tx, id gopay.ID, pay gopay.Payment) error {
+	if err := indexNested(tx.Bucket(emailIndexBucket), strings.ToLower(pay.User.Email), id); err != nil {
+		return err
+	}
+
+	if err := indexNested(tx.Bucket(statusIndexBucket), string(pay.Status), id); err != nil {
+		return err
+	}
+
+	if err := indexNested(tx.Bucket(productIndexBucket), string(pay.ProductID), id); err != nil {
+		return err
+	}
+
+	return tx.Bucket(createdIndexBucket).Put(createdKey(pay.CreatedAt, id), []byte(id))
+}
+
+// unindexPayment removes payment from all secondary indexes
+func unindexPayment(tx *bolt.Tx, id gopay.ID, pay gopay.Payment) error {
+	if err := unindexNested(tx.Bucket(emailIndexBucket), strings.ToLower(pay.User.Email), id); err != nil {
+		return err
+	}
+
+	if err := unindexNested(tx.Bucket(statusIndexBucket), string(pay.Status), id); err != nil {
+		return err
+	}
+
+	if err := unindexNested(tx.Bucket(productIndexBucket), string(pay.ProductID), id); err != nil {
+		return err
+	}
+
+	return tx.Bucket(createdIndexBucket).Delete(createdKey(pay.CreatedAt, id))
+}
+
+// indexNested puts payment ID into nested bucket of the index named by the indexed value
+func indexNested(index *bolt.Bucket, value string, id gopay.ID) error {
+	if value == "" {
+		return nil
+	}
+
+	b, err := index.CreateBucketIfNotExists([]byte(value))
+	if err != nil {
+		return err
+	}
+
+	return b.Put([]byte(id), nil)
+}
+
+func unindexNested(index *bolt.Bucket, value string, id gopay.ID) error {
+	if value == "" {
+		return nil
+	}
+
+	b := index.Bucket([]byte(value))
+	if b == nil {
+		return nil
+	}
+
+	return b.Delete([]byte(id))
+}
+
+// createdKey orders payments by creation time, payments without timestamp go first,
+// ID suffix keeps keys of payments created at the same moment unique
+func createdKey(createdAt time.Time, id gopay.ID) []byte {
+	key := make([]byte, 8, 8+len(id))
+
+	if !createdAt.IsZero() && createdAt.UnixNano() > 0 {
+		binary.BigEndian.PutUint64(key, uint64(createdAt.UnixNano()))
+	}
+
+	return append(key, id...)
+}
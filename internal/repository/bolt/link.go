@@ -8,18 +8,6 @@ import (
 	"github.com/Anton-Kraev/gopay"
 )
 
-func (r PaymentRepository) SetLink(id gopay.ID, link gopay.Link) error {
-	if err := r.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(linkBucket)
-
-		return b.Put([]byte(id), []byte(link))
-	}); err != nil {
-		return fmt.Errorf("bolt.PaymentRepository.SetLink: %w", err)
-	}
-
-	return nil
-}
-
 func (r PaymentRepository) GetLink(id gopay.ID) (gopay.Link, error) {
 	var link gopay.Link
 
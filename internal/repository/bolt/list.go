@@ -0,0 +1,214 @@
+package bolt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+// listEntry is a payment with the key it is ordered by, the key of the last entry on a page is the cursor
+type listEntry struct {
+	key   []byte
+	entry gopay.PaymentEntry
+}
+
+// ListPayments returns a page of payments, all of them without positive limit, pages ordered by creation time without email and status filters
+// are read directly from the creation time index, other pages are built from payments selected by indexes
+func (r PaymentRepository) ListPayments(filter gopay.PaymentFilter) (gopay.PaymentPage, error) {
+	const op = "bolt.PaymentRepository.ListPayments"
+
+	var after []byte
+
+	if filter.Cursor != "" {
+		var err error
+
+		after, err = base64.RawURLEncoding.DecodeString(filter.Cursor)
+		if err != nil || len(after) <= 8 {
+			return gopay.PaymentPage{}, fmt.Errorf("%s: %w", op, gopay.ErrInvalidCursor)
+		}
+	}
+
+	var (
+		entries []listEntry
+		desc    = strings.HasPrefix(string(filter.Sort), "-")
+		byTime  = filter.Sort == gopay.SortCreatedAsc || filter.Sort == gopay.SortCreatedDesc
+	)
+
+	if err := r.db.View(func(tx *bolt.Tx) error {
+		var err error
+
+		if byTime && filter.Email == "" && filter.Status == "" {
+			entries, err = scanCreated(tx, filter, after, desc)
+		} else {
+			entries, err = scanIndexed(tx, filter, after, desc, byTime)
+		}
+
+		return err
+	}); err != nil {
+		return gopay.PaymentPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var cursor string
+
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[:filter.Limit]
+		cursor = base64.RawURLEncoding.EncodeToString(entries[len(entries)-1].key)
+	}
+
+	page := gopay.PaymentPage{Payments: make([]gopay.PaymentEntry, 0, len(entries)), NextCursor: cursor}
+
+	for _, e := range entries {
+		page.Payments = append(page.Payments, e.entry)
+	}
+
+	return page, nil
+}
+
+// scanCreated walks the creation time index from the cursor and stops after one entry over the positive limit
+func scanCreated(tx *bolt.Tx, filter gopay.PaymentFilter, after []byte, desc bool) ([]listEntry, error) {
+	var (
+		entries  []listEntry
+		from, to []byte
+		b        = tx.Bucket(paymentBucket)
+		c        = tx.Bucket(createdIndexBucket).Cursor()
+	)
+
+	if !filter.CreatedFrom.IsZero() {
+		from = createdKey(filter.CreatedFrom, "")
+	}
+
+	if !filter.CreatedTo.IsZero() {
+		to = createdKey(filter.CreatedTo, "")
+	}
+
+	var k, v []byte
+
+	if desc {
+		if after != nil && (to == nil || bytes.Compare(after, to) < 0) {
+			to = after
+		}
+
+		if k, v = c.Last(); to != nil {
+			if k, v = c.Seek(to); k == nil {
+				k, v = c.Last()
+			} else {
+				k, v = c.Prev()
+			}
+		}
+	} else {
+		if after != nil && bytes.Compare(after, from) >= 0 {
+			from = after
+		}
+
+		if k, v = c.First(); from != nil {
+			if k, v = c.Seek(from); bytes.Equal(k, after) {
+				k, v = c.Next()
+			}
+		}
+	}
+
+	for ; k != nil && (filter.Limit <= 0 || len(entries) <= filter.Limit); k, v = next(c, desc) {
+		if desc && from != nil && bytes.Compare(k, from) < 0 || !desc && to != nil && bytes.Compare(k, to) >= 0 {
+			break
+		}
+
+		var pay gopay.Payment
+		if err := json.Unmarshal(b.Get(v), &pay); err != nil {
+			return nil, err
+		}
+
+		if filter.Match(pay) {
+			entries = append(entries, listEntry{
+				key:   slices.Clone(k),
+				entry: gopay.PaymentEntry{ID: gopay.ID(v), Payment: pay},
+			})
+		}
+	}
+
+	return entries, nil
+}
+
+func next(c *bolt.Cursor, desc bool) ([]byte, []byte) {
+	if desc {
+		return c.Prev()
+	}
+
+	return c.Next()
+}
+
+// scanIndexed selects payments by email or status index and sorts them in memory
+func scanIndexed(tx *bolt.Tx, filter gopay.PaymentFilter, after []byte, desc, byTime bool) ([]listEntry, error) {
+	var (
+		entries []listEntry
+		b       = tx.Bucket(paymentBucket)
+		ids     = b
+	)
+
+	switch {
+	case filter.Email != "":
+		ids = tx.Bucket(emailIndexBucket).Bucket([]byte(strings.ToLower(filter.Email)))
+	case filter.Status != "":
+		ids = tx.Bucket(statusIndexBucket).Bucket([]byte(filter.Status))
+	}
+
+	if ids == nil {
+		return nil, nil
+	}
+
+	if err := ids.ForEach(func(k, _ []byte) error {
+		var pay gopay.Payment
+		if err := json.Unmarshal(b.Get(k), &pay); err != nil {
+			return err
+		}
+
+		if !filter.Match(pay) {
+			return nil
+		}
+
+		key := amountKey(pay.Amount, gopay.ID(k))
+		if byTime {
+			key = createdKey(pay.CreatedAt, gopay.ID(k))
+		}
+
+		if after != nil && (desc && bytes.Compare(key, after) >= 0 || !desc && bytes.Compare(key, after) <= 0) {
+			return nil
+		}
+
+		entries = append(entries, listEntry{
+			key:   key,
+			entry: gopay.PaymentEntry{ID: gopay.ID(k), Payment: pay},
+		})
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(entries, func(a, b listEntry) int {
+		if desc {
+			return bytes.Compare(b.key, a.key)
+		}
+
+		return bytes.Compare(a.key, b.key)
+	})
+
+	if filter.Limit > 0 {
+		entries = entries[:min(len(entries), filter.Limit+1)]
+	}
+
+	return entries, nil
+}
+
+func amountKey(amount uint, id gopay.ID) []byte {
+	key := binary.BigEndian.AppendUint64(make([]byte, 0, 8+len(id)), uint64(amount))
+
+	return append(key, id...)
+}
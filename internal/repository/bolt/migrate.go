@@ -0,0 +1,282 @@
+package bolt
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+// errDryRun rolls back the transaction of dry run after all migrations are applied
+var errDryRun = errors.New("dry run")
+
+// Migration describes a schema change, migrations are applied in order of versions
+type Migration struct {
+	Version uint64
+	Name    string
+}
+
+type migration struct {
+	Migration
+	up func(tx *bolt.Tx) error
+}
+
+// migrations must only be appended, the version of every migration is one more than the previous one,
+// databases created before versioning was introduced have version 0 and are upgraded by all of them
+var migrations = []migration{
+	{Migration{1, "create buckets"}, createBuckets},
+	{Migration{2, "build payment indexes"}, createIndexes},
+	{Migration{3, "backfill payment update time"}, backfillUpdatedAt},
+	{Migration{4, "store payment statuses separately"}, createStatuses},
+	{Migration{5, "create idempotency keys bucket"}, createIdempotencyKeys},
+	{Migration{6, "create API keys bucket"}, createAPIKeys},
+	{Migration{7, "create audit log bucket"}, createAuditLog},
+	{Migration{8, "build idempotency keys expiry index"}, createIdempotencyExpiryIndex},
+}
+
+// MigrationStatus returns current schema version of the database and migrations which are not applied yet
+func MigrationStatus(db *bolt.DB) (uint64, []Migration, error) {
+	var (
+		version uint64
+		pending []Migration
+	)
+
+	if err := db.View(func(tx *bolt.Tx) error {
+		var err error
+
+		version, pending, err = pendingMigrations(tx)
+
+		return err
+	}); err != nil {
+		return 0, nil, fmt.Errorf("bolt.MigrationStatus: %w", err)
+	}
+
+	return version, pending, nil
+}
+
+// Migrate applies pending migrations in a single transaction, so the database is either upgraded
+// to the latest version or left unchanged, with dryRun all migrations are applied and rolled back,
+// returns applied migrations
+func Migrate(db *bolt.DB, dryRun bool) ([]Migration, error) {
+	var pending []Migration
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		var err error
+
+		if _, pending, err = pendingMigrations(tx); err != nil || len(pending) == 0 {
+			return err
+		}
+
+		for _, m := range migrations[len(migrations)-len(pending):] {
+			if err = m.up(tx); err != nil {
+				return fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+			}
+
+			if err = setSchemaVersion(tx, m.Version); err != nil {
+				return err
+			}
+		}
+
+		if dryRun {
+			return errDryRun
+		}
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, fmt.Errorf("bolt.Migrate: %w", err)
+	}
+
+	return pending, nil
+}
+
+func pendingMigrations(tx *bolt.Tx) (uint64, []Migration, error) {
+	version := schemaVersion(tx)
+
+	latest := migrations[len(migrations)-1].Version
+	if version > latest {
+		return 0, nil, fmt.Errorf("database schema version %d is newer than supported %d", version, latest)
+	}
+
+	pending := make([]Migration, 0, latest-version)
+	for _, m := range migrations[version:] {
+		pending = append(pending, m.Migration)
+	}
+
+	return version, pending, nil
+}
+
+func schemaVersion(tx *bolt.Tx) uint64 {
+	b := tx.Bucket(metaBucket)
+	if b == nil {
+		return 0
+	}
+
+	if v := b.Get(schemaVersionKey); len(v) == 8 {
+		return binary.BigEndian.Uint64(v)
+	}
+
+	return 0
+}
+
+func setSchemaVersion(tx *bolt.Tx, version uint64) error {
+	b, err := tx.CreateBucketIfNotExists(metaBucket)
+	if err != nil {
+		return err
+	}
+
+	return b.Put(schemaVersionKey, binary.BigEndian.AppendUint64(nil, version))
+}
+
+func createBuckets(tx *bolt.Tx) error {
+	for _, bucket := range [][]byte{
+		paymentBucket,
+		linkBucket,
+		fileBucket,
+		reminderBucket,
+		unsubscribeBucket,
+		usedTokenBucket,
+	} {
+		if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createIndexes builds indexes for payments stored before they were introduced,
+// indexes which already exist are rebuilt from scratch
+func createIndexes(tx *bolt.Tx) error {
+	for _, bucket := range indexBuckets {
+		if tx.Bucket(bucket) != nil {
+			if err := tx.DeleteBucket(bucket); err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.CreateBucket(bucket); err != nil {
+			return err
+		}
+	}
+
+	return tx.Bucket(paymentBucket).ForEach(func(k, v []byte) error {
+		var pay gopay.Payment
+		if err := json.Unmarshal(v, &pay); err != nil {
+			return err
+		}
+
+		return indexPayment(tx, gopay.ID(k), pay)
+	})
+}
+
+// backfillUpdatedAt sets update time of payments stored before it was tracked to their creation time
+func backfillUpdatedAt(tx *bolt.Tx) error {
+	b := tx.Bucket(paymentBucket)
+
+	updated := make(map[string][]byte)
+
+	if err := b.ForEach(func(k, v []byte) error {
+		var pay gopay.Payment
+		if err := json.Unmarshal(v, &pay); err != nil {
+			return err
+		}
+
+		if !pay.UpdatedAt.IsZero() {
+			return nil
+		}
+
+		pay.UpdatedAt = pay.CreatedAt
+
+		binPay, err := json.Marshal(pay)
+		if err != nil {
+			return err
+		}
+
+		updated[string(k)] = binPay
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// bolt does not allow to modify bucket while iterating over it
+	for k, v := range updated {
+		if err := b.Put([]byte(k), v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createStatuses copies statuses of stored payments to the bucket read by GetStatus
+func createStatuses(tx *bolt.Tx) error {
+	statuses, err := tx.CreateBucketIfNotExists(statusBucket)
+	if err != nil {
+		return err
+	}
+
+	return tx.Bucket(paymentBucket).ForEach(func(k, v []byte) error {
+		var pay gopay.Payment
+		if err := json.Unmarshal(v, &pay); err != nil {
+			return err
+		}
+
+		return statuses.Put(k, []byte(pay.Status))
+	})
+}
+
+func createIdempotencyKeys(tx *bolt.Tx) error {
+	_, err := tx.CreateBucketIfNotExists(idempotencyBucket)
+
+	return err
+}
+
+func createAPIKeys(tx *bolt.Tx) error {
+	_, err := tx.CreateBucketIfNotExists(apiKeyBucket)
+
+	return err
+}
+
+func createAuditLog(tx *bolt.Tx) error {
+	_, err := tx.CreateBucketIfNotExists(auditBucket)
+
+	return err
+}
+
+// createIdempotencyExpiryIndex indexes saved keys by expiration time, keys which can not be decoded are deleted
+func createIdempotencyExpiryIndex(tx *bolt.Tx) error {
+	if _, err := tx.CreateBucketIfNotExists(idempotencyExpiryBucket); err != nil {
+		return err
+	}
+
+	b := tx.Bucket(idempotencyBucket)
+
+	var broken [][]byte
+
+	if err := b.ForEach(func(k, v []byte) error {
+		var rec gopay.IdempotencyRecord
+		if err := json.Unmarshal(v, &rec); err != nil {
+			broken = append(broken, k)
+
+			return nil
+		}
+
+		return tx.Bucket(idempotencyExpiryBucket).Put(expiryKey(rec.ExpiresAt, string(k)), k)
+	}); err != nil {
+		return err
+	}
+
+	for _, k := range broken {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package bolt_test
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"maps"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/Anton-Kraev/gopay"
+	boltrepo "github.com/Anton-Kraev/gopay/internal/repository/bolt"
+)
+
+func openDB(t *testing.T) *bolt.DB {
+	t.Helper()
+
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "data.db"), 0600, &bolt.Options{Timeout: time.Second})
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = db.Close() })
+
+	return db
+}
+
+// legacyDB returns database in the layout used before schema versioning was introduced
+func legacyDB(t *testing.T, payments map[gopay.ID]gopay.Payment) *bolt.DB {
+	t.Helper()
+
+	db := openDB(t)
+
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("PaymentBucket"))
+		if err != nil {
+			return err
+		}
+
+		for id, pay := range payments {
+			binPay, err := json.Marshal(pay)
+			if err != nil {
+				return err
+			}
+
+			if err = b.Put([]byte(id), binPay); err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.CreateBucket([]byte("LinkBucket"))
+
+		return err
+	}))
+
+	return db
+}
+
+func TestMigrate_Legacy(t *testing.T) {
+	t.Parallel()
+
+	createdAt := time.Date(2024, time.March, 1, 10, 0, 0, 0, time.UTC)
+
+	db := legacyDB(t, map[gopay.ID]gopay.Payment{
+		"1": {
+			User:      gopay.User{Email: "Buyer@Mail.com"},
+			ProductID: "book",
+			Status:    gopay.StatusSucceeded,
+			CreatedAt: createdAt,
+		},
+		"2": {Status: gopay.StatusPending, CreatedAt: createdAt, UpdatedAt: createdAt.Add(time.Hour)},
+	})
+
+	version, pending, err := boltrepo.MigrationStatus(db)
+	require.NoError(t, err)
+	assert.Zero(t, version)
+	require.Len(t, pending, 8)
+
+	// dry run applies nothing
+	applied, err := boltrepo.Migrate(db, true)
+	require.NoError(t, err)
+	assert.Equal(t, pending, applied)
+
+	version, _, err = boltrepo.MigrationStatus(db)
+	require.NoError(t, err)
+	assert.Zero(t, version)
+
+	repo, err := boltrepo.NewPaymentRepository(db)
+	require.NoError(t, err)
+
+	version, pending, err = boltrepo.MigrationStatus(db)
+	require.NoError(t, err)
+	assert.Equal(t, applied[len(applied)-1].Version, version)
+	assert.Empty(t, pending)
+
+	byEmail, err := repo.GetPaymentsByEmail("buyer@mail.com")
+	require.NoError(t, err)
+	require.Contains(t, byEmail, gopay.ID("1"))
+	assert.Equal(t, createdAt, byEmail["1"].UpdatedAt)
+
+	byProduct, err := repo.GetProductPayments("book")
+	require.NoError(t, err)
+	assert.Equal(t, []gopay.ID{"1"}, slices.Collect(maps.Keys(byProduct)))
+
+	pay, err := repo.Get("2")
+	require.NoError(t, err)
+	assert.Equal(t, createdAt.Add(time.Hour), pay.UpdatedAt)
+
+	status, err := repo.GetStatus("1")
+	require.NoError(t, err)
+	assert.Equal(t, gopay.StatusSucceeded, status)
+
+	// repeated start does not apply migrations again
+	applied, err = boltrepo.Migrate(db, false)
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+}
+
+func TestMigrate_NewerVersion(t *testing.T) {
+	t.Parallel()
+
+	db := openDB(t)
+
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("MetaBucket"))
+		if err != nil {
+			return err
+		}
+
+		return b.Put([]byte("SchemaVersion"), binary.BigEndian.AppendUint64(nil, 1000))
+	}))
+
+	_, err := boltrepo.NewPaymentRepository(db)
+	require.Error(t, err)
+}
+
+func TestMigrate_IdempotencyExpiryIndex(t *testing.T) {
+	t.Parallel()
+
+	db := legacyDB(t, nil)
+
+	// keys saved before the expiry index was introduced
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("IdempotencyKeyBucket"))
+		if err != nil {
+			return err
+		}
+
+		for key, expiresAt := range map[string]time.Time{
+			"expired": time.Now().Add(-time.Minute),
+			"active":  time.Now().Add(time.Hour),
+		} {
+			binRecord, err := json.Marshal(gopay.IdempotencyRecord{RequestHash: "hash", ExpiresAt: expiresAt})
+			if err != nil {
+				return err
+			}
+
+			if err = b.Put([]byte(key), binRecord); err != nil {
+				return err
+			}
+		}
+
+		return b.Put([]byte("broken"), []byte("{"))
+	}))
+
+	repo, err := boltrepo.NewPaymentRepository(db)
+	require.NoError(t, err)
+
+	record := gopay.IdempotencyRecord{RequestHash: "other", ExpiresAt: time.Now().Add(time.Hour)}
+
+	// expired keys are found by the index and purged before the reservation
+	for key, reserved := range map[string]bool{"expired": true, "active": false, "broken": true} {
+		_, ok, err := repo.ReserveIdempotencyKey(key, record)
+		require.NoError(t, err)
+		assert.Equal(t, reserved, ok, key)
+	}
+
+	require.NoError(t, db.View(func(tx *bolt.Tx) error {
+		assert.Equal(t, 3, tx.Bucket([]byte("IdempotencyExpiryBucket")).Stats().KeyN)
+
+		return nil
+	}))
+}
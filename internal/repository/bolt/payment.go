@@ -1,8 +1,11 @@
 package bolt
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	bolt "go.etcd.io/bbolt"
 
@@ -13,14 +16,11 @@ func (r PaymentRepository) Get(id gopay.ID) (gopay.Payment, error) {
 	var pay gopay.Payment
 
 	if err := r.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(paymentBucket)
+		var err error
 
-		binPay := b.Get([]byte(id))
-		if len(binPay) == 0 {
-			return errPaymentNotFound
-		}
+		pay, err = paymentTx{tx: tx}.Get(id)
 
-		return json.Unmarshal(binPay, &pay)
+		return err
 	}); err != nil {
 		return gopay.Payment{}, fmt.Errorf("bolt.PaymentRepository.Get: %w", err)
 	}
@@ -28,68 +28,129 @@ func (r PaymentRepository) Get(id gopay.ID) (gopay.Payment, error) {
 	return pay, nil
 }
 
-func (r PaymentRepository) Set(id gopay.ID, pay gopay.Payment) error {
-	if err := r.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(paymentBucket)
+func (r PaymentRepository) GetStatus(id gopay.ID) (gopay.Status, error) {
+	var status gopay.Status
 
-		binPay, err := json.Marshal(pay)
-		if err != nil {
-			return err
+	if err := r.db.View(func(tx *bolt.Tx) error {
+		status = gopay.Status(tx.Bucket(statusBucket).Get([]byte(id)))
+		if status == "" {
+			return errPaymentNotFound
 		}
 
-		return b.Put([]byte(id), binPay)
+		return nil
 	}); err != nil {
-		return fmt.Errorf("bolt.PaymentRepository.Set: %w", err)
+		return "", fmt.Errorf("bolt.PaymentRepository.GetStatus: %w", err)
 	}
 
-	return nil
+	return status, nil
 }
 
-func (r PaymentRepository) GetStatus(id gopay.ID) (gopay.Status, error) {
-	pay, err := r.Get(id)
+func (r PaymentRepository) GetStatuses() (map[gopay.ID]gopay.Status, error) {
+	statuses := make(map[gopay.ID]gopay.Status)
+
+	if err := r.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(statusBucket).ForEach(func(k, v []byte) error {
+			statuses[gopay.ID(k)] = gopay.Status(v)
+
+			return nil
+		})
+	}); err != nil {
+		return nil, fmt.Errorf("bolt.PaymentRepository.GetStatuses: %w", err)
+	}
+
+	return statuses, nil
+}
+
+func (r PaymentRepository) GetProductPayments(productID gopay.ID) (map[gopay.ID]gopay.Payment, error) {
+	payments, err := r.byIndex(productIndexBucket, string(productID))
 	if err != nil {
-		return "", fmt.Errorf("bolt.PaymentRepository.GetStatus: %w", err)
+		return nil, fmt.Errorf("bolt.PaymentRepository.GetProductPayments: %w", err)
 	}
 
-	return pay.Status, nil
+	return payments, nil
 }
 
-func (r PaymentRepository) GetStatuses() (map[gopay.ID]gopay.Status, error) {
-	statuses := make(map[gopay.ID]gopay.Status)
+func (r PaymentRepository) GetPaymentsByStatus(status gopay.Status) (map[gopay.ID]gopay.Payment, error) {
+	payments, err := r.byIndex(statusIndexBucket, string(status))
+	if err != nil {
+		return nil, fmt.Errorf("bolt.PaymentRepository.GetPaymentsByStatus: %w", err)
+	}
+
+	return payments, nil
+}
+
+func (r PaymentRepository) GetPaymentsByEmail(email string) (map[gopay.ID]gopay.Payment, error) {
+	payments, err := r.byIndex(emailIndexBucket, strings.ToLower(email))
+	if err != nil {
+		return nil, fmt.Errorf("bolt.PaymentRepository.GetPaymentsByEmail: %w", err)
+	}
+
+	return payments, nil
+}
+
+// GetPaymentsCreated returns payments created within [from, to) ordered by creation time,
+// zero bound is not applied, payments without timestamp are returned only if from is zero
+func (r PaymentRepository) GetPaymentsCreated(from, to time.Time) ([]gopay.PaymentEntry, error) {
+	var payments []gopay.PaymentEntry
 
 	if err := r.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket(paymentBucket)
+		c := tx.Bucket(createdIndexBucket).Cursor()
 
-		return b.ForEach(func(k, v []byte) error {
+		k, v := c.First()
+		if !from.IsZero() {
+			k, v = c.Seek(createdKey(from, ""))
+		}
+
+		var end []byte
+		if !to.IsZero() {
+			end = createdKey(to, "")
+		}
+
+		for ; k != nil && (end == nil || bytes.Compare(k, end) < 0); k, v = c.Next() {
 			var pay gopay.Payment
-			if err := json.Unmarshal(v, &pay); err != nil {
+			if err := json.Unmarshal(b.Get(v), &pay); err != nil {
 				return err
 			}
 
-			statuses[gopay.ID(k)] = pay.Status
+			payments = append(payments, gopay.PaymentEntry{ID: gopay.ID(v), Payment: pay})
+		}
 
-			return nil
-		})
+		return nil
 	}); err != nil {
-		return nil, fmt.Errorf("bolt.PaymentRepository.GetStatuses: %w", err)
+		return nil, fmt.Errorf("bolt.PaymentRepository.GetPaymentsCreated: %w", err)
 	}
 
-	return statuses, nil
+	return payments, nil
 }
 
-func (r PaymentRepository) UpdateStatus(id gopay.ID, status gopay.Status) error {
-	const op = "bolt.PaymentRepository.UpdateStatus"
+// byIndex returns payments from nested bucket of the index named by the indexed value
+func (r PaymentRepository) byIndex(index []byte, value string) (map[gopay.ID]gopay.Payment, error) {
+	payments := make(map[gopay.ID]gopay.Payment)
 
-	pay, err := r.Get(id)
-	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+	if value == "" {
+		return payments, nil
 	}
 
-	pay.Status = status
+	err := r.db.View(func(tx *bolt.Tx) error {
+		ids := tx.Bucket(index).Bucket([]byte(value))
+		if ids == nil {
+			return nil
+		}
 
-	if err = r.Set(id, pay); err != nil {
-		return fmt.Errorf("%s: %w", op, err)
-	}
+		b := tx.Bucket(paymentBucket)
+
+		return ids.ForEach(func(k, _ []byte) error {
+			var pay gopay.Payment
+			if err := json.Unmarshal(b.Get(k), &pay); err != nil {
+				return err
+			}
+
+			payments[gopay.ID(k)] = pay
+
+			return nil
+		})
+	})
 
-	return nil
+	return payments, err
 }
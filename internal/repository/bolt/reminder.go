@@ -0,0 +1,90 @@
+package bolt
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+func (r PaymentRepository) GetReminder(id gopay.ID) (gopay.Reminder, error) {
+	var reminder gopay.Reminder
+
+	if err := r.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(reminderBucket)
+
+		binReminder := b.Get([]byte(id))
+		if len(binReminder) == 0 {
+			return nil
+		}
+
+		return json.Unmarshal(binReminder, &reminder)
+	}); err != nil {
+		return gopay.Reminder{}, fmt.Errorf("bolt.PaymentRepository.GetReminder: %w", err)
+	}
+
+	return reminder, nil
+}
+
+func (r PaymentRepository) ClaimReminder(id gopay.ID, sent uint, reminder gopay.Reminder) (bool, error) {
+	var claimed bool
+
+	if err := r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(reminderBucket)
+
+		var stored gopay.Reminder
+
+		if binStored := b.Get([]byte(id)); len(binStored) != 0 {
+			if err := json.Unmarshal(binStored, &stored); err != nil {
+				return err
+			}
+		}
+
+		if stored.Sent != sent {
+			return nil
+		}
+
+		binReminder, err := json.Marshal(reminder)
+		if err != nil {
+			return err
+		}
+
+		claimed = true
+
+		return b.Put([]byte(id), binReminder)
+	}); err != nil {
+		return false, fmt.Errorf("bolt.PaymentRepository.ClaimReminder: %w", err)
+	}
+
+	return claimed, nil
+}
+
+func (r PaymentRepository) IsUnsubscribed(email string) (bool, error) {
+	var unsubscribed bool
+
+	if err := r.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(unsubscribeBucket)
+		unsubscribed = b.Get([]byte(email)) != nil
+
+		return nil
+	}); err != nil {
+		return false, fmt.Errorf("bolt.PaymentRepository.IsUnsubscribed: %w", err)
+	}
+
+	return unsubscribed, nil
+}
+
+func (r PaymentRepository) Unsubscribe(email string) error {
+	if err := r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(unsubscribeBucket)
+
+		return b.Put([]byte(email), []byte(time.Now().UTC().Format(time.RFC3339)))
+	}); err != nil {
+		return fmt.Errorf("bolt.PaymentRepository.Unsubscribe: %w", err)
+	}
+
+	return nil
+}
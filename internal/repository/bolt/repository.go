@@ -1,45 +1,64 @@
 package bolt
 
 import (
-	"errors"
 	"fmt"
 
 	bolt "go.etcd.io/bbolt"
+
+	"github.com/Anton-Kraev/gopay"
 )
 
 var (
 	paymentBucket = []byte("PaymentBucket")
 	linkBucket    = []byte("LinkBucket")
+	fileBucket    = []byte("FileVersionBucket")
+	// statusBucket duplicates payment statuses, so they are read without decoding the whole payment
+	statusBucket = []byte("StatusBucket")
+
+	reminderBucket    = []byte("ReminderBucket")
+	unsubscribeBucket = []byte("UnsubscribeBucket")
+	usedTokenBucket   = []byte("UsedTokenBucket")
+	// idempotencyBucket maps idempotency keys of payment creation requests to gopay.IdempotencyRecord
+	idempotencyBucket = []byte("IdempotencyKeyBucket")
+	// idempotencyExpiryBucket maps keys ordered by expiration time to idempotency keys
+	idempotencyExpiryBucket = []byte("IdempotencyExpiryBucket")
+	// apiKeyBucket maps IDs of API keys to gopay.APIKey with the hash of the secret
+	apiKeyBucket = []byte("APIKeyBucket")
+	// auditBucket maps sequence numbers to gopay.AuditEntry, entries are only appended
+	auditBucket = []byte("AuditBucket")
+
+	// emailIndexBucket, statusIndexBucket and productIndexBucket contain nested bucket with payment IDs
+	// for every indexed value
+	emailIndexBucket   = []byte("EmailIndexBucket")
+	statusIndexBucket  = []byte("StatusIndexBucket")
+	productIndexBucket = []byte("ProductIndexBucket")
+	// createdIndexBucket maps keys ordered by payment creation time to payment IDs
+	createdIndexBucket = []byte("CreatedIndexBucket")
+
+	indexBuckets = [][]byte{emailIndexBucket, statusIndexBucket, productIndexBucket, createdIndexBucket}
+
+	// metaBucket keeps schemaVersionKey with the version of the last applied migration
+	metaBucket       = []byte("MetaBucket")
+	schemaVersionKey = []byte("SchemaVersion")
+
+	errPaymentNotFound = fmt.Errorf("payment %w", gopay.ErrNotFound)
+	errLinkNotFound    = fmt.Errorf("link %w", gopay.ErrNotFound)
+
+	errIdempotencyKeyNotFound = fmt.Errorf("idempotency key %w", gopay.ErrNotFound)
+	errAPIKeyNotFound         = fmt.Errorf("API key %w", gopay.ErrNotFound)
 
-	errPaymentNotFound = errors.New("payment not found")
-	errLinkNotFound    = errors.New("link not found")
+	errFileVersionConflict = fmt.Errorf("file version %w", gopay.ErrAlreadyExists)
 )
 
 type PaymentRepository struct {
 	db *bolt.DB
 }
 
+// NewPaymentRepository upgrades the database schema to the latest version before use
 func NewPaymentRepository(db *bolt.DB) (PaymentRepository, error) {
-	if err := createBuckets(db); err != nil {
+	if _, err := Migrate(db, false); err != nil {
 		return PaymentRepository{}, fmt.Errorf("bolt.NewPaymentRepository: %w", err)
 	}
 
 	return PaymentRepository{db: db}, nil
 }
-
-func createBuckets(db *bolt.DB) error {
-	if err := db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists(paymentBucket)
-		if err != nil {
-			return err
-		}
-
-		_, err = tx.CreateBucketIfNotExists(linkBucket)
-
-		return err
-	}); err != nil {
-		return fmt.Errorf("bolt.createBuckets: %w", err)
-	}
-
-	return nil
-}
@@ -0,0 +1,273 @@
+package bolt_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/Anton-Kraev/gopay"
+	boltrepo "github.com/Anton-Kraev/gopay/internal/repository/bolt"
+	"github.com/Anton-Kraev/gopay/storagetest"
+)
+
+func setupRepository(t *testing.T) boltrepo.PaymentRepository {
+	t.Helper()
+
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "data.db"), 0600, &bolt.Options{Timeout: time.Second})
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo, err := boltrepo.NewPaymentRepository(db)
+	require.NoError(t, err)
+
+	return repo
+}
+
+func TestPaymentRepository_Contract(t *testing.T) {
+	t.Parallel()
+
+	storagetest.TestStorage(t, func(t *testing.T) gopay.Storage { return setupRepository(t) })
+}
+
+func TestPaymentRepository_Backup(t *testing.T) {
+	t.Parallel()
+
+	repo := setupRepository(t)
+
+	require.NoError(t, repo.Update(func(tx gopay.PaymentTx) error {
+		return tx.Set("1", gopay.Payment{Status: gopay.StatusSucceeded})
+	}))
+
+	path := filepath.Join(t.TempDir(), "backup.db")
+
+	backup, err := os.Create(path)
+	require.NoError(t, err)
+
+	_, err = repo.Backup(backup)
+	require.NoError(t, err)
+	require.NoError(t, backup.Close())
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second, ReadOnly: true})
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = db.Close() })
+
+	version, pending, err := boltrepo.MigrationStatus(db)
+	require.NoError(t, err)
+	assert.NotZero(t, version)
+	assert.Empty(t, pending)
+
+	require.NoError(t, db.View(func(tx *bolt.Tx) error {
+		assert.NotNil(t, tx.Bucket([]byte("PaymentBucket")).Get([]byte("1")))
+
+		return nil
+	}))
+}
+
+func TestPaymentRepository_IdempotencyKeys(t *testing.T) {
+	t.Parallel()
+
+	storagetest.TestIdempotencyStorage(t, func(t *testing.T) gopay.IdempotencyStorage { return setupRepository(t) })
+}
+
+func TestPaymentRepository_APIKeys(t *testing.T) {
+	t.Parallel()
+
+	storagetest.TestAPIKeyStorage(t, func(t *testing.T) gopay.APIKeyStorage { return setupRepository(t) })
+}
+
+func TestPaymentRepository_AuditLog(t *testing.T) {
+	t.Parallel()
+
+	storagetest.TestAuditStorage(t, func(t *testing.T) gopay.AuditStorage { return setupRepository(t) })
+}
+
+func TestPaymentRepository_AuditTx(t *testing.T) {
+	t.Parallel()
+
+	storagetest.TestAuditTx(t, func(t *testing.T) storagetest.AuditTxStorage { return setupRepository(t) })
+}
+
+// indexedIDs returns payment IDs from the nested bucket of the index named by the indexed value
+func indexedIDs(t *testing.T, db *bolt.DB, index, value string) []gopay.ID {
+	t.Helper()
+
+	var ids []gopay.ID
+
+	require.NoError(t, db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(index)).Bucket([]byte(value))
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(k, _ []byte) error {
+			ids = append(ids, gopay.ID(k))
+
+			return nil
+		})
+	}))
+
+	return ids
+}
+
+func TestPaymentRepository_Indexes(t *testing.T) {
+	t.Parallel()
+
+	pay := gopay.Payment{
+		User:      gopay.User{Email: "Buyer@Mail.com"},
+		ProductID: "book",
+		Status:    gopay.StatusPending,
+	}
+
+	tests := []struct {
+		name    string
+		update  func(pay gopay.Payment) gopay.Payment
+		index   string
+		old     string
+		current string
+	}{
+		{
+			name: "status change",
+			update: func(pay gopay.Payment) gopay.Payment {
+				pay.Status = gopay.StatusSucceeded
+
+				return pay
+			},
+			index:   "StatusIndexBucket",
+			old:     string(gopay.StatusPending),
+			current: string(gopay.StatusSucceeded),
+		},
+		{
+			name: "email change",
+			update: func(pay gopay.Payment) gopay.Payment {
+				pay.User.Email = "Other@Mail.com"
+
+				return pay
+			},
+			index:   "EmailIndexBucket",
+			old:     "buyer@mail.com",
+			current: "other@mail.com",
+		},
+		{
+			name: "product change",
+			update: func(pay gopay.Payment) gopay.Payment {
+				pay.ProductID = "course"
+
+				return pay
+			},
+			index:   "ProductIndexBucket",
+			old:     "book",
+			current: "course",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			db := openDB(t)
+
+			repo, err := boltrepo.NewPaymentRepository(db)
+			require.NoError(t, err)
+
+			for _, p := range []gopay.Payment{pay, tt.update(pay)} {
+				require.NoError(t, repo.Update(func(tx gopay.PaymentTx) error {
+					return tx.Set("1", p)
+				}))
+			}
+
+			assert.Empty(t, indexedIDs(t, db, tt.index, tt.old))
+			assert.Equal(t, []gopay.ID{"1"}, indexedIDs(t, db, tt.index, tt.current))
+		})
+	}
+}
+
+func TestPaymentRepository_CreatedIndex(t *testing.T) {
+	t.Parallel()
+
+	db := openDB(t)
+
+	repo, err := boltrepo.NewPaymentRepository(db)
+	require.NoError(t, err)
+
+	start := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	for id, createdAt := range map[gopay.ID]time.Time{
+		"late":   start.Add(time.Hour),
+		"b":      start,
+		"a":      start,
+		"legacy": {},
+		"moved":  start.Add(-time.Hour),
+	} {
+		require.NoError(t, repo.Update(func(tx gopay.PaymentTx) error {
+			return tx.Set(id, gopay.Payment{CreatedAt: createdAt})
+		}))
+	}
+
+	// the entry of the previous creation time must be removed
+	require.NoError(t, repo.Update(func(tx gopay.PaymentTx) error {
+		return tx.Set("moved", gopay.Payment{CreatedAt: start.Add(2 * time.Hour)})
+	}))
+
+	entries, err := repo.GetPaymentsCreated(time.Time{}, time.Time{})
+	require.NoError(t, err)
+
+	ids := make([]gopay.ID, 0, len(entries))
+	for _, entry := range entries {
+		ids = append(ids, entry.ID)
+	}
+
+	// payments without timestamp go first, payments created at the same moment are ordered by ID
+	assert.Equal(t, []gopay.ID{"legacy", "a", "b", "late", "moved"}, ids)
+
+	entries, err = repo.GetPaymentsCreated(start, start.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, gopay.ID("a"), entries[0].ID)
+}
+
+func TestPaymentRepository_IdempotencyExpiryIndex(t *testing.T) {
+	t.Parallel()
+
+	db := openDB(t)
+
+	repo, err := boltrepo.NewPaymentRepository(db)
+	require.NoError(t, err)
+
+	now := time.Now()
+
+	for key, expiresAt := range map[string]time.Time{
+		"expired":  now.Add(-time.Minute),
+		"released": now.Add(time.Hour),
+		"active":   now.Add(2 * time.Hour),
+	} {
+		_, reserved, err := repo.ReserveIdempotencyKey(key, gopay.IdempotencyRecord{ExpiresAt: expiresAt})
+		require.NoError(t, err)
+		require.True(t, reserved, key)
+	}
+
+	require.NoError(t, repo.ReleaseIdempotencyKey("released"))
+
+	// the next reservation purges the expired key, the released key is kept for retries
+	_, reserved, err := repo.ReserveIdempotencyKey("new", gopay.IdempotencyRecord{ExpiresAt: now.Add(time.Hour)})
+	require.NoError(t, err)
+	require.True(t, reserved)
+
+	var keys []string
+
+	require.NoError(t, db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("IdempotencyExpiryBucket")).ForEach(func(_, v []byte) error {
+			keys = append(keys, string(v))
+
+			return nil
+		})
+	}))
+
+	assert.Equal(t, []string{"new", "released", "active"}, keys)
+}
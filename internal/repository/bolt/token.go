@@ -0,0 +1,50 @@
+package bolt
+
+import (
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// MarkTokenUsed saves one-time token until it expires, returns false if it is already used
+func (r PaymentRepository) MarkTokenUsed(token string, expiresAt time.Time) (bool, error) {
+	var marked bool
+
+	if err := r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(usedTokenBucket)
+		now := time.Now()
+
+		// expired tokens are rejected by signature check, so there is no need to keep them
+		var expired [][]byte
+
+		if err := b.ForEach(func(k, v []byte) error {
+			exp, err := time.Parse(time.RFC3339, string(v))
+			if err != nil || now.After(exp) {
+				expired = append(expired, k)
+			}
+
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		if b.Get([]byte(token)) != nil {
+			return nil
+		}
+
+		marked = true
+
+		return b.Put([]byte(token), []byte(expiresAt.UTC().Format(time.RFC3339)))
+	}); err != nil {
+		return false, fmt.Errorf("bolt.PaymentRepository.MarkTokenUsed: %w", err)
+	}
+
+	return marked, nil
+}
@@ -0,0 +1,85 @@
+package bolt
+
+import (
+	"encoding/json"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+// Update runs fn in a single read-write transaction, bolt allows only one such transaction at a time,
+// so concurrent updates are serialized
+func (r PaymentRepository) Update(fn func(tx gopay.PaymentTx) error) error {
+	if err := r.db.Update(func(tx *bolt.Tx) error {
+		return fn(paymentTx{tx: tx})
+	}); err != nil {
+		return fmt.Errorf("bolt.PaymentRepository.Update: %w", err)
+	}
+
+	return nil
+}
+
+type paymentTx struct {
+	tx *bolt.Tx
+}
+
+func (t paymentTx) Get(id gopay.ID) (gopay.Payment, error) {
+	var pay gopay.Payment
+
+	binPay := t.tx.Bucket(paymentBucket).Get([]byte(id))
+	if len(binPay) == 0 {
+		return gopay.Payment{}, errPaymentNotFound
+	}
+
+	if err := json.Unmarshal(binPay, &pay); err != nil {
+		return gopay.Payment{}, fmt.Errorf("bolt.paymentTx.Get: %w", err)
+	}
+
+	return pay, nil
+}
+
+func (t paymentTx) Set(id gopay.ID, pay gopay.Payment) error {
+	const op = "bolt.paymentTx.Set"
+
+	b := t.tx.Bucket(paymentBucket)
+
+	if binOld := b.Get([]byte(id)); len(binOld) != 0 {
+		var old gopay.Payment
+		if err := json.Unmarshal(binOld, &old); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		if err := unindexPayment(t.tx, id, old); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	binPay, err := json.Marshal(pay)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = b.Put([]byte(id), binPay); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = t.tx.Bucket(statusBucket).Put([]byte(id), []byte(pay.Status)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = indexPayment(t.tx, id, pay); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (t paymentTx) SetLink(id gopay.ID, link gopay.Link) error {
+	if err := t.tx.Bucket(linkBucket).Put([]byte(id), []byte(link)); err != nil {
+		return fmt.Errorf("bolt.paymentTx.SetLink: %w", err)
+	}
+
+	return nil
+}
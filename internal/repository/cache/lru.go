@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+type entry[V any] struct {
+	id        gopay.ID
+	value     V
+	expiresAt time.Time
+}
+
+// lru keeps up to size recently used values, values older than ttl are not returned
+type lru[V any] struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	order *list.List
+	items map[gopay.ID]*list.Element
+	// generation is incremented on every invalidation, values loaded before it
+	// may be stale and are not added, see add
+	generation uint64
+}
+
+func newLRU[V any](size int, ttl time.Duration) *lru[V] {
+	return &lru[V]{
+		size:  size,
+		ttl:   ttl,
+		order: list.New(),
+		items: make(map[gopay.ID]*list.Element, size),
+	}
+}
+
+func (c *lru[V]) get(id gopay.ID) (V, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[id]; ok {
+		e := el.Value.(*entry[V])
+		if c.ttl <= 0 || time.Now().Before(e.expiresAt) {
+			c.order.MoveToFront(el)
+
+			return e.value, c.generation, true
+		}
+
+		c.remove(el)
+	}
+
+	var zero V
+
+	return zero, c.generation, false
+}
+
+// add stores value loaded from the storage if no invalidation happened since generation was obtained
+func (c *lru[V]) add(id gopay.ID, value V, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	if el, ok := c.items[id]; ok {
+		c.remove(el)
+	}
+
+	c.items[id] = c.order.PushFront(&entry[V]{id: id, value: value, expiresAt: time.Now().Add(c.ttl)})
+
+	if c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *lru[V]) invalidate(ids []gopay.ID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+
+	for _, id := range ids {
+		if el, ok := c.items[id]; ok {
+			c.remove(el)
+		}
+	}
+}
+
+func (c *lru[V]) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.items, el.Value.(*entry[V]).id)
+}
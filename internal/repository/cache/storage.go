@@ -0,0 +1,127 @@
+// Package cache provides read-through cache of payment statuses and redirect links over gopay.Storage,
+// the cache lives in process memory, so with several instances sharing one database it may return
+// values changed by other instances until they expire
+package cache
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+type Config struct {
+	// Size is the maximum number of cached statuses and, separately, links
+	Size int
+	// TTL limits how long cached values are returned, zero means until invalidated or evicted
+	TTL time.Duration
+}
+
+// Storage caches results of GetStatus and GetLink, cached values are invalidated
+// by changes made through Update, other methods are passed to the wrapped storage
+type Storage struct {
+	gopay.Storage
+
+	statuses *lru[gopay.Status]
+	links    *lru[gopay.Link]
+}
+
+var _ gopay.Storage = (*Storage)(nil)
+
+func NewStorage(storage gopay.Storage, config Config) *Storage {
+	return &Storage{
+		Storage:  storage,
+		statuses: newLRU[gopay.Status](config.Size, config.TTL),
+		links:    newLRU[gopay.Link](config.Size, config.TTL),
+	}
+}
+
+func (s *Storage) GetStatus(id gopay.ID) (gopay.Status, error) {
+	status, generation, ok := s.statuses.get(id)
+	if ok {
+		return status, nil
+	}
+
+	status, err := s.Storage.GetStatus(id)
+	if err != nil {
+		return "", fmt.Errorf("cache.Storage.GetStatus: %w", err)
+	}
+
+	s.statuses.add(id, status, generation)
+
+	return status, nil
+}
+
+func (s *Storage) GetLink(id gopay.ID) (gopay.Link, error) {
+	link, generation, ok := s.links.get(id)
+	if ok {
+		return link, nil
+	}
+
+	link, err := s.Storage.GetLink(id)
+	if err != nil {
+		return "", fmt.Errorf("cache.Storage.GetLink: %w", err)
+	}
+
+	s.links.add(id, link, generation)
+
+	return link, nil
+}
+
+// Update invalidates cached values of payments changed in the transaction once it is finished
+func (s *Storage) Update(fn func(tx gopay.PaymentTx) error) error {
+	var (
+		statuses []gopay.ID
+		links    []gopay.ID
+	)
+
+	err := s.Storage.Update(func(tx gopay.PaymentTx) error {
+		// the function may be called again if the transaction is retried
+		statuses, links = statuses[:0], links[:0]
+
+		tracking := trackingTx{PaymentTx: tx, statuses: &statuses, links: &links}
+
+		// audit entries are still appended in the transaction of the wrapped storage
+		if auditTx, ok := tx.(gopay.AuditTx); ok {
+			return fn(auditTrackingTx{trackingTx: tracking, AuditTx: auditTx})
+		}
+
+		return fn(tracking)
+	})
+
+	// the transaction may be committed even if the error is returned, e.g. when the connection is lost
+	s.statuses.invalidate(statuses)
+	s.links.invalidate(links)
+
+	if err != nil {
+		return fmt.Errorf("cache.Storage.Update: %w", err)
+	}
+
+	return nil
+}
+
+// trackingTx records payments changed in the transaction
+type trackingTx struct {
+	gopay.PaymentTx
+
+	statuses *[]gopay.ID
+	links    *[]gopay.ID
+}
+
+func (t trackingTx) Set(id gopay.ID, pay gopay.Payment) error {
+	*t.statuses = append(*t.statuses, id)
+
+	return t.PaymentTx.Set(id, pay)
+}
+
+func (t trackingTx) SetLink(id gopay.ID, link gopay.Link) error {
+	*t.links = append(*t.links, id)
+
+	return t.PaymentTx.SetLink(id, link)
+}
+
+// auditTrackingTx is trackingTx of the transaction which keeps the audit log
+type auditTrackingTx struct {
+	trackingTx
+	gopay.AuditTx
+}
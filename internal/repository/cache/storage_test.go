@@ -0,0 +1,292 @@
+package cache_test
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/Anton-Kraev/gopay"
+	boltrepo "github.com/Anton-Kraev/gopay/internal/repository/bolt"
+	"github.com/Anton-Kraev/gopay/internal/repository/cache"
+	"github.com/Anton-Kraev/gopay/memory"
+	"github.com/Anton-Kraev/gopay/storagetest"
+)
+
+// countingStorage counts reads which reached the storage
+type countingStorage struct {
+	gopay.Storage
+
+	statusReads atomic.Int64
+	linkReads   atomic.Int64
+}
+
+func (s *countingStorage) GetStatus(id gopay.ID) (gopay.Status, error) {
+	s.statusReads.Add(1)
+
+	return s.Storage.GetStatus(id)
+}
+
+func (s *countingStorage) GetLink(id gopay.ID) (gopay.Link, error) {
+	s.linkReads.Add(1)
+
+	return s.Storage.GetLink(id)
+}
+
+func setupStorage(t *testing.T, config cache.Config) (*cache.Storage, *countingStorage) {
+	t.Helper()
+
+	storage := memory.NewStorage()
+
+	require.NoError(t, storage.Update(func(tx gopay.PaymentTx) error {
+		for _, id := range []gopay.ID{"1", "2", "3"} {
+			if err := tx.Set(id, gopay.Payment{Status: gopay.StatusPending}); err != nil {
+				return err
+			}
+
+			if err := tx.SetLink(id, "https://pay.example/"+gopay.Link(id)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}))
+
+	counting := &countingStorage{Storage: storage}
+
+	return cache.NewStorage(counting, config), counting
+}
+
+func TestStorage_Contract(t *testing.T) {
+	t.Parallel()
+
+	storagetest.TestStorage(t, func(*testing.T) gopay.Storage {
+		return cache.NewStorage(memory.NewStorage(), cache.Config{Size: 100, TTL: time.Minute})
+	})
+}
+
+func TestStorage_ReadThrough(t *testing.T) {
+	t.Parallel()
+
+	storage, counting := setupStorage(t, cache.Config{Size: 10, TTL: time.Minute})
+
+	for range 3 {
+		status, err := storage.GetStatus("1")
+		require.NoError(t, err)
+		assert.Equal(t, gopay.StatusPending, status)
+
+		link, err := storage.GetLink("1")
+		require.NoError(t, err)
+		assert.Equal(t, gopay.Link("https://pay.example/1"), link)
+	}
+
+	assert.Equal(t, int64(1), counting.statusReads.Load())
+	assert.Equal(t, int64(1), counting.linkReads.Load())
+
+	// not found errors are not cached
+	for range 2 {
+		_, err := storage.GetStatus("unknown")
+		require.Error(t, err)
+	}
+
+	assert.Equal(t, int64(3), counting.statusReads.Load())
+}
+
+func TestStorage_Invalidation(t *testing.T) {
+	t.Parallel()
+
+	storage, counting := setupStorage(t, cache.Config{Size: 10})
+
+	_, err := storage.GetStatus("1")
+	require.NoError(t, err)
+	_, err = storage.GetStatus("2")
+	require.NoError(t, err)
+
+	require.NoError(t, storage.Update(func(tx gopay.PaymentTx) error {
+		pay, err := tx.Get("1")
+		if err != nil {
+			return err
+		}
+
+		pay.Status = gopay.StatusSucceeded
+
+		return tx.Set("1", pay)
+	}))
+
+	status, err := storage.GetStatus("1")
+	require.NoError(t, err)
+	assert.Equal(t, gopay.StatusSucceeded, status)
+
+	_, err = storage.GetStatus("2")
+	require.NoError(t, err)
+
+	// only the changed payment is read again
+	assert.Equal(t, int64(3), counting.statusReads.Load())
+
+	// rolled back transaction does not change cached values
+	require.Error(t, storage.Update(func(tx gopay.PaymentTx) error {
+		if err := tx.SetLink("1", "https://pay.example/changed"); err != nil {
+			return err
+		}
+
+		return errors.New("rollback")
+	}))
+
+	link, err := storage.GetLink("1")
+	require.NoError(t, err)
+	assert.Equal(t, gopay.Link("https://pay.example/1"), link)
+}
+
+func TestStorage_Expiration(t *testing.T) {
+	t.Parallel()
+
+	storage, counting := setupStorage(t, cache.Config{Size: 10, TTL: 50 * time.Millisecond})
+
+	_, err := storage.GetStatus("1")
+	require.NoError(t, err)
+	_, err = storage.GetStatus("1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), counting.statusReads.Load())
+
+	time.Sleep(100 * time.Millisecond)
+
+	_, err = storage.GetStatus("1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), counting.statusReads.Load())
+}
+
+func TestStorage_Eviction(t *testing.T) {
+	t.Parallel()
+
+	storage, counting := setupStorage(t, cache.Config{Size: 2})
+
+	for _, id := range []gopay.ID{"1", "2", "1", "3"} {
+		_, err := storage.GetLink(id)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, int64(3), counting.linkReads.Load())
+
+	// "2" is the least recently used one
+	_, err := storage.GetLink("1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), counting.linkReads.Load())
+
+	_, err = storage.GetLink("2")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), counting.linkReads.Load())
+}
+
+// benchPayments is the number of stored payments in benchmarks, seeding takes a while,
+// so run them separately: go test -run ^$ -bench . ./internal/repository/cache
+const benchPayments = 1_000_000
+
+// hot payments are requested repeatedly, like statuses polled by the checkout page
+const benchHotPayments = 10_000
+
+func BenchmarkStorage(b *testing.B) {
+	repo := benchRepository(b)
+	cached := cache.NewStorage(repo, cache.Config{Size: benchHotPayments, TTL: time.Minute})
+
+	for _, bench := range []struct {
+		name string
+		get  func(id gopay.ID) error
+	}{
+		{"GetStatus/document", func(id gopay.ID) error {
+			_, err := repo.Get(id)
+
+			return err
+		}},
+		{"GetStatus/status_bucket", func(id gopay.ID) error {
+			_, err := repo.GetStatus(id)
+
+			return err
+		}},
+		{"GetStatus/cached", func(id gopay.ID) error {
+			_, err := cached.GetStatus(id)
+
+			return err
+		}},
+		{"GetLink/storage", func(id gopay.ID) error {
+			_, err := repo.GetLink(id)
+
+			return err
+		}},
+		{"GetLink/cached", func(id gopay.ID) error {
+			_, err := cached.GetLink(id)
+
+			return err
+		}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := range b.N {
+				// hot payments are spread over the whole database
+				if err := bench.get(benchID(i % benchHotPayments * (benchPayments / benchHotPayments))); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func benchRepository(b *testing.B) boltrepo.PaymentRepository {
+	b.Helper()
+
+	db, err := bolt.Open(filepath.Join(b.TempDir(), "bench.db"), 0600, &bolt.Options{NoSync: true})
+	require.NoError(b, err)
+
+	b.Cleanup(func() { _ = db.Close() })
+
+	repo, err := boltrepo.NewPaymentRepository(db)
+	require.NoError(b, err)
+
+	createdAt := time.Now().UTC()
+
+	const batch = 10_000
+
+	for start := 0; start < benchPayments; start += batch {
+		require.NoError(b, repo.Update(func(tx gopay.PaymentTx) error {
+			for i := start; i < start+batch; i++ {
+				id := benchID(i)
+				link := "https://pay.example/" + gopay.Link(id)
+
+				if err := tx.Set(id, gopay.Payment{
+					User:        gopay.User{Email: fmt.Sprintf("user%d@mail.com", i%1000)},
+					Amount:      uint(i%100+1) * 100,
+					Status:      gopay.StatusSucceeded,
+					PaymentLink: link,
+					Description: "Course access",
+					CreatedAt:   createdAt,
+					UpdatedAt:   createdAt,
+					History: []gopay.StatusChange{
+						{Status: gopay.StatusPending, ChangedAt: createdAt, Source: gopay.StatusSourceCreation},
+						{Status: gopay.StatusSucceeded, ChangedAt: createdAt, Source: gopay.StatusSourceWebhook},
+					},
+				}); err != nil {
+					return err
+				}
+
+				if err := tx.SetLink(id, link); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}))
+	}
+
+	return repo
+}
+
+func benchID(i int) gopay.ID {
+	return gopay.ID(fmt.Sprintf("payment-%07d", i))
+}
@@ -0,0 +1,48 @@
+CREATE TABLE payments (
+    id         TEXT PRIMARY KEY,
+    email      TEXT   NOT NULL,
+    status     TEXT   NOT NULL,
+    product_id TEXT   NOT NULL,
+    amount     BIGINT NOT NULL,
+    -- created_ns is the creation time in unix nanoseconds, zero for payments without timestamp
+    created_ns BIGINT NOT NULL,
+    data       JSONB  NOT NULL
+);
+
+CREATE INDEX payments_email_idx ON payments (email);
+CREATE INDEX payments_status_idx ON payments (status);
+CREATE INDEX payments_product_id_idx ON payments (product_id);
+CREATE INDEX payments_created_idx ON payments (created_ns, id);
+CREATE INDEX payments_amount_idx ON payments (amount, id);
+
+CREATE TABLE links (
+    payment_id TEXT PRIMARY KEY,
+    link       TEXT NOT NULL
+);
+
+CREATE TABLE file_versions (
+    file_id     TEXT        NOT NULL,
+    version     BIGINT      NOT NULL,
+    comment     TEXT        NOT NULL,
+    uploaded_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (file_id, version)
+);
+
+CREATE TABLE reminders (
+    payment_id   TEXT PRIMARY KEY,
+    sent         BIGINT      NOT NULL,
+    last_sent_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE unsubscribes (
+    email           TEXT PRIMARY KEY,
+    unsubscribed_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE used_tokens (
+    token      TEXT   PRIMARY KEY,
+    -- expires_ns is the expiration time in unix nanoseconds like in SQLite, so both databases share queries
+    expires_ns BIGINT NOT NULL
+);
+
+CREATE INDEX used_tokens_expires_ns_idx ON used_tokens (expires_ns);
@@ -0,0 +1,43 @@
+// Package postgres opens the payment storage in PostgreSQL, queries are shared with SQLite in package sqlrepo
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"strconv"
+
+	"github.com/Anton-Kraev/gopay/internal/repository/sqlrepo"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// migrationLock is the key of advisory lock which keeps replicas started at the same time from migrating concurrently
+const migrationLock = 7_260_350_001
+
+// NewPaymentRepository applies pending migrations, the connection pool is configured by the caller
+func NewPaymentRepository(ctx context.Context, db *sql.DB) (sqlrepo.PaymentRepository, error) {
+	files, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return sqlrepo.PaymentRepository{}, fmt.Errorf("postgres.NewPaymentRepository: %w", err)
+	}
+
+	repo, err := sqlrepo.NewPaymentRepository(ctx, db, sqlrepo.Dialect{
+		Placeholder: func(n int) string { return "$" + strconv.Itoa(n) },
+		LockRows:    " FOR UPDATE",
+		Migrations:  files,
+		SchemaMigrations: `CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    BIGINT PRIMARY KEY,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`,
+		LockMigrations: fmt.Sprintf("SELECT pg_advisory_xact_lock(%d)", migrationLock),
+	})
+	if err != nil {
+		return sqlrepo.PaymentRepository{}, fmt.Errorf("postgres.NewPaymentRepository: %w", err)
+	}
+
+	return repo, nil
+}
@@ -0,0 +1,93 @@
+package postgres_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Anton-Kraev/gopay"
+	"github.com/Anton-Kraev/gopay/internal/repository/postgres"
+	"github.com/Anton-Kraev/gopay/internal/repository/sqlrepo"
+	"github.com/Anton-Kraev/gopay/storagetest"
+)
+
+// setupRepository creates repository in a new schema of the database from GOPAY_TEST_POSTGRES_DSN,
+// the test is skipped if the variable is not set
+func setupRepository(t *testing.T) sqlrepo.PaymentRepository {
+	t.Helper()
+
+	dsn := os.Getenv("GOPAY_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("GOPAY_TEST_POSTGRES_DSN is not set")
+	}
+
+	admin, err := sql.Open("pgx", dsn)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = admin.Close() })
+
+	schema := fmt.Sprintf("gopay_test_%d", time.Now().UnixNano())
+
+	_, err = admin.Exec("CREATE SCHEMA " + schema)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _, _ = admin.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	u, err := url.Parse(dsn)
+	require.NoError(t, err)
+
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+
+	db, err := sql.Open("pgx", u.String())
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo, err := postgres.NewPaymentRepository(context.Background(), db)
+	require.NoError(t, err)
+
+	// repeated start must not apply migrations again
+	_, err = postgres.NewPaymentRepository(context.Background(), db)
+	require.NoError(t, err)
+
+	return repo
+}
+
+func TestPaymentRepository_Contract(t *testing.T) {
+	t.Parallel()
+
+	storagetest.TestStorage(t, func(t *testing.T) gopay.Storage { return setupRepository(t) })
+}
+
+func TestPaymentRepository_IdempotencyKeys(t *testing.T) {
+	t.Parallel()
+
+	storagetest.TestIdempotencyStorage(t, func(t *testing.T) gopay.IdempotencyStorage { return setupRepository(t) })
+}
+
+func TestPaymentRepository_APIKeys(t *testing.T) {
+	t.Parallel()
+
+	storagetest.TestAPIKeyStorage(t, func(t *testing.T) gopay.APIKeyStorage { return setupRepository(t) })
+}
+
+func TestPaymentRepository_AuditLog(t *testing.T) {
+	t.Parallel()
+
+	storagetest.TestAuditStorage(t, func(t *testing.T) gopay.AuditStorage { return setupRepository(t) })
+}
+
+func TestPaymentRepository_AuditTx(t *testing.T) {
+	t.Parallel()
+
+	storagetest.TestAuditTx(t, func(t *testing.T) storagetest.AuditTxStorage { return setupRepository(t) })
+}
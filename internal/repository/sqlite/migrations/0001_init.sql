@@ -0,0 +1,64 @@
+CREATE TABLE payments (
+    id         TEXT    PRIMARY KEY,
+    email      TEXT    NOT NULL,
+    status     TEXT    NOT NULL,
+    product_id TEXT    NOT NULL,
+    amount     INTEGER NOT NULL,
+    -- created_ns is the creation time in unix nanoseconds, zero for payments without timestamp
+    created_ns INTEGER NOT NULL,
+    data       TEXT    NOT NULL
+);
+
+CREATE INDEX payments_email_idx ON payments (email);
+CREATE INDEX payments_status_idx ON payments (status);
+CREATE INDEX payments_product_id_idx ON payments (product_id);
+CREATE INDEX payments_created_idx ON payments (created_ns, id);
+CREATE INDEX payments_amount_idx ON payments (amount, id);
+
+-- payments_report exposes fields of the payment document as columns for ad-hoc queries
+CREATE VIEW payments_report AS
+SELECT
+    id,
+    email,
+    status,
+    product_id,
+    amount,
+    json_extract(data, '$.currency')    AS currency,
+    json_extract(data, '$.user.name')   AS user_name,
+    json_extract(data, '$.provider_id') AS provider_id,
+    json_extract(data, '$.created_at')  AS created_at,
+    json_extract(data, '$.updated_at')  AS updated_at,
+    json_extract(data, '$.paid_at')     AS paid_at
+FROM payments;
+
+CREATE TABLE links (
+    payment_id TEXT PRIMARY KEY,
+    link       TEXT NOT NULL
+);
+
+CREATE TABLE file_versions (
+    file_id     TEXT     NOT NULL,
+    version     INTEGER  NOT NULL,
+    comment     TEXT     NOT NULL,
+    uploaded_at DATETIME NOT NULL,
+    PRIMARY KEY (file_id, version)
+);
+
+CREATE TABLE reminders (
+    payment_id   TEXT     PRIMARY KEY,
+    sent         INTEGER  NOT NULL,
+    last_sent_at DATETIME NOT NULL
+);
+
+CREATE TABLE unsubscribes (
+    email           TEXT     PRIMARY KEY,
+    unsubscribed_at DATETIME NOT NULL
+);
+
+CREATE TABLE used_tokens (
+    token      TEXT    PRIMARY KEY,
+    -- expires_ns is the expiration time in unix nanoseconds, integers are compared faster than text dates
+    expires_ns INTEGER NOT NULL
+);
+
+CREATE INDEX used_tokens_expires_ns_idx ON used_tokens (expires_ns);
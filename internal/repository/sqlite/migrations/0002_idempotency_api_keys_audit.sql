@@ -0,0 +1,32 @@
+CREATE TABLE idempotency_keys (
+    key          TEXT    PRIMARY KEY,
+    request_hash TEXT    NOT NULL,
+    -- payment_id is generated when the key is reserved and reused by retries of the failed request
+    payment_id   TEXT    NOT NULL,
+    -- state is in_progress, completed or released
+    state        TEXT    NOT NULL,
+    expires_ns   INTEGER NOT NULL
+);
+
+CREATE INDEX idempotency_keys_expires_ns_idx ON idempotency_keys (expires_ns);
+
+CREATE TABLE api_keys (
+    id   TEXT PRIMARY KEY,
+    data TEXT NOT NULL
+);
+
+-- AUTOINCREMENT keeps IDs of deleted rows from reuse, so IDs of newer entries are always greater
+CREATE TABLE audit_log (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    at_ns        INTEGER NOT NULL,
+    actor        TEXT    NOT NULL,
+    -- on_behalf_of is the person named by the client in X-Actor, it is not verified
+    on_behalf_of TEXT    NOT NULL,
+    action       TEXT    NOT NULL,
+    target       TEXT    NOT NULL,
+    data         TEXT    NOT NULL
+);
+
+CREATE INDEX audit_log_actor_idx ON audit_log (actor, id);
+CREATE INDEX audit_log_on_behalf_of_idx ON audit_log (on_behalf_of, id);
+CREATE INDEX audit_log_target_idx ON audit_log (target, id);
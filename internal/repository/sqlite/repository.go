@@ -0,0 +1,58 @@
+// Package sqlite opens the payment storage in SQLite, queries are shared with PostgreSQL in package sqlrepo
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"time"
+
+	_ "modernc.org/sqlite" // registers pure Go sqlite driver for database/sql
+
+	"github.com/Anton-Kraev/gopay/internal/repository/sqlrepo"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Open opens the database file in WAL mode, so reports can be run by other processes while the service is writing,
+// transactions take the write lock at the beginning and wait for it up to busyTimeout
+func Open(path string, busyTimeout time.Duration) (*sql.DB, error) {
+	query := url.Values{}
+	query.Add("_pragma", "journal_mode(WAL)")
+	query.Add("_pragma", "synchronous(NORMAL)")
+	query.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", busyTimeout.Milliseconds()))
+	query.Set("_txlock", "immediate")
+
+	db, err := sql.Open("sqlite", "file:"+path+"?"+query.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("sqlite.Open: %w", err)
+	}
+
+	return db, nil
+}
+
+// NewPaymentRepository applies pending migrations, the database must be opened with Open,
+// so transactions take the write lock when they begin and do not need to lock rows
+func NewPaymentRepository(ctx context.Context, db *sql.DB) (sqlrepo.PaymentRepository, error) {
+	files, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return sqlrepo.PaymentRepository{}, fmt.Errorf("sqlite.NewPaymentRepository: %w", err)
+	}
+
+	repo, err := sqlrepo.NewPaymentRepository(ctx, db, sqlrepo.Dialect{
+		Migrations: files,
+		SchemaMigrations: `CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER PRIMARY KEY,
+			applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+	})
+	if err != nil {
+		return sqlrepo.PaymentRepository{}, fmt.Errorf("sqlite.NewPaymentRepository: %w", err)
+	}
+
+	return repo, nil
+}
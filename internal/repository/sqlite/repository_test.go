@@ -0,0 +1,63 @@
+package sqlite_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Anton-Kraev/gopay"
+	"github.com/Anton-Kraev/gopay/internal/repository/sqlite"
+	"github.com/Anton-Kraev/gopay/internal/repository/sqlrepo"
+	"github.com/Anton-Kraev/gopay/storagetest"
+)
+
+func setupRepository(t *testing.T) sqlrepo.PaymentRepository {
+	t.Helper()
+
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "data.db"), time.Second)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo, err := sqlite.NewPaymentRepository(context.Background(), db)
+	require.NoError(t, err)
+
+	// repeated start must not apply migrations again
+	_, err = sqlite.NewPaymentRepository(context.Background(), db)
+	require.NoError(t, err)
+
+	return repo
+}
+
+func TestPaymentRepository_Contract(t *testing.T) {
+	t.Parallel()
+
+	storagetest.TestStorage(t, func(t *testing.T) gopay.Storage { return setupRepository(t) })
+}
+
+func TestPaymentRepository_IdempotencyKeys(t *testing.T) {
+	t.Parallel()
+
+	storagetest.TestIdempotencyStorage(t, func(t *testing.T) gopay.IdempotencyStorage { return setupRepository(t) })
+}
+
+func TestPaymentRepository_APIKeys(t *testing.T) {
+	t.Parallel()
+
+	storagetest.TestAPIKeyStorage(t, func(t *testing.T) gopay.APIKeyStorage { return setupRepository(t) })
+}
+
+func TestPaymentRepository_AuditLog(t *testing.T) {
+	t.Parallel()
+
+	storagetest.TestAuditStorage(t, func(t *testing.T) gopay.AuditStorage { return setupRepository(t) })
+}
+
+func TestPaymentRepository_AuditTx(t *testing.T) {
+	t.Parallel()
+
+	storagetest.TestAuditTx(t, func(t *testing.T) storagetest.AuditTxStorage { return setupRepository(t) })
+}
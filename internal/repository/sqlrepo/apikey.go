@@ -0,0 +1,108 @@
+package sqlrepo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+func (r PaymentRepository) SetAPIKey(key gopay.APIKey) error {
+	const op = "sqlrepo.PaymentRepository.SetAPIKey"
+
+	data, err := json.Marshal(key)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err = r.db.ExecContext(context.Background(), r.bind(`
+		INSERT INTO api_keys (id, data) VALUES (?, ?)
+		ON CONFLICT (id) DO UPDATE SET data = EXCLUDED.data`),
+		key.ID, string(data),
+	); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (r PaymentRepository) GetAPIKey(id string) (gopay.APIKey, error) {
+	const op = "sqlrepo.PaymentRepository.GetAPIKey"
+
+	var data []byte
+
+	err := r.db.QueryRowContext(
+		context.Background(), r.bind("SELECT data FROM api_keys WHERE id = ?"), id,
+	).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return gopay.APIKey{}, fmt.Errorf("%s: %w", op, errAPIKeyNotFound)
+	}
+
+	if err != nil {
+		return gopay.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var key gopay.APIKey
+	if err = json.Unmarshal(data, &key); err != nil {
+		return gopay.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return key, nil
+}
+
+// ListAPIKeys returns keys ordered by ID
+func (r PaymentRepository) ListAPIKeys() ([]gopay.APIKey, error) {
+	const op = "sqlrepo.PaymentRepository.ListAPIKeys"
+
+	rows, err := r.db.QueryContext(context.Background(), "SELECT data FROM api_keys ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer rows.Close()
+
+	var keys []gopay.APIKey
+
+	for rows.Next() {
+		var data []byte
+		if err = rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		var key gopay.APIKey
+		if err = json.Unmarshal(data, &key); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return keys, nil
+}
+
+func (r PaymentRepository) DeleteAPIKey(id string) error {
+	const op = "sqlrepo.PaymentRepository.DeleteAPIKey"
+
+	res, err := r.db.ExecContext(context.Background(), r.bind("DELETE FROM api_keys WHERE id = ?"), id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, errAPIKeyNotFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,158 @@
+package sqlrepo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+// AppendAuditEntry saves the entry with the next identity of the table as ID
+func (r PaymentRepository) AppendAuditEntry(entry gopay.AuditEntry) (gopay.AuditEntry, error) {
+	entry, err := r.appendAuditEntry(r.db, entry)
+	if err != nil {
+		return gopay.AuditEntry{}, fmt.Errorf("sqlrepo.PaymentRepository.AppendAuditEntry: %w", err)
+	}
+
+	return entry, nil
+}
+
+// AppendAuditEntry saves the entry in the transaction, so it is committed together with the change
+func (t paymentTx) AppendAuditEntry(entry gopay.AuditEntry) (gopay.AuditEntry, error) {
+	entry, err := t.r.appendAuditEntry(t.tx, entry)
+	if err != nil {
+		return gopay.AuditEntry{}, fmt.Errorf("sqlrepo.paymentTx.AppendAuditEntry: %w", err)
+	}
+
+	return entry, nil
+}
+
+// rowQuerier is *sql.DB or *sql.Tx
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (r PaymentRepository) appendAuditEntry(q rowQuerier, entry gopay.AuditEntry) (gopay.AuditEntry, error) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return gopay.AuditEntry{}, err
+	}
+
+	var id int64
+
+	if err = q.QueryRowContext(context.Background(), r.bind(`
+		INSERT INTO audit_log (at_ns, actor, on_behalf_of, action, target, data) VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING id`),
+		entry.At.UnixNano(), entry.Name, entry.OnBehalfOf, entry.Action, entry.Target, string(data),
+	).Scan(&id); err != nil {
+		return gopay.AuditEntry{}, err
+	}
+
+	entry.ID = uint64(id)
+
+	return entry, nil
+}
+
+// ListAuditEntries returns entries from the newest one, the cursor has the same format as in the Bolt repository:
+// big-endian ID of the last returned entry
+func (r PaymentRepository) ListAuditEntries(filter gopay.AuditFilter) (gopay.AuditPage, error) {
+	const op = "sqlrepo.PaymentRepository.ListAuditEntries"
+
+	var (
+		where []string
+		args  []any
+	)
+
+	arg := func(v any) string {
+		args = append(args, v)
+
+		return "?"
+	}
+
+	if filter.Actor != "" {
+		where = append(where, fmt.Sprintf("(actor = %s OR on_behalf_of = %s)", arg(filter.Actor), arg(filter.Actor)))
+	}
+
+	if filter.Action != "" {
+		where = append(where, "action = "+arg(filter.Action))
+	}
+
+	if filter.Target != "" {
+		where = append(where, "target = "+arg(filter.Target))
+	}
+
+	if !filter.From.IsZero() {
+		where = append(where, "at_ns >= "+arg(filter.From.UnixNano()))
+	}
+
+	if !filter.To.IsZero() {
+		where = append(where, "at_ns < "+arg(filter.To.UnixNano()))
+	}
+
+	if filter.Cursor != "" {
+		before, err := base64.RawURLEncoding.DecodeString(filter.Cursor)
+		if err != nil || len(before) != 8 {
+			return gopay.AuditPage{}, fmt.Errorf("%s: %w", op, gopay.ErrInvalidCursor)
+		}
+
+		where = append(where, "id < "+arg(int64(binary.BigEndian.Uint64(before))))
+	}
+
+	query := "SELECT id, data FROM audit_log"
+	if len(where) != 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+
+	query += " ORDER BY id DESC LIMIT " + arg(filter.Limit+1)
+
+	page, err := r.auditPage(query, args, filter.Limit)
+	if err != nil {
+		return gopay.AuditPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return page, nil
+}
+
+func (r PaymentRepository) auditPage(query string, args []any, limit int) (gopay.AuditPage, error) {
+	rows, err := r.db.QueryContext(context.Background(), r.bind(query), args...)
+	if err != nil {
+		return gopay.AuditPage{}, err
+	}
+
+	defer rows.Close()
+
+	page := gopay.AuditPage{Entries: make([]gopay.AuditEntry, 0, limit)}
+
+	for rows.Next() {
+		if len(page.Entries) == limit {
+			last := page.Entries[len(page.Entries)-1]
+			page.NextCursor = base64.RawURLEncoding.EncodeToString(binary.BigEndian.AppendUint64(nil, last.ID))
+
+			break
+		}
+
+		var (
+			id   int64
+			data []byte
+		)
+
+		if err = rows.Scan(&id, &data); err != nil {
+			return gopay.AuditPage{}, err
+		}
+
+		var entry gopay.AuditEntry
+		if err = json.Unmarshal(data, &entry); err != nil {
+			return gopay.AuditPage{}, err
+		}
+
+		entry.ID = uint64(id)
+		page.Entries = append(page.Entries, entry)
+	}
+
+	return page, rows.Err()
+}
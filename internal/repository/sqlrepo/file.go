@@ -0,0 +1,80 @@
+package sqlrepo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+func (r PaymentRepository) GetFileVersions(id gopay.ID) ([]gopay.FileVersion, error) {
+	const op = "sqlrepo.PaymentRepository.GetFileVersions"
+
+	rows, err := r.db.QueryContext(
+		context.Background(),
+		r.bind("SELECT version, comment, uploaded_at FROM file_versions WHERE file_id = ? ORDER BY version"),
+		id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer rows.Close()
+
+	var versions []gopay.FileVersion
+
+	for rows.Next() {
+		var version gopay.FileVersion
+		if err = rows.Scan(&version.Version, &version.Comment, &version.UploadedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		version.UploadedAt = version.UploadedAt.UTC()
+		versions = append(versions, version)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return versions, nil
+}
+
+func (r PaymentRepository) AddFileVersions(id gopay.ID, versions ...gopay.FileVersion) error {
+	if err := r.inTx(func(tx *sql.Tx) error {
+		var latest uint
+
+		if err := tx.QueryRowContext(
+			context.Background(), r.bind("SELECT COALESCE(MAX(version), 0) FROM file_versions WHERE file_id = ?"), id,
+		).Scan(&latest); err != nil {
+			return err
+		}
+
+		// concurrent uploads must not get the same version number
+		if len(versions) != 0 && versions[0].Version <= latest {
+			return errFileVersionConflict
+		}
+
+		for _, version := range versions {
+			res, err := tx.ExecContext(context.Background(), r.bind(`
+				INSERT INTO file_versions (file_id, version, comment, uploaded_at) VALUES (?, ?, ?, ?)
+				ON CONFLICT DO NOTHING`),
+				id, int64(version.Version), version.Comment, version.UploadedAt,
+			)
+			if err != nil {
+				return err
+			}
+
+			if n, err := res.RowsAffected(); err != nil || n == 0 {
+				return errFileVersionConflict
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return fmt.Errorf("sqlrepo.PaymentRepository.AddFileVersions: %w", err)
+	}
+
+	return nil
+}
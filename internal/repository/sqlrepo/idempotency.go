@@ -0,0 +1,110 @@
+package sqlrepo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+// ReserveIdempotencyKey saves the record unless the key is already saved and not expired, the released key
+// is reserved again by the request with the same hash keeping the saved payment ID
+func (r PaymentRepository) ReserveIdempotencyKey(
+	key string, record gopay.IdempotencyRecord,
+) (gopay.IdempotencyRecord, bool, error) {
+	var (
+		saved    gopay.IdempotencyRecord
+		reserved bool
+	)
+
+	if err := r.inTx(func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(
+			context.Background(), r.bind("DELETE FROM idempotency_keys WHERE expires_ns < ?"), time.Now().UnixNano(),
+		); err != nil {
+			return err
+		}
+
+		res, err := tx.ExecContext(context.Background(), r.bind(`
+			INSERT INTO idempotency_keys (key, request_hash, payment_id, state, expires_ns) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT DO NOTHING`),
+			key, record.RequestHash, record.PaymentID, record.State, record.ExpiresAt.UnixNano(),
+		)
+		if err != nil {
+			return err
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil || n == 1 {
+			saved, reserved = record, n == 1
+
+			return err
+		}
+
+		if res, err = tx.ExecContext(context.Background(), r.bind(`
+			UPDATE idempotency_keys SET state = ? WHERE key = ? AND state = ? AND request_hash = ?`),
+			gopay.IdempotencyInProgress, key, gopay.IdempotencyReleased, record.RequestHash,
+		); err != nil {
+			return err
+		}
+
+		if n, err = res.RowsAffected(); err != nil {
+			return err
+		}
+
+		reserved = n == 1
+
+		var expiresNs int64
+
+		if err = tx.QueryRowContext(
+			context.Background(),
+			r.bind("SELECT request_hash, payment_id, state, expires_ns FROM idempotency_keys WHERE key = ?"), key,
+		).Scan(&saved.RequestHash, &saved.PaymentID, &saved.State, &expiresNs); err != nil {
+			return err
+		}
+
+		saved.ExpiresAt = time.Unix(0, expiresNs).UTC()
+
+		return nil
+	}); err != nil {
+		return gopay.IdempotencyRecord{}, false, fmt.Errorf("sqlrepo.PaymentRepository.ReserveIdempotencyKey: %w", err)
+	}
+
+	return saved, reserved, nil
+}
+
+// CompleteIdempotencyKey saves ID of the payment created by the request with the key
+func (r PaymentRepository) CompleteIdempotencyKey(key string, id gopay.ID) error {
+	const op = "sqlrepo.PaymentRepository.CompleteIdempotencyKey"
+
+	res, err := r.db.ExecContext(
+		context.Background(), r.bind("UPDATE idempotency_keys SET payment_id = ?, state = ? WHERE key = ?"),
+		id, gopay.IdempotencyCompleted, key,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, errIdempotencyKeyNotFound)
+	}
+
+	return nil
+}
+
+// ReleaseIdempotencyKey marks the request with the key failed, so the request can be retried
+func (r PaymentRepository) ReleaseIdempotencyKey(key string) error {
+	if _, err := r.db.ExecContext(
+		context.Background(), r.bind("UPDATE idempotency_keys SET state = ? WHERE key = ?"), gopay.IdempotencyReleased, key,
+	); err != nil {
+		return fmt.Errorf("sqlrepo.PaymentRepository.ReleaseIdempotencyKey: %w", err)
+	}
+
+	return nil
+}
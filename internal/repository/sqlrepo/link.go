@@ -0,0 +1,25 @@
+package sqlrepo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+func (r PaymentRepository) GetLink(id gopay.ID) (gopay.Link, error) {
+	var link gopay.Link
+
+	err := r.db.QueryRowContext(context.Background(), r.bind("SELECT link FROM links WHERE payment_id = ?"), id).Scan(&link)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = errLinkNotFound
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("sqlrepo.PaymentRepository.GetLink: %w", err)
+	}
+
+	return link, nil
+}
@@ -0,0 +1,136 @@
+package sqlrepo
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+// ListPayments returns a page of payments using keyset pagination, all of them without positive limit,
+// the cursor has the same format as in the Bolt repository: big-endian sort value followed by payment ID
+func (r PaymentRepository) ListPayments(filter gopay.PaymentFilter) (gopay.PaymentPage, error) {
+	const op = "sqlrepo.PaymentRepository.ListPayments"
+
+	var (
+		where []string
+		args  []any
+	)
+
+	arg := func(v any) string {
+		args = append(args, v)
+
+		return "?"
+	}
+
+	if filter.Status != "" {
+		where = append(where, "status = "+arg(filter.Status))
+	}
+
+	if filter.Email != "" {
+		where = append(where, "email = "+arg(strings.ToLower(filter.Email)))
+	}
+
+	if filter.ProductID != "" {
+		where = append(where, "product_id = "+arg(filter.ProductID))
+	}
+
+	if !filter.CreatedFrom.IsZero() {
+		where = append(where, "created_ns >= "+arg(filter.CreatedFrom.UnixNano()))
+	}
+
+	if !filter.CreatedTo.IsZero() {
+		where = append(where, "created_ns < "+arg(filter.CreatedTo.UnixNano()))
+	}
+
+	if filter.MinAmount != 0 {
+		where = append(where, "amount >= "+arg(int64(filter.MinAmount)))
+	}
+
+	if filter.MaxAmount != 0 {
+		where = append(where, "amount <= "+arg(int64(filter.MaxAmount)))
+	}
+
+	column, order, cmp := "created_ns", "ASC", ">"
+
+	if filter.Sort == gopay.SortAmountAsc || filter.Sort == gopay.SortAmountDesc {
+		column = "amount"
+	}
+
+	if strings.HasPrefix(string(filter.Sort), "-") {
+		order, cmp = "DESC", "<"
+	}
+
+	if filter.Cursor != "" {
+		after, err := base64.RawURLEncoding.DecodeString(filter.Cursor)
+		if err != nil || len(after) <= 8 {
+			return gopay.PaymentPage{}, fmt.Errorf("%s: %w", op, gopay.ErrInvalidCursor)
+		}
+
+		value := int64(binary.BigEndian.Uint64(after[:8]))
+		where = append(where, fmt.Sprintf("(%s, id) %s (%s, %s)", column, cmp, arg(value), arg(string(after[8:]))))
+	}
+
+	query := "SELECT id, data, " + column + " FROM payments"
+	if len(where) != 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+
+	query += fmt.Sprintf(" ORDER BY %s %s, id %s", column, order, order)
+
+	if filter.Limit > 0 {
+		query += " LIMIT " + arg(filter.Limit+1)
+	}
+
+	page, err := r.page(query, args, filter.Limit)
+	if err != nil {
+		return gopay.PaymentPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return page, nil
+}
+
+func (r PaymentRepository) page(query string, args []any, limit int) (gopay.PaymentPage, error) {
+	rows, err := r.db.QueryContext(context.Background(), r.bind(query), args...)
+	if err != nil {
+		return gopay.PaymentPage{}, err
+	}
+
+	defer rows.Close()
+
+	var (
+		page  = gopay.PaymentPage{Payments: make([]gopay.PaymentEntry, 0, max(limit, 0))}
+		value int64
+	)
+
+	for rows.Next() {
+		if limit > 0 && len(page.Payments) == limit {
+			last := page.Payments[len(page.Payments)-1]
+			key := binary.BigEndian.AppendUint64(nil, uint64(value))
+			page.NextCursor = base64.RawURLEncoding.EncodeToString(append(key, last.ID...))
+
+			break
+		}
+
+		var (
+			entry gopay.PaymentEntry
+			data  []byte
+		)
+
+		if err = rows.Scan(&entry.ID, &data, &value); err != nil {
+			return gopay.PaymentPage{}, err
+		}
+
+		if err = json.Unmarshal(data, &entry.Payment); err != nil {
+			return gopay.PaymentPage{}, err
+		}
+
+		page.Payments = append(page.Payments, entry)
+	}
+
+	return page, rows.Err()
+}
@@ -0,0 +1,77 @@
+package sqlrepo
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// migrate applies migrations which are not recorded in schema_migrations table, every migration
+// is applied in its own transaction, file names start with the version number
+func (r PaymentRepository) migrate(ctx context.Context) error {
+	files, err := fs.Glob(r.dialect.Migrations, "*.sql")
+	if err != nil {
+		return fmt.Errorf("sqlrepo.migrate: %w", err)
+	}
+
+	sort.Strings(files)
+
+	if _, err = r.db.ExecContext(ctx, r.dialect.SchemaMigrations); err != nil {
+		return fmt.Errorf("sqlrepo.migrate: %w", err)
+	}
+
+	for _, file := range files {
+		if err = r.applyMigration(ctx, file); err != nil {
+			return fmt.Errorf("sqlrepo.migrate: %s: %w", file, err)
+		}
+	}
+
+	return nil
+}
+
+func (r PaymentRepository) applyMigration(ctx context.Context, file string) error {
+	name, _, _ := strings.Cut(file, "_")
+
+	version, err := strconv.Atoi(name)
+	if err != nil {
+		return err
+	}
+
+	query, err := fs.ReadFile(r.dialect.Migrations, file)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = tx.Rollback() }()
+
+	if r.dialect.LockMigrations != "" {
+		if _, err = tx.ExecContext(ctx, r.dialect.LockMigrations); err != nil {
+			return err
+		}
+	}
+
+	var applied bool
+	if err = tx.QueryRowContext(
+		ctx, r.bind("SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = ?)"), version,
+	).Scan(&applied); err != nil || applied {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, string(query)); err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, r.bind("INSERT INTO schema_migrations (version) VALUES (?)"), version); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
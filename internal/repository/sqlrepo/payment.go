@@ -0,0 +1,127 @@
+package sqlrepo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+func (r PaymentRepository) Get(id gopay.ID) (gopay.Payment, error) {
+	pay, err := scanPayment(r.db.QueryRowContext(context.Background(), r.bind("SELECT data FROM payments WHERE id = ?"), id))
+	if err != nil {
+		return gopay.Payment{}, fmt.Errorf("sqlrepo.PaymentRepository.Get: %w", err)
+	}
+
+	return pay, nil
+}
+
+func (r PaymentRepository) GetStatus(id gopay.ID) (gopay.Status, error) {
+	var status gopay.Status
+
+	err := r.db.QueryRowContext(context.Background(), r.bind("SELECT status FROM payments WHERE id = ?"), id).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = errPaymentNotFound
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("sqlrepo.PaymentRepository.GetStatus: %w", err)
+	}
+
+	return status, nil
+}
+
+func (r PaymentRepository) GetStatuses() (map[gopay.ID]gopay.Status, error) {
+	const op = "sqlrepo.PaymentRepository.GetStatuses"
+
+	rows, err := r.db.QueryContext(context.Background(), "SELECT id, status FROM payments")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer rows.Close()
+
+	statuses := make(map[gopay.ID]gopay.Status)
+
+	for rows.Next() {
+		var (
+			id     gopay.ID
+			status gopay.Status
+		)
+
+		if err = rows.Scan(&id, &status); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		statuses[id] = status
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return statuses, nil
+}
+
+func (r PaymentRepository) GetProductPayments(productID gopay.ID) (map[gopay.ID]gopay.Payment, error) {
+	payments, err := r.query("SELECT id, data FROM payments WHERE product_id = ?", productID)
+	if err != nil {
+		return nil, fmt.Errorf("sqlrepo.PaymentRepository.GetProductPayments: %w", err)
+	}
+
+	return payments, nil
+}
+
+func (r PaymentRepository) GetPaymentsByStatus(status gopay.Status) (map[gopay.ID]gopay.Payment, error) {
+	payments, err := r.query("SELECT id, data FROM payments WHERE status = ?", status)
+	if err != nil {
+		return nil, fmt.Errorf("sqlrepo.PaymentRepository.GetPaymentsByStatus: %w", err)
+	}
+
+	return payments, nil
+}
+
+func (r PaymentRepository) GetPaymentsByEmail(email string) (map[gopay.ID]gopay.Payment, error) {
+	payments, err := r.query("SELECT id, data FROM payments WHERE email = ?", strings.ToLower(email))
+	if err != nil {
+		return nil, fmt.Errorf("sqlrepo.PaymentRepository.GetPaymentsByEmail: %w", err)
+	}
+
+	return payments, nil
+}
+
+// query returns payments selected by the query, it must select id and data columns
+func (r PaymentRepository) query(query string, args ...any) (map[gopay.ID]gopay.Payment, error) {
+	rows, err := r.db.QueryContext(context.Background(), r.bind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	payments := make(map[gopay.ID]gopay.Payment)
+
+	for rows.Next() {
+		var (
+			id   gopay.ID
+			data []byte
+			pay  gopay.Payment
+		)
+
+		if err = rows.Scan(&id, &data); err != nil {
+			return nil, err
+		}
+
+		if err = json.Unmarshal(data, &pay); err != nil {
+			return nil, err
+		}
+
+		payments[id] = pay
+	}
+
+	return payments, rows.Err()
+}
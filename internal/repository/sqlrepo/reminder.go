@@ -0,0 +1,82 @@
+package sqlrepo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+func (r PaymentRepository) GetReminder(id gopay.ID) (gopay.Reminder, error) {
+	var reminder gopay.Reminder
+
+	err := r.db.QueryRowContext(
+		context.Background(), r.bind("SELECT sent, last_sent_at FROM reminders WHERE payment_id = ?"), id,
+	).Scan(&reminder.Sent, &reminder.LastSentAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return gopay.Reminder{}, nil
+	}
+
+	if err != nil {
+		return gopay.Reminder{}, fmt.Errorf("sqlrepo.PaymentRepository.GetReminder: %w", err)
+	}
+
+	reminder.LastSentAt = reminder.LastSentAt.UTC()
+
+	return reminder, nil
+}
+
+func (r PaymentRepository) ClaimReminder(id gopay.ID, sent uint, reminder gopay.Reminder) (bool, error) {
+	const op = "sqlrepo.PaymentRepository.ClaimReminder"
+
+	// missing row means no reminders were sent, so it is inserted only when none are expected
+	query, args := `
+		UPDATE reminders SET sent = ?, last_sent_at = ? WHERE payment_id = ? AND sent = ?`,
+		[]any{int64(reminder.Sent), reminder.LastSentAt, id, int64(sent)}
+	if sent == 0 {
+		query, args = `
+		INSERT INTO reminders (payment_id, sent, last_sent_at) VALUES (?, ?, ?)
+		ON CONFLICT (payment_id) DO UPDATE SET sent = EXCLUDED.sent, last_sent_at = EXCLUDED.last_sent_at
+		WHERE reminders.sent = 0`,
+			[]any{id, int64(reminder.Sent), reminder.LastSentAt}
+	}
+
+	res, err := r.db.ExecContext(context.Background(), r.bind(query), args...)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affected == 1, nil
+}
+
+func (r PaymentRepository) IsUnsubscribed(email string) (bool, error) {
+	var unsubscribed bool
+
+	if err := r.db.QueryRowContext(
+		context.Background(), r.bind("SELECT EXISTS (SELECT 1 FROM unsubscribes WHERE email = ?)"), email,
+	).Scan(&unsubscribed); err != nil {
+		return false, fmt.Errorf("sqlrepo.PaymentRepository.IsUnsubscribed: %w", err)
+	}
+
+	return unsubscribed, nil
+}
+
+func (r PaymentRepository) Unsubscribe(email string) error {
+	if _, err := r.db.ExecContext(
+		context.Background(),
+		r.bind("INSERT INTO unsubscribes (email, unsubscribed_at) VALUES (?, ?) ON CONFLICT DO NOTHING"),
+		email, time.Now().UTC(),
+	); err != nil {
+		return fmt.Errorf("sqlrepo.PaymentRepository.Unsubscribe: %w", err)
+	}
+
+	return nil
+}
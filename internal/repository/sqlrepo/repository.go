@@ -0,0 +1,96 @@
+// Package sqlrepo implements the payment storage over database/sql, packages postgres and sqlite
+// open the database and describe its differences with Dialect
+package sqlrepo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+var (
+	errPaymentNotFound = fmt.Errorf("payment %w", gopay.ErrNotFound)
+	errLinkNotFound    = fmt.Errorf("link %w", gopay.ErrNotFound)
+
+	errIdempotencyKeyNotFound = fmt.Errorf("idempotency key %w", gopay.ErrNotFound)
+	errAPIKeyNotFound         = fmt.Errorf("API key %w", gopay.ErrNotFound)
+
+	errFileVersionConflict = fmt.Errorf("file version %w", gopay.ErrAlreadyExists)
+)
+
+// Dialect describes how the database differs from SQL shared by the supported databases,
+// queries are written with ? placeholders
+type Dialect struct {
+	// Placeholder returns the placeholder of the n-th query argument starting from 1, ? is used if nil
+	Placeholder func(n int) string
+	// LockRows is appended to selects of payments in transactions to lock them until the transaction ends,
+	// empty if transactions take the write lock of the whole database when they begin
+	LockRows string
+	// Migrations contains SQL files named <version>_<name>.sql
+	Migrations fs.FS
+	// SchemaMigrations creates the table of applied migration versions if it does not exist
+	SchemaMigrations string
+	// LockMigrations is run at the beginning of every migration transaction, so processes started
+	// at the same time do not migrate concurrently, empty if the transaction takes the write lock itself
+	LockMigrations string
+}
+
+type PaymentRepository struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+// NewPaymentRepository applies pending migrations, the connection pool is configured by the caller
+func NewPaymentRepository(ctx context.Context, db *sql.DB, dialect Dialect) (PaymentRepository, error) {
+	r := PaymentRepository{db: db, dialect: dialect}
+
+	if err := r.migrate(ctx); err != nil {
+		return PaymentRepository{}, fmt.Errorf("sqlrepo.NewPaymentRepository: %w", err)
+	}
+
+	return r, nil
+}
+
+// inTx runs fn in a transaction which is committed if fn returns nil and rolled back otherwise
+func (r PaymentRepository) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	if err = fn(tx); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+
+	return tx.Commit()
+}
+
+// bind replaces ? placeholders of the query with placeholders of the dialect
+func (r PaymentRepository) bind(query string) string {
+	if r.dialect.Placeholder == nil {
+		return query
+	}
+
+	var (
+		sb strings.Builder
+		n  int
+	)
+
+	for _, c := range query {
+		if c != '?' {
+			sb.WriteRune(c)
+
+			continue
+		}
+
+		n++
+		sb.WriteString(r.dialect.Placeholder(n))
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,40 @@
+package sqlrepo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// MarkTokenUsed saves one-time token until it expires, returns false if it is already used
+func (r PaymentRepository) MarkTokenUsed(token string, expiresAt time.Time) (bool, error) {
+	var marked bool
+
+	if err := r.inTx(func(tx *sql.Tx) error {
+		// expired tokens are rejected by signature check, so there is no need to keep them
+		if _, err := tx.ExecContext(
+			context.Background(), r.bind("DELETE FROM used_tokens WHERE expires_ns < ?"), time.Now().UnixNano(),
+		); err != nil {
+			return err
+		}
+
+		res, err := tx.ExecContext(
+			context.Background(),
+			r.bind("INSERT INTO used_tokens (token, expires_ns) VALUES (?, ?) ON CONFLICT DO NOTHING"),
+			token, expiresAt.UnixNano(),
+		)
+		if err != nil {
+			return err
+		}
+
+		n, err := res.RowsAffected()
+		marked = n == 1
+
+		return err
+	}); err != nil {
+		return false, fmt.Errorf("sqlrepo.PaymentRepository.MarkTokenUsed: %w", err)
+	}
+
+	return marked, nil
+}
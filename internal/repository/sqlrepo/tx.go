@@ -0,0 +1,113 @@
+package sqlrepo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+// Update runs fn in a single transaction, payments read through the transaction are locked until it ends
+// or the transaction holds the write lock of the whole database, so concurrent updates of the same payment
+// are serialized
+func (r PaymentRepository) Update(fn func(tx gopay.PaymentTx) error) error {
+	if err := r.inTx(func(tx *sql.Tx) error {
+		return fn(paymentTx{r: r, tx: tx})
+	}); err != nil {
+		return fmt.Errorf("sqlrepo.PaymentRepository.Update: %w", err)
+	}
+
+	return nil
+}
+
+type paymentTx struct {
+	r  PaymentRepository
+	tx *sql.Tx
+}
+
+func (t paymentTx) Get(id gopay.ID) (gopay.Payment, error) {
+	pay, err := scanPayment(t.tx.QueryRowContext(
+		context.Background(), t.r.bind("SELECT data FROM payments WHERE id = ?"+t.r.dialect.LockRows), id,
+	))
+	if err != nil {
+		return gopay.Payment{}, fmt.Errorf("sqlrepo.paymentTx.Get: %w", err)
+	}
+
+	return pay, nil
+}
+
+func (t paymentTx) Set(id gopay.ID, pay gopay.Payment) error {
+	data, err := json.Marshal(pay)
+	if err != nil {
+		return fmt.Errorf("sqlrepo.paymentTx.Set: %w", err)
+	}
+
+	if _, err = t.tx.ExecContext(context.Background(), t.r.bind(`
+		INSERT INTO payments (id, email, status, product_id, amount, created_ns, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			email = EXCLUDED.email,
+			status = EXCLUDED.status,
+			product_id = EXCLUDED.product_id,
+			amount = EXCLUDED.amount,
+			created_ns = EXCLUDED.created_ns,
+			data = EXCLUDED.data`),
+		id,
+		strings.ToLower(pay.User.Email),
+		pay.Status,
+		pay.ProductID,
+		int64(pay.Amount),
+		unixNano(pay),
+		string(data),
+	); err != nil {
+		return fmt.Errorf("sqlrepo.paymentTx.Set: %w", err)
+	}
+
+	return nil
+}
+
+func (t paymentTx) SetLink(id gopay.ID, link gopay.Link) error {
+	if _, err := t.tx.ExecContext(context.Background(), t.r.bind(`
+		INSERT INTO links (payment_id, link) VALUES (?, ?)
+		ON CONFLICT (payment_id) DO UPDATE SET link = EXCLUDED.link`),
+		id, link,
+	); err != nil {
+		return fmt.Errorf("sqlrepo.paymentTx.SetLink: %w", err)
+	}
+
+	return nil
+}
+
+func scanPayment(row *sql.Row) (gopay.Payment, error) {
+	var (
+		pay  gopay.Payment
+		data []byte
+	)
+
+	if err := row.Scan(&data); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return gopay.Payment{}, errPaymentNotFound
+		}
+
+		return gopay.Payment{}, err
+	}
+
+	if err := json.Unmarshal(data, &pay); err != nil {
+		return gopay.Payment{}, err
+	}
+
+	return pay, nil
+}
+
+// unixNano is the payment creation time used for ordering, payments without timestamp go first
+func unixNano(pay gopay.Payment) int64 {
+	if pay.CreatedAt.IsZero() || pay.CreatedAt.UnixNano() < 0 {
+		return 0
+	}
+
+	return pay.CreatedAt.UnixNano()
+}
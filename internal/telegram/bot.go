@@ -15,7 +15,9 @@ type Telegram struct {
 	bot               *telego.Bot
 	fsm               map[int64]state                   // finite state machine (store user states in format "user_id: state")
 	newPaymentService map[int64]gopay.NewPaymentService // service with user data for creating new payments
+	allPaymentService map[int64]gopay.AllPaymentService // service with filters and cursor of the listed payments
 	whitelist         []int64                           // list of allowed users (gopay admins user ids)
+	scopes            map[int64][]gopay.Scope           // scopes of admins with limited access
 	log               *slog.Logger
 }
 
@@ -30,7 +32,9 @@ func New(config Config, adminClient gopay.AdminClient, log *slog.Logger) (*Teleg
 		bot:               tgBot,
 		fsm:               make(map[int64]state),
 		newPaymentService: make(map[int64]gopay.NewPaymentService),
+		allPaymentService: make(map[int64]gopay.AllPaymentService),
 		whitelist:         config.AdminIDs,
+		scopes:            config.AdminScopes,
 		log:               log,
 	}, nil
 }
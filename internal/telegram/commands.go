@@ -1,8 +1,30 @@
 package telegram
 
+import "github.com/Anton-Kraev/gopay"
+
 const (
 	cmdStart      = "/start"
 	cmdNewPayment = "/new_payment"
 	cmdAllPayment = "/all_payment"
+	cmdNextPage   = "/next_page"
 	cmdGetPayment = "/get_payment"
+	cmdResend     = "/resend"
+	cmdAudit      = "/audit"
 )
+
+const (
+	// paymentsPageLimit is the number of payments in a single message
+	paymentsPageLimit = 20
+	// auditPageLimit is the number of audit entries in a single message
+	auditPageLimit = 20
+)
+
+// commandScopes are scopes required by commands, they match the scopes of API routes called by the commands
+var commandScopes = map[string]gopay.Scope{
+	cmdNewPayment: gopay.ScopePaymentsWrite,
+	cmdAllPayment: gopay.ScopePaymentsRead,
+	cmdNextPage:   gopay.ScopePaymentsRead,
+	cmdGetPayment: gopay.ScopePaymentsRead,
+	cmdResend:     gopay.ScopePurchasesResend,
+	cmdAudit:      gopay.ScopeAdmin,
+}
@@ -1,6 +1,10 @@
 package telegram
 
+import "github.com/Anton-Kraev/gopay"
+
 type Config struct {
 	BotToken string
 	AdminIDs []int64
+	// AdminScopes limits commands of the admins, admins without scopes may use all commands
+	AdminScopes map[int64][]gopay.Scope
 }
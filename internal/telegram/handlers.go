@@ -44,6 +44,18 @@ func (t *Telegram) checkAuth(chatID int64) bool {
 	return slices.Contains(t.whitelist, chatID)
 }
 
+// client returns API client which acts on behalf of the admin, so the admin is recorded in the audit log
+func (t *Telegram) client(update telego.Update) gopay.AdminClient {
+	return t.adminClient.WithActor(gopay.ActorTelegram(update.Message.Chat.ID))
+}
+
+// checkScope reports whether the admin may use commands of the scope
+func (t *Telegram) checkScope(chatID int64, scope gopay.Scope) bool {
+	scopes, ok := t.scopes[chatID]
+
+	return !ok || gopay.HasScope(scopes, scope)
+}
+
 func (t *Telegram) handleUnauthenticated(ctx context.Context, update telego.Update) error {
 	return t.sendMessage(
 		ctx,
@@ -53,6 +65,32 @@ func (t *Telegram) handleUnauthenticated(ctx context.Context, update telego.Upda
 	)
 }
 
+// handleForbidden rejects the command and records the denial in the audit log of the server,
+// the admin is answered even if the server does not record it
+func (t *Telegram) handleForbidden(ctx context.Context, update telego.Update, cmd string, scope gopay.Scope) error {
+	chatID := update.Message.Chat.ID
+	delete(t.fsm, chatID)
+
+	t.log.Warn("access denied",
+		slog.String("audit", "access_denied"),
+		slog.Int64("chat_id", chatID),
+		slog.String("command", cmd),
+		slog.String("scope", string(scope)),
+	)
+
+	err := t.client(update).NewDenialService().Command(cmd).Scope(scope).Do()
+	if err != nil {
+		err = fmt.Errorf("telegram.handleForbidden: %w", err)
+	}
+
+	return errors.Join(err, t.sendMessage(
+		ctx,
+		update,
+		"telegram.handleForbidden",
+		"у вас нет прав на команду "+cmd+" (требуется "+string(scope)+"), обратитесь к администратору",
+	))
+}
+
 func (t *Telegram) handleMessage(ctx context.Context, update telego.Update) error {
 	var err error
 
@@ -66,6 +104,14 @@ func (t *Telegram) handleMessage(ctx context.Context, update telego.Update) erro
 		)
 	}
 
+	if scope, ok := commandScopes[text[0]]; ok && !t.checkScope(update.Message.Chat.ID, scope) {
+		if err = t.handleForbidden(ctx, update, text[0], scope); err != nil {
+			return fmt.Errorf("telegram.handleMessage: %w", err)
+		}
+
+		return nil
+	}
+
 	switch text[0] {
 	case cmdStart:
 		err = t.handleCmdStart(ctx, update)
@@ -73,8 +119,14 @@ func (t *Telegram) handleMessage(ctx context.Context, update telego.Update) erro
 		err = t.handleCmdNewPayment(ctx, update)
 	case cmdAllPayment:
 		err = t.handleCmdAllPayment(ctx, update)
+	case cmdNextPage:
+		err = t.handleCmdNextPage(ctx, update)
 	case cmdGetPayment:
 		err = t.handleCmdGetPayment(ctx, update)
+	case cmdResend:
+		err = t.handleCmdResend(ctx, update)
+	case cmdAudit:
+		err = t.handleCmdAudit(ctx, update)
 	default:
 		err = t.handleState(ctx, update)
 	}
@@ -95,15 +147,18 @@ func (t *Telegram) handleCmdStart(ctx context.Context, update telego.Update) err
 		"telegram.handleCmdStart",
 		`вы успешно авторизованы, список доступных команд:
 				1) /new_payment --- создание нового платежа
-				2) /all_payment --- получение статусов всех платежей
-				3) /get_payment <id> --- получение статуса платежа по его id
+				2) /all_payment [status] --- получение статусов последних платежей, можно указать статус
+				3) /next_page --- следующая страница списка платежей
+				4) /get_payment <id> --- получение статуса и истории платежа по его id
+				5) /resend <email> --- повторная отправка покупателю ссылок на все его покупки
+				6) /audit [actor=<actor>] [action=<action>] [target=<target>] --- последние действия администраторов
 			`,
 	)
 }
 
 func (t *Telegram) handleCmdNewPayment(ctx context.Context, update telego.Update) error {
 	chatID := update.Message.Chat.ID
-	t.newPaymentService[chatID] = t.adminClient.NewNewPaymentService()
+	t.newPaymentService[chatID] = t.client(update).NewNewPaymentService()
 	t.fsm[chatID] = stateNewPaymentAmount
 
 	return t.sendMessage(
@@ -115,29 +170,74 @@ func (t *Telegram) handleCmdNewPayment(ctx context.Context, update telego.Update
 }
 
 func (t *Telegram) handleCmdAllPayment(ctx context.Context, update telego.Update) error {
+	chatID := update.Message.Chat.ID
+	delete(t.fsm, chatID)
+
+	text := strings.Split(update.Message.Text, " ")
+	if len(text) > 2 {
+		return t.sendMessage(
+			ctx,
+			update,
+			"telegram.handleCmdAllPayment",
+			"неверный формат команды, ожидается \"/all_payment [status]\"",
+		)
+	}
+
+	service := t.client(update).NewAllPaymentService().Sort(gopay.SortCreatedDesc).Limit(paymentsPageLimit)
+	if len(text) == 2 {
+		service = service.Status(gopay.Status(text[1]))
+	}
+
+	t.allPaymentService[chatID] = service
+
+	return t.sendPaymentsPage(ctx, update, "telegram.handleCmdAllPayment")
+}
+
+func (t *Telegram) handleCmdNextPage(ctx context.Context, update telego.Update) error {
 	delete(t.fsm, update.Message.Chat.ID)
 
-	statuses, err := t.adminClient.NewAllPaymentService().Do()
+	if _, ok := t.allPaymentService[update.Message.Chat.ID]; !ok {
+		return t.sendMessage(
+			ctx,
+			update,
+			"telegram.handleCmdNextPage",
+			"больше платежей нет, для получения списка платежей введите /all_payment",
+		)
+	}
+
+	return t.sendPaymentsPage(ctx, update, "telegram.handleCmdNextPage")
+}
+
+// sendPaymentsPage sends the next page of payments and keeps the cursor for the following one
+func (t *Telegram) sendPaymentsPage(ctx context.Context, update telego.Update, handler string) error {
+	chatID := update.Message.Chat.ID
+	service := t.allPaymentService[chatID]
+
+	page, err := service.Page()
 	if err != nil {
+		delete(t.allPaymentService, chatID)
+
 		return errors.Join(
-			fmt.Errorf("telegram.handleCmdAllPayment: %w", err),
-			t.sendMessage(
-				ctx,
-				update,
-				"telegram.handleCmdAllPayment",
-				"не удалось получить статусы платежей",
-			),
+			fmt.Errorf("%s: %w", handler, err),
+			t.sendMessage(ctx, update, handler, "не удалось получить статусы платежей"),
 		)
 	}
 
 	msg := strings.Builder{}
 	msg.WriteString("список статусов платежей в формате \"id: status\"")
 
-	for id, status := range statuses {
-		msg.WriteString(fmt.Sprintf("\n%s: %s", id, status))
+	for _, payment := range page.Payments {
+		msg.WriteString(fmt.Sprintf("\n%s: %s", payment.ID, payment.Payment.Status))
 	}
 
-	return t.sendMessage(ctx, update, "telegram.handleCmdAllPayment", msg.String())
+	if page.NextCursor == "" {
+		delete(t.allPaymentService, chatID)
+	} else {
+		t.allPaymentService[chatID] = service.Cursor(page.NextCursor)
+		msg.WriteString("\n\nдля следующей страницы введите " + cmdNextPage)
+	}
+
+	return t.sendMessage(ctx, update, handler, msg.String())
 }
 
 func (t *Telegram) handleCmdGetPayment(ctx context.Context, update telego.Update) error {
@@ -154,7 +254,11 @@ func (t *Telegram) handleCmdGetPayment(ctx context.Context, update telego.Update
 	}
 
 	id := text[1]
-	status, err := t.adminClient.NewGetPaymentService().ID(gopay.ID(id)).Do()
+	details, err := t.client(update).NewGetPaymentService().ID(gopay.ID(id)).Details()
+	if errors.Is(err, gopay.ErrNotFound) {
+		return t.sendMessage(ctx, update, "telegram.handleCmdGetPayment", "платеж "+id+" не найден")
+	}
+
 	if err != nil {
 		return errors.Join(
 			fmt.Errorf("telegram.handleCmdGetPayment: %w", err),
@@ -167,14 +271,93 @@ func (t *Telegram) handleCmdGetPayment(ctx context.Context, update telego.Update
 		)
 	}
 
+	return t.sendMessage(ctx, update, "telegram.handleCmdGetPayment", formatPaymentDetails(details))
+}
+
+func (t *Telegram) handleCmdResend(ctx context.Context, update telego.Update) error {
+	delete(t.fsm, update.Message.Chat.ID)
+
+	text := strings.Split(update.Message.Text, " ")
+	if len(text) != 2 {
+		return t.sendMessage(
+			ctx,
+			update,
+			"telegram.handleCmdResend",
+			"неверный формат команды, ожидается \"/resend <email>\"",
+		)
+	}
+
+	email := text[1]
+	sent, err := t.client(update).NewResendService().Email(email).Do()
+	if err != nil {
+		return errors.Join(
+			fmt.Errorf("telegram.handleCmdResend: %w", err),
+			t.sendMessage(
+				ctx,
+				update,
+				"telegram.handleCmdResend",
+				"не удалось отправить покупки на адрес "+email,
+			),
+		)
+	}
+
+	if sent == 0 {
+		return t.sendMessage(
+			ctx,
+			update,
+			"telegram.handleCmdResend",
+			"у покупателя "+email+" нет оплаченных заказов, письмо не отправлено",
+		)
+	}
+
 	return t.sendMessage(
 		ctx,
 		update,
-		"telegram.handleCmdGetPayment",
-		fmt.Sprintf("статус платежа %s: %s", id, status),
+		"telegram.handleCmdResend",
+		fmt.Sprintf("ссылки на покупки (%d) отправлены на адрес %s", sent, email),
 	)
 }
 
+func (t *Telegram) handleCmdAudit(ctx context.Context, update telego.Update) error {
+	delete(t.fsm, update.Message.Chat.ID)
+
+	service := t.client(update).NewAuditService().Limit(auditPageLimit)
+
+	for _, filter := range strings.Fields(update.Message.Text)[1:] {
+		key, value, _ := strings.Cut(filter, "=")
+
+		switch key {
+		case "actor":
+			service = service.Actor(value)
+		case "action":
+			service = service.Action(gopay.AuditAction(value))
+		case "target":
+			service = service.Target(value)
+		default:
+			return t.sendMessage(
+				ctx,
+				update,
+				"telegram.handleCmdAudit",
+				"неверный формат команды, ожидается \"/audit [actor=<actor>] [action=<action>] [target=<target>]\"",
+			)
+		}
+	}
+
+	page, err := service.Do()
+	if err != nil {
+		return errors.Join(
+			fmt.Errorf("telegram.handleCmdAudit: %w", err),
+			t.sendMessage(ctx, update, "telegram.handleCmdAudit", "не удалось получить журнал действий"),
+		)
+	}
+
+	if len(page.Entries) == 0 {
+		return t.sendMessage(ctx, update, "telegram.handleCmdAudit", "в журнале нет подходящих действий")
+	}
+
+	return t.sendMessage(ctx, update, "telegram.handleCmdAudit", formatAuditPage(page))
+}
+
 func (t *Telegram) handleState(ctx context.Context, update telego.Update) error {
 	var err error
 
@@ -307,36 +490,44 @@ func (t *Telegram) handleStateNewPaymentConfirmation(ctx context.Context, update
 	}
 
 	chatID := update.Message.Chat.ID
-	defer delete(t.newPaymentService, chatID)
-	defer delete(t.fsm, chatID)
-
-	if text[0] == "да" {
-		link, err := t.newPaymentService[chatID].Do()
-		if err != nil {
-			return errors.Join(
-				fmt.Errorf("telegram.handleStateNewPaymentConfirmation: %w", err),
-				t.sendMessage(
-					ctx,
-					update,
-					"telegram.handleStateNewPaymentConfirmation",
-					"не удалось создать платеж",
-				),
-			)
-		}
+
+	if text[0] != "да" {
+		delete(t.newPaymentService, chatID)
+		delete(t.fsm, chatID)
 
 		return t.sendMessage(
 			ctx,
 			update,
 			"telegram.handleStateNewPaymentConfirmation",
-			"платеж успешно создан, платежная ссылка:\n"+string(link),
+			"создание нового платежа отменено",
+		)
+	}
+
+	link, err := t.newPaymentService[chatID].Do()
+	if err != nil {
+		// the state is kept and the service sends the same idempotency key again, the server retries
+		// the failed request with the payment ID reserved for the key, so the payment is not created twice
+		msg := "не удалось создать платеж"
+		if errors.Is(err, gopay.ErrProviderUnavailable) {
+			msg += ": платежный сервис недоступен"
+		}
+
+		msg += ", для повторной попытки введите \"да\", для отмены — \"нет\""
+
+		return errors.Join(
+			fmt.Errorf("telegram.handleStateNewPaymentConfirmation: %w", err),
+			t.sendMessage(ctx, update, "telegram.handleStateNewPaymentConfirmation", msg),
 		)
 	}
 
+	delete(t.newPaymentService, chatID)
+	delete(t.fsm, chatID)
+
 	return t.sendMessage(
 		ctx,
 		update,
 		"telegram.handleStateNewPaymentConfirmation",
-		"создание нового платежа отменено",
+		"платеж успешно создан, платежная ссылка:\n"+string(link),
 	)
 }
 
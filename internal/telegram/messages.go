@@ -3,9 +3,13 @@ package telegram
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/mymmrac/telego"
 	tu "github.com/mymmrac/telego/telegoutil"
+
+	"github.com/Anton-Kraev/gopay"
 )
 
 func (t *Telegram) sendMessage(ctx context.Context, update telego.Update, handler, msg string) error {
@@ -19,3 +23,48 @@ func (t *Telegram) sendMessage(ctx context.Context, update telego.Update, handle
 
 	return nil
 }
+
+func formatPaymentDetails(details gopay.PaymentDetails) string {
+	payment := details.Payment
+
+	msg := strings.Builder{}
+	msg.WriteString(fmt.Sprintf("статус платежа %s: %s", details.ID, payment.Status))
+	msg.WriteString(fmt.Sprintf("\nсумма: %d %s", payment.Amount, payment.Currency))
+	msg.WriteString(fmt.Sprintf("\nпокупатель: %s", payment.User.Email))
+	msg.WriteString(fmt.Sprintf("\nсоздан: %s", formatTime(payment.CreatedAt)))
+	msg.WriteString(fmt.Sprintf("\nоплачен: %s", formatTime(payment.PaidAt)))
+
+	if len(payment.History) != 0 {
+		msg.WriteString("\nистория статусов:")
+	}
+
+	for _, change := range payment.History {
+		msg.WriteString(fmt.Sprintf("\n%s %s (%s)", formatTime(change.ChangedAt), change.Status, change.Source))
+	}
+
+	return msg.String()
+}
+
+func formatAuditPage(page gopay.AuditPage) string {
+	msg := strings.Builder{}
+	msg.WriteString("последние действия в формате \"время: кто действие цель\"")
+
+	for _, entry := range page.Entries {
+		actor := entry.Name
+		if entry.OnBehalfOf != "" {
+			actor += " (" + entry.OnBehalfOf + ")"
+		}
+
+		msg.WriteString(fmt.Sprintf("\n%s: %s %s %s", formatTime(entry.At), actor, entry.Action, entry.Target))
+	}
+
+	return msg.String()
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	return t.Format(time.DateTime)
+}
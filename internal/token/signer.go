@@ -0,0 +1,100 @@
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+type claims struct {
+	Purpose string `json:"prp"`
+	Subject string `json:"sub"`
+	Expires int64  `json:"exp,omitempty"`
+	// Nonce makes every issued token unique, so it can be used only once
+	Nonce string `json:"jti"`
+}
+
+// Signer issues and verifies HMAC-signed tokens, purpose of the token is signed as well,
+// so tokens issued for one feature are rejected by another
+type Signer struct {
+	secret []byte
+	now    func() time.Time
+}
+
+func NewSigner(secret string) Signer {
+	return Signer{
+		secret: []byte(secret),
+		now:    time.Now,
+	}
+}
+
+// Sign returns token for the subject, zero ttl means the token never expires
+func (s Signer) Sign(purpose, subject string, ttl time.Duration) (string, error) {
+	nonce := make([]byte, 12)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("token.Signer.Sign: %w", err)
+	}
+
+	c := claims{
+		Purpose: purpose,
+		Subject: subject,
+		Nonce:   base64.RawURLEncoding.EncodeToString(nonce),
+	}
+
+	if ttl > 0 {
+		c.Expires = s.now().Add(ttl).Unix()
+	}
+
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("token.Signer.Sign: %w", err)
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+
+	return encoded + "." + s.signature(encoded), nil
+}
+
+// Verify checks token signature, purpose and expiration and returns its subject
+func (s Signer) Verify(purpose, token string) (string, error) {
+	const op = "token.Signer.Verify"
+
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.signature(encoded))) {
+		return "", fmt.Errorf("%s: %w", op, gopay.ErrInvalidToken)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, gopay.ErrInvalidToken)
+	}
+
+	var c claims
+	if err = json.Unmarshal(payload, &c); err != nil {
+		return "", fmt.Errorf("%s: %w", op, gopay.ErrInvalidToken)
+	}
+
+	if c.Purpose != purpose {
+		return "", fmt.Errorf("%s: %w", op, gopay.ErrInvalidToken)
+	}
+
+	if c.Expires != 0 && s.now().Unix() > c.Expires {
+		return "", fmt.Errorf("%s: %w", op, gopay.ErrTokenExpired)
+	}
+
+	return c.Subject, nil
+}
+
+func (s Signer) signature(encoded string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(encoded))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -2,6 +2,7 @@ package typeconv
 
 import (
 	"fmt"
+	"net"
 	"strconv"
 	"strings"
 )
@@ -21,3 +22,38 @@ func StringToInt64Slice(str string) ([]int64, error) {
 
 	return int64Slice, nil
 }
+
+// StringToIPNets parses comma separated networks in CIDR notation or single IPs, empty string gives no networks
+func StringToIPNets(str string) ([]*net.IPNet, error) {
+	str = strings.TrimSpace(str)
+	if str == "" {
+		return nil, nil
+	}
+
+	strSlice := strings.Split(str, ",")
+	networks := make([]*net.IPNet, len(strSlice))
+
+	for i, el := range strSlice {
+		el = strings.TrimSpace(el)
+
+		if ip := net.ParseIP(el); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+
+			networks[i] = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(el)
+		if err != nil {
+			return nil, fmt.Errorf("typeconv.StringToIPNets: %w", err)
+		}
+
+		networks[i] = network
+	}
+
+	return networks, nil
+}
@@ -1,6 +1,9 @@
 package validator
 
 import (
+	"reflect"
+	"strings"
+
 	"github.com/go-playground/validator/v10"
 )
 
@@ -11,6 +14,9 @@ type Validator struct {
 func NewValidator() (*Validator, error) {
 	validate := validator.New()
 
+	// errors name fields as they are named in requests
+	validate.RegisterTagNameFunc(fieldName)
+
 	if err := validate.RegisterValidation("id", ValidateID); err != nil {
 		return nil, err
 	}
@@ -25,3 +31,13 @@ func NewValidator() (*Validator, error) {
 func (v *Validator) Validate(i any) error {
 	return v.validator.Struct(i)
 }
+
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "query", "form"} {
+		if name, _, _ := strings.Cut(field.Tag.Get(tag), ","); name != "" && name != "-" {
+			return name
+		}
+	}
+
+	return field.Name
+}
@@ -0,0 +1,64 @@
+package memory
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+func (s *Storage) SetAPIKey(key gopay.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.apiKeys[key.ID] = cloneAPIKey(key)
+
+	return nil
+}
+
+func (s *Storage) GetAPIKey(id string) (gopay.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.apiKeys[id]
+	if !ok {
+		return gopay.APIKey{}, fmt.Errorf("memory.Storage.GetAPIKey: %w", errAPIKeyNotFound)
+	}
+
+	return cloneAPIKey(key), nil
+}
+
+// ListAPIKeys returns keys ordered by ID
+func (s *Storage) ListAPIKeys() ([]gopay.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]gopay.APIKey, 0, len(s.apiKeys))
+
+	for _, id := range slices.Sorted(maps.Keys(s.apiKeys)) {
+		keys = append(keys, cloneAPIKey(s.apiKeys[id]))
+	}
+
+	return keys, nil
+}
+
+func (s *Storage) DeleteAPIKey(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.apiKeys[id]; !ok {
+		return fmt.Errorf("memory.Storage.DeleteAPIKey: %w", errAPIKeyNotFound)
+	}
+
+	delete(s.apiKeys, id)
+
+	return nil
+}
+
+// cloneAPIKey copies the scopes, so keys returned to callers do not share memory with stored ones
+func cloneAPIKey(key gopay.APIKey) gopay.APIKey {
+	key.Scopes = slices.Clone(key.Scopes)
+
+	return key
+}
@@ -0,0 +1,87 @@
+package memory
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"slices"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+// AppendAuditEntry saves the entry with the next ID
+func (s *Storage) AppendAuditEntry(entry gopay.AuditEntry) (gopay.AuditEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry.ID = uint64(len(s.audit)) + 1
+	s.audit = append(s.audit, cloneAuditEntry(entry))
+
+	return entry, nil
+}
+
+// AppendAuditEntry buffers the entry in the transaction, so it is saved together with the change,
+// the write lock of the transaction keeps the next ID for it
+func (t *paymentTx) AppendAuditEntry(entry gopay.AuditEntry) (gopay.AuditEntry, error) {
+	entry.ID = uint64(len(t.storage.audit)+len(t.audit)) + 1
+	t.audit = append(t.audit, cloneAuditEntry(entry))
+
+	return entry, nil
+}
+
+// ListAuditEntries walks entries from the newest one, all of them without positive limit, the cursor has
+// the same format as in the Bolt repository: big-endian ID of the last returned entry
+func (s *Storage) ListAuditEntries(filter gopay.AuditFilter) (gopay.AuditPage, error) {
+	var before uint64
+
+	if filter.Cursor != "" {
+		cursor, err := base64.RawURLEncoding.DecodeString(filter.Cursor)
+		if err != nil || len(cursor) != 8 {
+			return gopay.AuditPage{}, fmt.Errorf("memory.Storage.ListAuditEntries: %w", gopay.ErrInvalidCursor)
+		}
+
+		before = binary.BigEndian.Uint64(cursor)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	page := gopay.AuditPage{Entries: []gopay.AuditEntry{}}
+
+	last := len(s.audit)
+	if before != 0 {
+		last = min(last, int(before)-1)
+	}
+
+	for i := last - 1; i >= 0; i-- {
+		entry := s.audit[i]
+
+		// entries are appended in time order, so older ones can not match
+		if !filter.From.IsZero() && entry.At.Before(filter.From) {
+			break
+		}
+
+		if !filter.Match(entry) {
+			continue
+		}
+
+		if filter.Limit > 0 && len(page.Entries) == filter.Limit {
+			id := page.Entries[len(page.Entries)-1].ID
+			page.NextCursor = base64.RawURLEncoding.EncodeToString(binary.BigEndian.AppendUint64(nil, id))
+
+			break
+		}
+
+		page.Entries = append(page.Entries, cloneAuditEntry(entry))
+	}
+
+	return page, nil
+}
+
+// cloneAuditEntry copies the states, so entries returned to callers do not share memory with stored ones
+func cloneAuditEntry(entry gopay.AuditEntry) gopay.AuditEntry {
+	entry.Before = slices.Clone(entry.Before)
+	entry.After = slices.Clone(entry.After)
+
+	return entry
+}
@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+// ReserveIdempotencyKey saves the record unless the key is already saved and not expired, the released key
+// is reserved again by the request with the same hash keeping the saved payment ID
+func (s *Storage) ReserveIdempotencyKey(
+	key string, record gopay.IdempotencyRecord,
+) (gopay.IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	for k, saved := range s.idempotencyKeys {
+		if saved.ExpiresAt.Before(now) {
+			delete(s.idempotencyKeys, k)
+		}
+	}
+
+	saved, ok := s.idempotencyKeys[key]
+	switch {
+	case !ok:
+		saved = record
+	case saved.State == gopay.IdempotencyReleased && saved.RequestHash == record.RequestHash:
+		saved.State = gopay.IdempotencyInProgress
+	default:
+		return saved, false, nil
+	}
+
+	s.idempotencyKeys[key] = saved
+
+	return saved, true, nil
+}
+
+// CompleteIdempotencyKey saves ID of the payment created by the request with the key
+func (s *Storage) CompleteIdempotencyKey(key string, id gopay.ID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saved, ok := s.idempotencyKeys[key]
+	if !ok {
+		return fmt.Errorf("memory.Storage.CompleteIdempotencyKey: %w", errIdempotencyKeyNotFound)
+	}
+
+	saved.PaymentID, saved.State = id, gopay.IdempotencyCompleted
+	s.idempotencyKeys[key] = saved
+
+	return nil
+}
+
+// ReleaseIdempotencyKey marks the request with the key failed, so the request can be retried
+func (s *Storage) ReleaseIdempotencyKey(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if saved, ok := s.idempotencyKeys[key]; ok {
+		saved.State = gopay.IdempotencyReleased
+		s.idempotencyKeys[key] = saved
+	}
+
+	return nil
+}
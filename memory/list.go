@@ -0,0 +1,103 @@
+package memory
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+// listEntry is a payment with the key it is ordered by, the key of the last entry on a page is the cursor
+type listEntry struct {
+	key   []byte
+	entry gopay.PaymentEntry
+}
+
+// ListPayments returns a page of payments, all of them without positive limit, the cursor has the same format
+// as in the Bolt repository: big-endian sort value followed by payment ID
+func (s *Storage) ListPayments(filter gopay.PaymentFilter) (gopay.PaymentPage, error) {
+	var after []byte
+
+	if filter.Cursor != "" {
+		var err error
+
+		after, err = base64.RawURLEncoding.DecodeString(filter.Cursor)
+		if err != nil || len(after) <= 8 {
+			return gopay.PaymentPage{}, fmt.Errorf("memory.Storage.ListPayments: %w", gopay.ErrInvalidCursor)
+		}
+	}
+
+	var (
+		entries []listEntry
+		desc    = strings.HasPrefix(string(filter.Sort), "-")
+		byTime  = filter.Sort == gopay.SortCreatedAsc || filter.Sort == gopay.SortCreatedDesc
+	)
+
+	s.mu.RLock()
+
+	for id, pay := range s.payments {
+		if !filter.Match(pay) {
+			continue
+		}
+
+		key := amountKey(pay.Amount, id)
+		if byTime {
+			key = createdKey(pay, id)
+		}
+
+		if after != nil && (desc && bytes.Compare(key, after) >= 0 || !desc && bytes.Compare(key, after) <= 0) {
+			continue
+		}
+
+		entries = append(entries, listEntry{
+			key:   key,
+			entry: gopay.PaymentEntry{ID: id, Payment: clonePayment(pay)},
+		})
+	}
+
+	s.mu.RUnlock()
+
+	slices.SortFunc(entries, func(a, b listEntry) int {
+		if desc {
+			return bytes.Compare(b.key, a.key)
+		}
+
+		return bytes.Compare(a.key, b.key)
+	})
+
+	var cursor string
+
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[:filter.Limit]
+		cursor = base64.RawURLEncoding.EncodeToString(entries[len(entries)-1].key)
+	}
+
+	page := gopay.PaymentPage{Payments: make([]gopay.PaymentEntry, 0, len(entries)), NextCursor: cursor}
+
+	for _, e := range entries {
+		page.Payments = append(page.Payments, e.entry)
+	}
+
+	return page, nil
+}
+
+// createdKey orders payments by creation time, payments without timestamp go first
+func createdKey(pay gopay.Payment, id gopay.ID) []byte {
+	key := make([]byte, 8, 8+len(id))
+
+	if !pay.CreatedAt.IsZero() && pay.CreatedAt.UnixNano() > 0 {
+		binary.BigEndian.PutUint64(key, uint64(pay.CreatedAt.UnixNano()))
+	}
+
+	return append(key, id...)
+}
+
+func amountKey(amount uint, id gopay.ID) []byte {
+	key := binary.BigEndian.AppendUint64(make([]byte, 0, 8+len(id)), uint64(amount))
+
+	return append(key, id...)
+}
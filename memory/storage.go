@@ -0,0 +1,231 @@
+// Package memory provides gopay.Storage which keeps all data in memory, it is meant for tests, demos
+// and applications embedding GoPay without a database, all data is lost when the process exits,
+// the storage also keeps idempotency keys, API keys and the audit log
+package memory
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+var (
+	errPaymentNotFound = fmt.Errorf("payment %w", gopay.ErrNotFound)
+	errLinkNotFound    = fmt.Errorf("link %w", gopay.ErrNotFound)
+
+	errIdempotencyKeyNotFound = fmt.Errorf("idempotency key %w", gopay.ErrNotFound)
+	errAPIKeyNotFound         = fmt.Errorf("API key %w", gopay.ErrNotFound)
+
+	errFileVersionConflict = fmt.Errorf("file version %w", gopay.ErrAlreadyExists)
+)
+
+// Storage is safe for concurrent use, the zero value is not usable, use NewStorage instead
+type Storage struct {
+	mu sync.RWMutex
+
+	payments map[gopay.ID]gopay.Payment
+	links    map[gopay.ID]gopay.Link
+	files    map[gopay.ID][]gopay.FileVersion
+
+	reminders    map[gopay.ID]gopay.Reminder
+	unsubscribes map[string]time.Time
+	usedTokens   map[string]time.Time
+
+	idempotencyKeys map[string]gopay.IdempotencyRecord
+	apiKeys         map[string]gopay.APIKey
+	// audit is ordered by IDs, the ID of an entry is its position plus one
+	audit []gopay.AuditEntry
+}
+
+var (
+	_ gopay.Storage            = (*Storage)(nil)
+	_ gopay.IdempotencyStorage = (*Storage)(nil)
+	_ gopay.APIKeyStorage      = (*Storage)(nil)
+	_ gopay.AuditStorage       = (*Storage)(nil)
+)
+
+func NewStorage() *Storage {
+	return &Storage{
+		payments:     make(map[gopay.ID]gopay.Payment),
+		links:        make(map[gopay.ID]gopay.Link),
+		files:        make(map[gopay.ID][]gopay.FileVersion),
+		reminders:    make(map[gopay.ID]gopay.Reminder),
+		unsubscribes: make(map[string]time.Time),
+		usedTokens:   make(map[string]time.Time),
+
+		idempotencyKeys: make(map[string]gopay.IdempotencyRecord),
+		apiKeys:         make(map[string]gopay.APIKey),
+	}
+}
+
+func (s *Storage) Get(id gopay.ID) (gopay.Payment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	pay, ok := s.payments[id]
+	if !ok {
+		return gopay.Payment{}, fmt.Errorf("memory.Storage.Get: %w", errPaymentNotFound)
+	}
+
+	return clonePayment(pay), nil
+}
+
+func (s *Storage) GetStatus(id gopay.ID) (gopay.Status, error) {
+	pay, err := s.Get(id)
+	if err != nil {
+		return "", fmt.Errorf("memory.Storage.GetStatus: %w", err)
+	}
+
+	return pay.Status, nil
+}
+
+func (s *Storage) GetStatuses() (map[gopay.ID]gopay.Status, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	statuses := make(map[gopay.ID]gopay.Status, len(s.payments))
+
+	for id, pay := range s.payments {
+		statuses[id] = pay.Status
+	}
+
+	return statuses, nil
+}
+
+func (s *Storage) GetLink(id gopay.ID) (gopay.Link, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	link, ok := s.links[id]
+	if !ok {
+		return "", fmt.Errorf("memory.Storage.GetLink: %w", errLinkNotFound)
+	}
+
+	return link, nil
+}
+
+func (s *Storage) GetProductPayments(productID gopay.ID) (map[gopay.ID]gopay.Payment, error) {
+	return s.filter(func(pay gopay.Payment) bool { return pay.ProductID == productID }), nil
+}
+
+func (s *Storage) GetPaymentsByStatus(status gopay.Status) (map[gopay.ID]gopay.Payment, error) {
+	return s.filter(func(pay gopay.Payment) bool { return status != "" && pay.Status == status }), nil
+}
+
+func (s *Storage) GetPaymentsByEmail(email string) (map[gopay.ID]gopay.Payment, error) {
+	return s.filter(func(pay gopay.Payment) bool {
+		return email != "" && strings.EqualFold(pay.User.Email, email)
+	}), nil
+}
+
+func (s *Storage) filter(match func(pay gopay.Payment) bool) map[gopay.ID]gopay.Payment {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	payments := make(map[gopay.ID]gopay.Payment)
+
+	for id, pay := range s.payments {
+		if match(pay) {
+			payments[id] = clonePayment(pay)
+		}
+	}
+
+	return payments
+}
+
+func (s *Storage) GetFileVersions(id gopay.ID) ([]gopay.FileVersion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return slices.Clone(s.files[id]), nil
+}
+
+func (s *Storage) AddFileVersions(id gopay.ID, versions ...gopay.FileVersion) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.files[id]
+
+	// concurrent uploads must not get the same version number
+	if len(stored) != 0 && len(versions) != 0 && versions[0].Version <= stored[len(stored)-1].Version {
+		return fmt.Errorf("memory.Storage.AddFileVersions: %w", errFileVersionConflict)
+	}
+
+	s.files[id] = append(slices.Clone(stored), versions...)
+
+	return nil
+}
+
+func (s *Storage) GetReminder(id gopay.ID) (gopay.Reminder, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.reminders[id], nil
+}
+
+func (s *Storage) ClaimReminder(id gopay.ID, sent uint, reminder gopay.Reminder) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.reminders[id].Sent != sent {
+		return false, nil
+	}
+
+	s.reminders[id] = reminder
+
+	return true, nil
+}
+
+func (s *Storage) IsUnsubscribed(email string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.unsubscribes[email]
+
+	return ok, nil
+}
+
+func (s *Storage) Unsubscribe(email string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.unsubscribes[email]; !ok {
+		s.unsubscribes[email] = time.Now().UTC()
+	}
+
+	return nil
+}
+
+// MarkTokenUsed saves one-time token until it expires, returns false if it is already used
+func (s *Storage) MarkTokenUsed(token string, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	// expired tokens are rejected by signature check, so there is no need to keep them
+	for k, exp := range s.usedTokens {
+		if now.After(exp) {
+			delete(s.usedTokens, k)
+		}
+	}
+
+	if _, ok := s.usedTokens[token]; ok {
+		return false, nil
+	}
+
+	s.usedTokens[token] = expiresAt
+
+	return true, nil
+}
+
+// clonePayment copies the status history, so payments returned to callers do not share memory with stored ones
+func clonePayment(pay gopay.Payment) gopay.Payment {
+	pay.History = slices.Clone(pay.History)
+
+	return pay
+}
@@ -0,0 +1,39 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/Anton-Kraev/gopay"
+	"github.com/Anton-Kraev/gopay/memory"
+	"github.com/Anton-Kraev/gopay/storagetest"
+)
+
+func TestStorage_Contract(t *testing.T) {
+	t.Parallel()
+
+	storagetest.TestStorage(t, func(*testing.T) gopay.Storage { return memory.NewStorage() })
+}
+
+func TestStorage_IdempotencyKeys(t *testing.T) {
+	t.Parallel()
+
+	storagetest.TestIdempotencyStorage(t, func(*testing.T) gopay.IdempotencyStorage { return memory.NewStorage() })
+}
+
+func TestStorage_APIKeys(t *testing.T) {
+	t.Parallel()
+
+	storagetest.TestAPIKeyStorage(t, func(*testing.T) gopay.APIKeyStorage { return memory.NewStorage() })
+}
+
+func TestStorage_AuditLog(t *testing.T) {
+	t.Parallel()
+
+	storagetest.TestAuditStorage(t, func(*testing.T) gopay.AuditStorage { return memory.NewStorage() })
+}
+
+func TestStorage_AuditTx(t *testing.T) {
+	t.Parallel()
+
+	storagetest.TestAuditTx(t, func(*testing.T) storagetest.AuditTxStorage { return memory.NewStorage() })
+}
@@ -0,0 +1,63 @@
+package memory
+
+import (
+	"fmt"
+	"maps"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+// Update runs fn holding the write lock, changes are buffered in the transaction and applied
+// only if fn returns nil, so concurrent updates are serialized and failed ones leave no trace
+func (s *Storage) Update(fn func(tx gopay.PaymentTx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &paymentTx{
+		storage:  s,
+		payments: make(map[gopay.ID]gopay.Payment),
+		links:    make(map[gopay.ID]gopay.Link),
+	}
+
+	if err := fn(tx); err != nil {
+		return fmt.Errorf("memory.Storage.Update: %w", err)
+	}
+
+	maps.Copy(s.payments, tx.payments)
+	maps.Copy(s.links, tx.links)
+	s.audit = append(s.audit, tx.audit...)
+
+	return nil
+}
+
+type paymentTx struct {
+	storage  *Storage
+	payments map[gopay.ID]gopay.Payment
+	links    map[gopay.ID]gopay.Link
+	audit    []gopay.AuditEntry
+}
+
+func (t *paymentTx) Get(id gopay.ID) (gopay.Payment, error) {
+	pay, ok := t.payments[id]
+	if !ok {
+		pay, ok = t.storage.payments[id]
+	}
+
+	if !ok {
+		return gopay.Payment{}, errPaymentNotFound
+	}
+
+	return clonePayment(pay), nil
+}
+
+func (t *paymentTx) Set(id gopay.ID, pay gopay.Payment) error {
+	t.payments[id] = clonePayment(pay)
+
+	return nil
+}
+
+func (t *paymentTx) SetLink(id gopay.ID, link gopay.Link) error {
+	t.links[id] = link
+
+	return nil
+}
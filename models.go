@@ -3,6 +3,8 @@ package gopay
 import (
 	"net/url"
 	"slices"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -20,6 +22,8 @@ const (
 	StatusWaitingForCapture Status = "waiting_for_capture"
 	StatusSucceeded         Status = "succeeded"
 	StatusCancelled         Status = "canceled"
+	// StatusRefunded is set by the application after the succeeded payment is refunded
+	StatusRefunded Status = "refunded"
 )
 
 func (s Status) Validate() bool {
@@ -28,13 +32,32 @@ func (s Status) Validate() bool {
 		StatusWaitingForCapture,
 		StatusSucceeded,
 		StatusCancelled,
+		StatusRefunded,
 	}, s)
 }
 
+// CanTransition reports whether the payment in the status can be moved to the next one,
+// canceled and refunded payments are final, succeeded payments can only be refunded
+// and waiting for capture can not become pending again
+func (s Status) CanTransition(next Status) bool {
+	switch s {
+	case StatusCancelled, StatusRefunded:
+		return false
+	case StatusSucceeded:
+		return next == StatusRefunded
+	case StatusWaitingForCapture:
+		return next == StatusSucceeded || next == StatusCancelled
+	default:
+		return next != StatusRefunded
+	}
+}
+
 type User struct {
 	ID    ID     `json:"id" validate:"required"`
 	Name  string `json:"name" validate:"required"`
 	Email string `json:"email" validate:"required,email"`
+	// TelegramID is an optional chat identifier used for notifications to the buyer
+	TelegramID int64 `json:"telegram_id,omitempty"`
 }
 
 type Link string
@@ -51,6 +74,47 @@ type Payment struct {
 	Status       Status `json:"status"`
 	PaymentLink  Link   `json:"payment_link"`
 	ResourceLink Link   `json:"resource_link"`
+	ProductID    ID     `json:"product_id,omitempty"`
+	Currency     string `json:"currency,omitempty"`
+	Description  string `json:"description,omitempty"`
+	// ProviderID is the payment ID in the payment service
+	ProviderID string `json:"provider_id,omitempty"`
+	// CreatedAt is zero for payments created before timestamps were introduced
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// PaidAt is zero for payments which have not succeeded
+	PaidAt time.Time `json:"paid_at"`
+	// ExpiresAt is the time until which the payment is to be paid, zero if the time is not limited
+	ExpiresAt time.Time `json:"expires_at"`
+	// History is the append-only list of status changes, the first entry is the status on creation
+	History []StatusChange `json:"history,omitempty"`
+}
+
+// StatusSource tells what changed the payment status
+type StatusSource string
+
+const (
+	// StatusSourceCreation is the status returned by the payment service when the payment is created
+	StatusSourceCreation StatusSource = "creation"
+	// StatusSourceWebhook is the status from the payment service notification
+	StatusSourceWebhook StatusSource = "webhook"
+	// StatusSourceAdmin is the status set by the application with PaymentManager.UpdatePaymentStatus
+	StatusSourceAdmin StatusSource = "admin"
+)
+
+type StatusChange struct {
+	Status    Status       `json:"status"`
+	ChangedAt time.Time    `json:"changed_at"`
+	Source    StatusSource `json:"source"`
+	// RequestID is the ID of the HTTP request which caused the change
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// PaymentEvent is passed to notifiers when payment is created or paid
+type PaymentEvent struct {
+	ID      ID      `json:"id"`
+	Payment Payment `json:"payment"`
+	Link    Link    `json:"link"`
 }
 
 type PaymentTemplate struct {
@@ -58,4 +122,122 @@ type PaymentTemplate struct {
 	Amount       uint   `json:"amount" validate:"required"`
 	Description  string `json:"description" validate:"required"`
 	ResourceLink Link   `json:"resource_link" validate:"required,url"`
+	ProductID    ID     `json:"product_id,omitempty" validate:"omitempty,id"`
+}
+
+type FileVersion struct {
+	Version    uint      `json:"version"`
+	Comment    string    `json:"comment,omitempty"`
+	UploadedAt time.Time `json:"uploaded_at"`
+}
+
+type FileUpdate struct {
+	ProductID ID     `json:"product_id"`
+	Version   uint   `json:"version"`
+	Comment   string `json:"comment,omitempty"`
+	Link      Link   `json:"link"`
+}
+
+// PaymentEntry is a payment with its ID, used where the order of payments matters
+type PaymentEntry struct {
+	ID      ID      `json:"id"`
+	Payment Payment `json:"payment"`
+}
+
+// PaymentDetails is the full payment information for administrators
+type PaymentDetails struct {
+	ID      ID      `json:"id"`
+	Payment Payment `json:"payment"`
+	// Link is the payment link given to the buyer
+	Link Link `json:"link"`
+	// RedirectLink is the current target of the link: payment page before success and resource after
+	RedirectLink Link `json:"redirect_link"`
+}
+
+type PaymentSort string
+
+const (
+	SortCreatedAsc  PaymentSort = "created_at"
+	SortCreatedDesc PaymentSort = "-created_at"
+	SortAmountAsc   PaymentSort = "amount"
+	SortAmountDesc  PaymentSort = "-amount"
+)
+
+func (s PaymentSort) Validate() bool {
+	return slices.Contains([]PaymentSort{
+		SortCreatedAsc,
+		SortCreatedDesc,
+		SortAmountAsc,
+		SortAmountDesc,
+	}, s)
+}
+
+// PaymentFilter selects a page of payments, zero fields are not applied
+type PaymentFilter struct {
+	Status      Status
+	Email       string
+	ProductID   ID
+	CreatedFrom time.Time // inclusive
+	CreatedTo   time.Time // exclusive
+	MinAmount   uint
+	MaxAmount   uint
+	Sort        PaymentSort
+	// Limit is the page size, all payments are returned on one page if it is not positive
+	Limit int
+	// Cursor is NextCursor of the previous page, empty for the first page
+	Cursor string
+}
+
+// Match reports whether payment satisfies all filter conditions
+func (f PaymentFilter) Match(pay Payment) bool {
+	switch {
+	case f.Status != "" && pay.Status != f.Status:
+		return false
+	case f.Email != "" && !strings.EqualFold(pay.User.Email, f.Email):
+		return false
+	case f.ProductID != "" && pay.ProductID != f.ProductID:
+		return false
+	case !f.CreatedFrom.IsZero() && pay.CreatedAt.Before(f.CreatedFrom):
+		return false
+	case !f.CreatedTo.IsZero() && !pay.CreatedAt.Before(f.CreatedTo):
+		return false
+	case f.MinAmount != 0 && pay.Amount < f.MinAmount:
+		return false
+	case f.MaxAmount != 0 && pay.Amount > f.MaxAmount:
+		return false
+	}
+
+	return true
+}
+
+type PaymentPage struct {
+	Payments []PaymentEntry `json:"payments"`
+	// NextCursor is empty for the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Purchase is a succeeded payment with the link to the bought resource
+type Purchase struct {
+	ID      ID      `json:"id"`
+	Payment Payment `json:"payment"`
+	Link    Link    `json:"link"`
+}
+
+type Reminder struct {
+	Sent       uint      `json:"sent"`
+	LastSentAt time.Time `json:"last_sent_at"`
+}
+
+type NotifyChannel string
+
+const (
+	NotifyChannelEmail    NotifyChannel = "email"
+	NotifyChannelTelegram NotifyChannel = "telegram"
+)
+
+func (c NotifyChannel) Validate() bool {
+	return slices.Contains([]NotifyChannel{
+		NotifyChannelEmail,
+		NotifyChannelTelegram,
+	}, c)
 }
@@ -0,0 +1,201 @@
+package gopay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const (
+	tokenPurposeUnsubscribe = "unsubscribe"
+
+	defaultUnsubscribeTTL = 30 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrTokenExpired = errors.New("token expired")
+)
+
+type (
+	// ReminderStorage keeps reminders sent for pending payments and unsubscribed emails,
+	// GetReminder returns zero Reminder for payments without reminders.
+	// ClaimReminder saves the reminder only if Sent of the stored one equals sent, otherwise it reports
+	// the conflict by returning false without an error, so the same reminder is not sent by several processes
+	ReminderStorage interface {
+		GetPaymentsByStatus(status Status) (map[ID]Payment, error)
+		GetReminder(id ID) (Reminder, error)
+		ClaimReminder(id ID, sent uint, reminder Reminder) (bool, error)
+		IsUnsubscribed(email string) (bool, error)
+		Unsubscribe(email string) error
+	}
+
+	tokenSigner interface {
+		Sign(purpose, subject string, ttl time.Duration) (string, error)
+		Verify(purpose, token string) (string, error)
+	}
+
+	reminderLinker interface {
+		Link(id ID) Link
+		UnsubscribeLink(token string) Link
+	}
+
+	reminderNotifier interface {
+		NotifyPaymentReminder(event PaymentEvent, unsubscribeLink Link) error
+	}
+)
+
+type ReminderConfig struct {
+	// Delay is the age of pending payment for the first reminder and the interval between next ones
+	Delay time.Duration
+	// MaxReminders is the maximum number of reminders sent for a single payment
+	MaxReminders uint
+	// UnsubscribeTTL is the lifetime of unsubscribe links, 30 days by default
+	UnsubscribeTTL time.Duration
+}
+
+// ReminderManager reminds buyers about payments abandoned in pending status
+type ReminderManager struct {
+	storage  ReminderStorage
+	signer   tokenSigner
+	links    reminderLinker
+	notifier reminderNotifier
+	config   ReminderConfig
+	now      func() time.Time
+}
+
+func NewReminderManager(
+	reminderStorage ReminderStorage,
+	tokenSigner tokenSigner,
+	reminderLinker reminderLinker,
+	reminderNotifier reminderNotifier,
+	config ReminderConfig,
+) *ReminderManager {
+	if config.UnsubscribeTTL <= 0 {
+		config.UnsubscribeTTL = defaultUnsubscribeTTL
+	}
+
+	return &ReminderManager{
+		storage:  reminderStorage,
+		signer:   tokenSigner,
+		links:    reminderLinker,
+		notifier: reminderNotifier,
+		config:   config,
+		now:      time.Now,
+	}
+}
+
+// Start sends due reminders every interval until context is done
+func (rm *ReminderManager) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sent, err := rm.SendReminders()
+			if err != nil {
+				slog.Default().Error(fmt.Errorf("gopay.ReminderManager.Start: %w", err).Error())
+			}
+
+			if sent != 0 {
+				slog.Default().Info("payment reminders sent", slog.Int("sent", sent))
+			}
+		}
+	}
+}
+
+// SendReminders sends reminders for all pending payments which are due, returns the number of sent reminders
+func (rm *ReminderManager) SendReminders() (int, error) {
+	payments, err := rm.storage.GetPaymentsByStatus(StatusPending)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		sent int
+		errs []error
+		now  = rm.now().UTC()
+	)
+
+	for id, payment := range payments {
+		ok, err := rm.sendReminder(id, payment, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("payment %s: %w", id, err))
+
+			continue
+		}
+
+		if ok {
+			sent++
+		}
+	}
+
+	return sent, errors.Join(errs...)
+}
+
+func (rm *ReminderManager) sendReminder(id ID, payment Payment, now time.Time) (bool, error) {
+	// age of the payments created before timestamps were introduced is unknown
+	if payment.CreatedAt.IsZero() || payment.User.Email == "" {
+		return false, nil
+	}
+
+	reminder, err := rm.storage.GetReminder(id)
+	if err != nil {
+		return false, err
+	}
+
+	if reminder.Sent >= rm.config.MaxReminders {
+		return false, nil
+	}
+
+	if now.Before(payment.CreatedAt.Add(rm.config.Delay * time.Duration(reminder.Sent+1))) {
+		return false, nil
+	}
+
+	email := strings.ToLower(payment.User.Email)
+
+	unsubscribed, err := rm.storage.IsUnsubscribed(email)
+	if err != nil || unsubscribed {
+		return false, err
+	}
+
+	token, err := rm.signer.Sign(tokenPurposeUnsubscribe, email, rm.config.UnsubscribeTTL)
+	if err != nil {
+		return false, err
+	}
+
+	// reminder is claimed before sending, so it is not repeated if the process restarts after sending,
+	// and it is not sent by another process which found it due at the same time
+	claimed := Reminder{Sent: reminder.Sent + 1, LastSentAt: now}
+
+	ok, err := rm.storage.ClaimReminder(id, reminder.Sent, claimed)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	event := PaymentEvent{ID: id, Payment: payment, Link: rm.links.Link(id)}
+
+	if err = rm.notifier.NotifyPaymentReminder(event, rm.links.UnsubscribeLink(token)); err != nil {
+		_, rollbackErr := rm.storage.ClaimReminder(id, claimed.Sent, reminder)
+
+		return false, errors.Join(err, rollbackErr)
+	}
+
+	return true, nil
+}
+
+// Unsubscribe stops reminders for email address from the unsubscribe token
+func (rm *ReminderManager) Unsubscribe(token string) error {
+	email, err := rm.signer.Verify(tokenPurposeUnsubscribe, token)
+	if err != nil {
+		return err
+	}
+
+	return rm.storage.Unsubscribe(email)
+}
@@ -0,0 +1,179 @@
+package gopay_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/Anton-Kraev/gopay"
+	"github.com/Anton-Kraev/gopay/mocks"
+)
+
+type reminderMockFields struct {
+	mockStorage  *mocks.MockReminderStorage
+	mockSigner   *mocks.MocktokenSigner
+	mockLinks    *mocks.MockreminderLinker
+	mockNotifier *mocks.MockreminderNotifier
+}
+
+func setupReminderMocks(ctrl *gomock.Controller) (reminderMockFields, *gopay.ReminderManager) {
+	mf := reminderMockFields{
+		mockStorage:  mocks.NewMockReminderStorage(ctrl),
+		mockSigner:   mocks.NewMocktokenSigner(ctrl),
+		mockLinks:    mocks.NewMockreminderLinker(ctrl),
+		mockNotifier: mocks.NewMockreminderNotifier(ctrl),
+	}
+
+	rm := gopay.NewReminderManager(mf.mockStorage, mf.mockSigner, mf.mockLinks, mf.mockNotifier, gopay.ReminderConfig{
+		Delay:        time.Hour,
+		MaxReminders: 2,
+	})
+
+	return mf, rm
+}
+
+func TestReminderManager_SendReminders(t *testing.T) {
+	t.Parallel()
+
+	user := gopay.User{ID: "1", Name: "name", Email: "Buyer@Mail.com"}
+
+	tests := []struct {
+		name       string
+		payment    gopay.Payment
+		setupMocks func(f reminderMockFields)
+		expected   int
+	}{
+		{
+			name:       "payment without timestamp",
+			payment:    gopay.Payment{User: user},
+			setupMocks: func(_ reminderMockFields) {},
+		},
+		{
+			name:    "first reminder is not due",
+			payment: gopay.Payment{User: user, CreatedAt: time.Now().Add(-30 * time.Minute)},
+			setupMocks: func(f reminderMockFields) {
+				f.mockStorage.EXPECT().GetReminder(gopay.ID("1")).Return(gopay.Reminder{}, nil).Times(1)
+			},
+		},
+		{
+			name:    "second reminder is not due",
+			payment: gopay.Payment{User: user, CreatedAt: time.Now().Add(-90 * time.Minute)},
+			setupMocks: func(f reminderMockFields) {
+				f.mockStorage.EXPECT().GetReminder(gopay.ID("1")).Return(gopay.Reminder{Sent: 1}, nil).Times(1)
+			},
+		},
+		{
+			name:    "reminders limit reached",
+			payment: gopay.Payment{User: user, CreatedAt: time.Now().Add(-10 * time.Hour)},
+			setupMocks: func(f reminderMockFields) {
+				f.mockStorage.EXPECT().GetReminder(gopay.ID("1")).Return(gopay.Reminder{Sent: 2}, nil).Times(1)
+			},
+		},
+		{
+			name:    "unsubscribed",
+			payment: gopay.Payment{User: user, CreatedAt: time.Now().Add(-2 * time.Hour)},
+			setupMocks: func(f reminderMockFields) {
+				f.mockStorage.EXPECT().GetReminder(gopay.ID("1")).Return(gopay.Reminder{}, nil).Times(1)
+				f.mockStorage.EXPECT().IsUnsubscribed("buyer@mail.com").Return(true, nil).Times(1)
+			},
+		},
+		{
+			name:    "claimed by another process",
+			payment: gopay.Payment{User: user, CreatedAt: time.Now().Add(-2 * time.Hour)},
+			setupMocks: func(f reminderMockFields) {
+				f.mockStorage.EXPECT().GetReminder(gopay.ID("1")).Return(gopay.Reminder{}, nil).Times(1)
+				f.mockStorage.EXPECT().IsUnsubscribed("buyer@mail.com").Return(false, nil).Times(1)
+				f.mockSigner.EXPECT().Sign("unsubscribe", "buyer@mail.com", 30*24*time.Hour).
+					Return("token", nil).Times(1)
+				f.mockStorage.EXPECT().ClaimReminder(gopay.ID("1"), uint(0), gomock.Any()).Return(false, nil).Times(1)
+			},
+		},
+		{
+			name:    "send failed",
+			payment: gopay.Payment{User: user, CreatedAt: time.Now().Add(-2 * time.Hour)},
+			setupMocks: func(f reminderMockFields) {
+				f.mockStorage.EXPECT().GetReminder(gopay.ID("1")).Return(gopay.Reminder{}, nil).Times(1)
+				f.mockStorage.EXPECT().IsUnsubscribed("buyer@mail.com").Return(false, nil).Times(1)
+				f.mockSigner.EXPECT().Sign("unsubscribe", "buyer@mail.com", 30*24*time.Hour).
+					Return("token", nil).Times(1)
+				f.mockStorage.EXPECT().ClaimReminder(gopay.ID("1"), uint(0), gomock.Any()).Return(true, nil).Times(1)
+				f.mockLinks.EXPECT().Link(gopay.ID("1")).Return(gopay.Link("https://redirect.com/1")).Times(1)
+				f.mockLinks.EXPECT().UnsubscribeLink("token").Return(gopay.Link("https://unsubscribe.com")).Times(1)
+				f.mockNotifier.EXPECT().NotifyPaymentReminder(gomock.Any(), gopay.Link("https://unsubscribe.com")).
+					Return(errors.New("error send")).Times(1)
+				f.mockStorage.EXPECT().ClaimReminder(gopay.ID("1"), uint(1), gopay.Reminder{}).Return(true, nil).Times(1)
+			},
+		},
+		{
+			name:    "success",
+			payment: gopay.Payment{User: user, CreatedAt: time.Now().Add(-2 * time.Hour)},
+			setupMocks: func(f reminderMockFields) {
+				f.mockStorage.EXPECT().GetReminder(gopay.ID("1")).Return(gopay.Reminder{}, nil).Times(1)
+				f.mockStorage.EXPECT().IsUnsubscribed("buyer@mail.com").Return(false, nil).Times(1)
+				f.mockSigner.EXPECT().Sign("unsubscribe", "buyer@mail.com", 30*24*time.Hour).
+					Return("token", nil).Times(1)
+				f.mockStorage.EXPECT().ClaimReminder(gopay.ID("1"), uint(0), gomock.Any()).
+					DoAndReturn(func(_ gopay.ID, _ uint, reminder gopay.Reminder) (bool, error) {
+						if reminder.Sent != 1 || reminder.LastSentAt.IsZero() {
+							return false, errors.New("error reminder fields")
+						}
+
+						return true, nil
+					}).Times(1)
+				f.mockLinks.EXPECT().Link(gopay.ID("1")).Return(gopay.Link("https://redirect.com/1")).Times(1)
+				f.mockLinks.EXPECT().UnsubscribeLink("token").Return(gopay.Link("https://unsubscribe.com")).Times(1)
+				f.mockNotifier.EXPECT().NotifyPaymentReminder(gomock.Any(), gopay.Link("https://unsubscribe.com")).
+					Return(nil).Times(1)
+			},
+			expected: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			mf, rm := setupReminderMocks(ctrl)
+
+			mf.mockStorage.EXPECT().GetPaymentsByStatus(gopay.StatusPending).
+				Return(map[gopay.ID]gopay.Payment{"1": tt.payment}, nil).Times(1)
+			tt.setupMocks(mf)
+
+			sent, _ := rm.SendReminders()
+
+			assert.Equal(t, tt.expected, sent)
+		})
+	}
+}
+
+func TestReminderManager_Unsubscribe(t *testing.T) {
+	t.Parallel()
+
+	t.Run("invalid token", func(t *testing.T) {
+		t.Parallel()
+
+		ctrl := gomock.NewController(t)
+		mf, rm := setupReminderMocks(ctrl)
+
+		mf.mockSigner.EXPECT().Verify("unsubscribe", "token").Return("", gopay.ErrInvalidToken).Times(1)
+
+		require.ErrorIs(t, rm.Unsubscribe("token"), gopay.ErrInvalidToken)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		ctrl := gomock.NewController(t)
+		mf, rm := setupReminderMocks(ctrl)
+
+		mf.mockSigner.EXPECT().Verify("unsubscribe", "token").Return("buyer@mail.com", nil).Times(1)
+		mf.mockStorage.EXPECT().Unsubscribe("buyer@mail.com").Return(nil).Times(1)
+
+		require.NoError(t, rm.Unsubscribe("token"))
+	})
+}
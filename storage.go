@@ -0,0 +1,10 @@
+package gopay
+
+// Storage is implemented by storages which can be used by all managers at once,
+// see package memory for the in-memory implementation
+type Storage interface {
+	PaymentStorage
+	FileVersionStorage
+	ReminderStorage
+	CustomerStorage
+}
@@ -0,0 +1,55 @@
+package storagetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+// TestAPIKeyStorage checks that storage keeps API keys by their IDs
+func TestAPIKeyStorage(t *testing.T, newStorage func(t *testing.T) gopay.APIKeyStorage) {
+	t.Helper()
+
+	storage := newStorage(t)
+	key := gopay.APIKey{
+		ID:         "id",
+		Name:       "bot",
+		SecretHash: "hash",
+		Scopes:     []gopay.Scope{gopay.ScopePaymentsRead},
+		CreatedAt:  time.Now().UTC().Truncate(time.Second),
+	}
+	other := gopay.APIKey{ID: "a", Name: "ci", SecretHash: "hash", Scopes: []gopay.Scope{gopay.ScopeAdmin}}
+
+	keys, err := storage.ListAPIKeys()
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	require.NoError(t, storage.SetAPIKey(key))
+	require.NoError(t, storage.SetAPIKey(other))
+
+	stored, err := storage.GetAPIKey("id")
+	require.NoError(t, err)
+	assert.Equal(t, key, stored)
+
+	keys, err = storage.ListAPIKeys()
+	require.NoError(t, err)
+	assert.Equal(t, []gopay.APIKey{other, key}, keys)
+
+	// the key is overwritten, e.g. when it is used
+	key.Name = "renamed"
+	require.NoError(t, storage.SetAPIKey(key))
+
+	stored, err = storage.GetAPIKey("id")
+	require.NoError(t, err)
+	assert.Equal(t, key, stored)
+
+	require.NoError(t, storage.DeleteAPIKey("id"))
+
+	_, err = storage.GetAPIKey("id")
+	require.ErrorIs(t, err, gopay.ErrNotFound)
+	require.ErrorIs(t, storage.DeleteAPIKey("id"), gopay.ErrNotFound)
+}
@@ -0,0 +1,125 @@
+package storagetest
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+// TestAuditStorage checks that storage appends audit entries with growing IDs and lists newest entries first
+func TestAuditStorage(t *testing.T, newStorage func(t *testing.T) gopay.AuditStorage) {
+	t.Helper()
+
+	storage := newStorage(t)
+	start := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	page, err := storage.ListAuditEntries(gopay.AuditFilter{Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, page.Entries)
+	assert.Empty(t, page.NextCursor)
+
+	var first uint64
+
+	for i, person := range []string{"telegram:1", "telegram:2", "telegram:1", "telegram:3"} {
+		entry, err := storage.AppendAuditEntry(gopay.AuditEntry{
+			At:     start.Add(time.Duration(i) * time.Hour),
+			Actor:  gopay.Actor{Name: "api_key:bot", OnBehalfOf: person},
+			Action: gopay.AuditPaymentCreate,
+			Target: "payment:" + strconv.Itoa(i),
+			After:  []byte(`{"amount":100}`),
+		})
+		require.NoError(t, err)
+
+		if i == 0 {
+			first = entry.ID
+		}
+
+		assert.Equal(t, first+uint64(i), entry.ID)
+	}
+
+	page, err = storage.ListAuditEntries(gopay.AuditFilter{Actor: "telegram:1", Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, page.Entries, 1)
+	assert.Equal(t, first+2, page.Entries[0].ID)
+	assert.Equal(t, "payment:2", page.Entries[0].Target)
+	assert.JSONEq(t, `{"amount":100}`, string(page.Entries[0].After))
+	require.NotEmpty(t, page.NextCursor)
+
+	page, err = storage.ListAuditEntries(gopay.AuditFilter{Actor: "telegram:1", Limit: 1, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, page.Entries, 1)
+	assert.Equal(t, first, page.Entries[0].ID)
+	assert.Empty(t, page.NextCursor)
+
+	page, err = storage.ListAuditEntries(gopay.AuditFilter{Actor: "api_key:bot", From: start.Add(time.Hour), Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page.Entries, 3)
+	assert.Equal(t, first+3, page.Entries[0].ID)
+
+	page, err = storage.ListAuditEntries(gopay.AuditFilter{Target: "payment:1", To: start.Add(time.Hour), Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, page.Entries)
+
+	_, err = storage.ListAuditEntries(gopay.AuditFilter{Limit: 10, Cursor: "bad"})
+	require.ErrorIs(t, err, gopay.ErrInvalidCursor)
+}
+
+// AuditTxStorage keeps payments and the audit log in the same database
+type AuditTxStorage interface {
+	gopay.PaymentStorage
+	gopay.AuditStorage
+}
+
+// TestAuditTx checks that an audit entry appended in a payment transaction is committed
+// together with the change and discarded on rollback
+func TestAuditTx(t *testing.T, newStorage func(t *testing.T) AuditTxStorage) {
+	t.Helper()
+
+	storage := newStorage(t)
+	entry := gopay.AuditEntry{
+		At:     time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+		Actor:  gopay.Actor{Name: "api_key:bot"},
+		Action: gopay.AuditPaymentCreate,
+		Target: "payment:1",
+	}
+
+	update := func(id gopay.ID, target string, fail error) error {
+		return storage.Update(func(tx gopay.PaymentTx) error {
+			atx, ok := tx.(gopay.AuditTx)
+			require.True(t, ok, "transaction does not append audit entries")
+
+			if err := tx.Set(id, newPayment(1)); err != nil {
+				return err
+			}
+
+			entry.Target = target
+			if _, err := atx.AppendAuditEntry(entry); err != nil {
+				return err
+			}
+
+			return fail
+		})
+	}
+
+	errRollback := errors.New("rollback")
+	require.ErrorIs(t, update("2", "payment:2", errRollback), errRollback)
+	require.NoError(t, update("1", "payment:1", nil))
+
+	_, err := storage.Get("1")
+	require.NoError(t, err)
+
+	_, err = storage.Get("2")
+	require.Error(t, err)
+
+	page, err := storage.ListAuditEntries(gopay.AuditFilter{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page.Entries, 1)
+	assert.Equal(t, "payment:1", page.Entries[0].Target)
+	assert.Equal(t, entry.Actor, page.Entries[0].Actor)
+}
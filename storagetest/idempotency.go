@@ -0,0 +1,83 @@
+package storagetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+// TestIdempotencyStorage checks that storage keeps idempotency keys until they expire, released keys are reserved again by retries
+func TestIdempotencyStorage(t *testing.T, newStorage func(t *testing.T) gopay.IdempotencyStorage) {
+	t.Helper()
+
+	storage := newStorage(t)
+	record := gopay.IdempotencyRecord{
+		RequestHash: "hash",
+		PaymentID:   "1",
+		State:       gopay.IdempotencyInProgress,
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}
+
+	saved, reserved, err := storage.ReserveIdempotencyKey("key", record)
+	require.NoError(t, err)
+	assert.True(t, reserved)
+	assert.Equal(t, gopay.ID("1"), saved.PaymentID)
+
+	// the key is in progress until the payment is saved
+	saved, reserved, err = storage.ReserveIdempotencyKey("key", gopay.IdempotencyRecord{RequestHash: "other"})
+	require.NoError(t, err)
+	assert.False(t, reserved)
+	assert.Equal(t, "hash", saved.RequestHash)
+	assert.Equal(t, gopay.IdempotencyInProgress, saved.State)
+
+	require.NoError(t, storage.CompleteIdempotencyKey("key", "1"))
+
+	saved, reserved, err = storage.ReserveIdempotencyKey("key", record)
+	require.NoError(t, err)
+	assert.False(t, reserved)
+	assert.Equal(t, gopay.ID("1"), saved.PaymentID)
+	assert.Equal(t, gopay.IdempotencyCompleted, saved.State)
+
+	require.ErrorIs(t, storage.CompleteIdempotencyKey("unknown", "1"), gopay.ErrNotFound)
+
+	// released keys are reserved again only by the same request, the payment ID of the first attempt is kept
+	_, reserved, err = storage.ReserveIdempotencyKey("released", record)
+	require.NoError(t, err)
+	require.True(t, reserved)
+	require.NoError(t, storage.ReleaseIdempotencyKey("released"))
+
+	saved, reserved, err = storage.ReserveIdempotencyKey("released", gopay.IdempotencyRecord{RequestHash: "other"})
+	require.NoError(t, err)
+	assert.False(t, reserved)
+	assert.Equal(t, gopay.IdempotencyReleased, saved.State)
+
+	retry := record
+	retry.PaymentID = "2"
+
+	saved, reserved, err = storage.ReserveIdempotencyKey("released", retry)
+	require.NoError(t, err)
+	assert.True(t, reserved)
+	assert.Equal(t, gopay.ID("1"), saved.PaymentID)
+	assert.Equal(t, gopay.IdempotencyInProgress, saved.State)
+
+	// the retry is in progress
+	_, reserved, err = storage.ReserveIdempotencyKey("released", retry)
+	require.NoError(t, err)
+	assert.False(t, reserved)
+
+	// expired keys can be reserved again
+	_, reserved, err = storage.ReserveIdempotencyKey(
+		"expired", gopay.IdempotencyRecord{PaymentID: "1", ExpiresAt: time.Now().Add(-time.Second)},
+	)
+	require.NoError(t, err)
+	require.True(t, reserved)
+
+	saved, reserved, err = storage.ReserveIdempotencyKey("expired", retry)
+	require.NoError(t, err)
+	assert.True(t, reserved)
+	assert.Equal(t, gopay.ID("2"), saved.PaymentID)
+}
@@ -0,0 +1,215 @@
+package storagetest
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+// StorageFactory returns a new empty storage, resources of the storage should be released with t.Cleanup
+type StorageFactory func(t *testing.T) gopay.Storage
+
+// TestStorage checks that storage follows the contracts of all storages combined by gopay.Storage,
+// payment storage is checked by TestPaymentStorage
+func TestStorage(t *testing.T, newStorage StorageFactory) {
+	t.Helper()
+
+	TestPaymentStorage(t, func(t *testing.T) gopay.PaymentStorage { return newStorage(t) })
+
+	for name, test := range map[string]func(t *testing.T, storage gopay.Storage){
+		"FileVersions":     testFileVersions,
+		"ProductPayments":  testProductPayments,
+		"PaymentsByStatus": testPaymentsByStatus,
+		"PaymentsByEmail":  testPaymentsByEmail,
+		"Reminders":        testReminders,
+		"Unsubscribe":      testUnsubscribe,
+		"MarkTokenUsed":    testMarkTokenUsed,
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			test(t, newStorage(t))
+		})
+	}
+}
+
+func testFileVersions(t *testing.T, storage gopay.Storage) {
+	versions, err := storage.GetFileVersions("file")
+	require.NoError(t, err)
+	assert.Empty(t, versions)
+
+	first := []gopay.FileVersion{
+		{Version: 1, UploadedAt: baseTime},
+		{Version: 2, Comment: "fix", UploadedAt: baseTime.Add(time.Hour)},
+	}
+
+	require.NoError(t, storage.AddFileVersions("file", first...))
+
+	// concurrent uploads of the same version must not both succeed
+	require.ErrorIs(t, storage.AddFileVersions("file", gopay.FileVersion{Version: 2}), gopay.ErrAlreadyExists)
+	require.ErrorIs(t, storage.AddFileVersions("file", gopay.FileVersion{Version: 1}), gopay.ErrAlreadyExists)
+
+	third := gopay.FileVersion{Version: 3, Comment: "new edition", UploadedAt: baseTime.Add(2 * time.Hour)}
+	require.NoError(t, storage.AddFileVersions("file", third))
+	require.NoError(t, storage.AddFileVersions("other", gopay.FileVersion{Version: 1, UploadedAt: baseTime}))
+
+	versions, err = storage.GetFileVersions("file")
+	require.NoError(t, err)
+	assert.Equal(t, append(first, third), versions)
+
+	versions, err = storage.GetFileVersions("other")
+	require.NoError(t, err)
+	assert.Len(t, versions, 1)
+}
+
+// setPayments saves payments newPayment(0), ..., newPayment(n-1) with IDs p0, ..., p<n-1>
+func setPayments(t *testing.T, storage gopay.PaymentStorage, n int) map[gopay.ID]gopay.Payment {
+	t.Helper()
+
+	payments := make(map[gopay.ID]gopay.Payment, n)
+
+	for i := range n {
+		id := gopay.ID(fmt.Sprintf("p%d", i))
+		payments[id] = newPayment(i)
+
+		set(t, storage, id, payments[id], "https://example.com")
+	}
+
+	return payments
+}
+
+func testProductPayments(t *testing.T, storage gopay.Storage) {
+	payments := setPayments(t, storage, 4)
+
+	found, err := storage.GetProductPayments("product0")
+	require.NoError(t, err)
+	assert.Equal(t, map[gopay.ID]gopay.Payment{"p0": payments["p0"], "p2": payments["p2"]}, found)
+
+	found, err = storage.GetProductPayments("unknown")
+	require.NoError(t, err)
+	assert.Empty(t, found)
+}
+
+func testPaymentsByStatus(t *testing.T, storage gopay.Storage) {
+	payments := setPayments(t, storage, 3)
+
+	paid := payments["p1"]
+	paid.Status = gopay.StatusSucceeded
+	set(t, storage, "p1", paid, "https://example.com")
+
+	// the payment must not be found by the previous status
+	found, err := storage.GetPaymentsByStatus(gopay.StatusPending)
+	require.NoError(t, err)
+	assert.Equal(t, []gopay.ID{"p0", "p2"}, slices.Sorted(maps.Keys(found)))
+
+	found, err = storage.GetPaymentsByStatus(gopay.StatusSucceeded)
+	require.NoError(t, err)
+	assert.Equal(t, map[gopay.ID]gopay.Payment{"p1": paid}, found)
+}
+
+func testPaymentsByEmail(t *testing.T, storage gopay.Storage) {
+	payments := setPayments(t, storage, 3)
+
+	found, err := storage.GetPaymentsByEmail("BUYER0@mail.com")
+	require.NoError(t, err)
+	assert.Equal(t, map[gopay.ID]gopay.Payment{"p0": payments["p0"], "p2": payments["p2"]}, found)
+
+	moved := payments["p2"]
+	moved.User.Email = "other@mail.com"
+	set(t, storage, "p2", moved, "https://example.com")
+
+	// the payment must not be found by the previous email
+	found, err = storage.GetPaymentsByEmail("buyer0@mail.com")
+	require.NoError(t, err)
+	assert.Equal(t, []gopay.ID{"p0"}, slices.Sorted(maps.Keys(found)))
+
+	found, err = storage.GetPaymentsByEmail("other@mail.com")
+	require.NoError(t, err)
+	assert.Equal(t, map[gopay.ID]gopay.Payment{"p2": moved}, found)
+}
+
+func testReminders(t *testing.T, storage gopay.Storage) {
+	reminder, err := storage.GetReminder("1")
+	require.NoError(t, err)
+	assert.Zero(t, reminder)
+
+	for _, expected := range []gopay.Reminder{
+		{Sent: 1, LastSentAt: baseTime},
+		{Sent: 2, LastSentAt: baseTime.Add(time.Hour)},
+	} {
+		claimed, err := storage.ClaimReminder("1", expected.Sent-1, expected)
+		require.NoError(t, err)
+		assert.True(t, claimed)
+
+		// another process found the same reminder due
+		claimed, err = storage.ClaimReminder("1", expected.Sent-1, expected)
+		require.NoError(t, err)
+		assert.False(t, claimed)
+
+		reminder, err = storage.GetReminder("1")
+		require.NoError(t, err)
+		assert.Equal(t, expected, reminder)
+	}
+
+	// rollback of the first reminder after failed sending, so it can be claimed again
+	for _, sent := range []uint{2, 1} {
+		claimed, err := storage.ClaimReminder("1", sent, gopay.Reminder{Sent: sent - 1, LastSentAt: baseTime})
+		require.NoError(t, err)
+		assert.True(t, claimed)
+	}
+
+	claimed, err := storage.ClaimReminder("1", 0, gopay.Reminder{Sent: 1, LastSentAt: baseTime})
+	require.NoError(t, err)
+	assert.True(t, claimed)
+
+	claimed, err = storage.ClaimReminder("2", 1, gopay.Reminder{Sent: 2, LastSentAt: baseTime})
+	require.NoError(t, err)
+	assert.False(t, claimed)
+
+	reminder, err = storage.GetReminder("2")
+	require.NoError(t, err)
+	assert.Zero(t, reminder)
+}
+
+func testUnsubscribe(t *testing.T, storage gopay.Storage) {
+	unsubscribed, err := storage.IsUnsubscribed("buyer@mail.com")
+	require.NoError(t, err)
+	assert.False(t, unsubscribed)
+
+	require.NoError(t, storage.Unsubscribe("buyer@mail.com"))
+	require.NoError(t, storage.Unsubscribe("buyer@mail.com"))
+
+	unsubscribed, err = storage.IsUnsubscribed("buyer@mail.com")
+	require.NoError(t, err)
+	assert.True(t, unsubscribed)
+
+	unsubscribed, err = storage.IsUnsubscribed("other@mail.com")
+	require.NoError(t, err)
+	assert.False(t, unsubscribed)
+}
+
+func testMarkTokenUsed(t *testing.T, storage gopay.Storage) {
+	marked, err := storage.MarkTokenUsed("token", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.True(t, marked)
+
+	marked, err = storage.MarkTokenUsed("token", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.False(t, marked)
+
+	// expired tokens are not kept, they are rejected by signature check
+	marked, err = storage.MarkTokenUsed("expired", time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	assert.True(t, marked)
+
+	marked, err = storage.MarkTokenUsed("expired", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.True(t, marked)
+}
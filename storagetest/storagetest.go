@@ -0,0 +1,432 @@
+// Package storagetest implements tests for storage backends, every implementation of gopay.PaymentStorage
+// is expected to pass TestPaymentStorage and every implementation of gopay.Storage is expected to pass TestStorage
+package storagetest
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Anton-Kraev/gopay"
+)
+
+// Factory returns a new empty storage, resources of the storage should be released with t.Cleanup
+type Factory func(t *testing.T) gopay.PaymentStorage
+
+// TestPaymentStorage checks that storage follows the contract of payment and link storage,
+// every subtest runs in parallel with its own storage returned by newStorage
+func TestPaymentStorage(t *testing.T, newStorage Factory) {
+	t.Helper()
+
+	for name, test := range map[string]func(t *testing.T, storage gopay.PaymentStorage){
+		"NotFound":          testNotFound,
+		"SetAndGet":         testSetAndGet,
+		"Overwrite":         testOverwrite,
+		"Rollback":          testRollback,
+		"ReadYourWrites":    testReadYourWrites,
+		"ConcurrentUpdates": testConcurrentUpdates,
+		"ListFilters":       testListFilters,
+		"ListPagination":    testListPagination,
+		"ListInvalidCursor": testListInvalidCursor,
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			test(t, newStorage(t))
+		})
+	}
+}
+
+var baseTime = time.Date(2025, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+func newPayment(n int) gopay.Payment {
+	return gopay.Payment{
+		User: gopay.User{
+			ID:    gopay.ID(fmt.Sprintf("user%d", n)),
+			Name:  "name",
+			Email: fmt.Sprintf("Buyer%d@Mail.com", n%2),
+		},
+		Amount:       uint(100 * (n%3 + 1)),
+		Status:       gopay.StatusPending,
+		PaymentLink:  gopay.Link(fmt.Sprintf("https://pay.example.com/%d", n)),
+		ResourceLink: "https://example.com/resource",
+		ProductID:    gopay.ID(fmt.Sprintf("product%d", n%2)),
+		Currency:     "RUB",
+		CreatedAt:    baseTime.Add(time.Duration(n) * time.Minute),
+		UpdatedAt:    baseTime.Add(time.Duration(n) * time.Minute),
+		History: []gopay.StatusChange{{
+			Status:    gopay.StatusPending,
+			ChangedAt: baseTime.Add(time.Duration(n) * time.Minute),
+			Source:    gopay.StatusSourceCreation,
+		}},
+	}
+}
+
+func set(t *testing.T, storage gopay.PaymentStorage, id gopay.ID, pay gopay.Payment, link gopay.Link) {
+	t.Helper()
+
+	require.NoError(t, storage.Update(func(tx gopay.PaymentTx) error {
+		if err := tx.Set(id, pay); err != nil {
+			return err
+		}
+
+		return tx.SetLink(id, link)
+	}))
+}
+
+func testNotFound(t *testing.T, storage gopay.PaymentStorage) {
+	_, err := storage.Get("unknown")
+	require.ErrorIs(t, err, gopay.ErrNotFound)
+
+	_, err = storage.GetStatus("unknown")
+	require.ErrorIs(t, err, gopay.ErrNotFound)
+
+	_, err = storage.GetLink("unknown")
+	require.ErrorIs(t, err, gopay.ErrNotFound)
+
+	require.ErrorIs(t, storage.Update(func(tx gopay.PaymentTx) error {
+		_, err := tx.Get("unknown")
+
+		return err
+	}), gopay.ErrNotFound)
+
+	statuses, err := storage.GetStatuses()
+	require.NoError(t, err)
+	assert.Empty(t, statuses)
+
+	page, err := storage.ListPayments(gopay.PaymentFilter{Sort: gopay.SortCreatedDesc, Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, page.Payments)
+	assert.Empty(t, page.NextCursor)
+}
+
+func testSetAndGet(t *testing.T, storage gopay.PaymentStorage) {
+	pay := newPayment(1)
+	pay.PaidAt = baseTime.Add(time.Hour)
+	pay.ProviderID = "provider"
+
+	set(t, storage, "1", pay, "https://example.com/1")
+
+	stored, err := storage.Get("1")
+	require.NoError(t, err)
+	assert.Equal(t, pay, stored)
+
+	status, err := storage.GetStatus("1")
+	require.NoError(t, err)
+	assert.Equal(t, gopay.StatusPending, status)
+
+	link, err := storage.GetLink("1")
+	require.NoError(t, err)
+	assert.Equal(t, gopay.Link("https://example.com/1"), link)
+
+	statuses, err := storage.GetStatuses()
+	require.NoError(t, err)
+	assert.Equal(t, map[gopay.ID]gopay.Status{"1": gopay.StatusPending}, statuses)
+}
+
+func testOverwrite(t *testing.T, storage gopay.PaymentStorage) {
+	set(t, storage, "1", newPayment(1), "https://example.com/old")
+
+	pay := newPayment(1)
+	pay.Status = gopay.StatusSucceeded
+	pay.User.Email = "other@mail.com"
+	pay.Amount = 1000
+
+	set(t, storage, "1", pay, "https://example.com/new")
+
+	stored, err := storage.Get("1")
+	require.NoError(t, err)
+	assert.Equal(t, pay, stored)
+
+	link, err := storage.GetLink("1")
+	require.NoError(t, err)
+	assert.Equal(t, gopay.Link("https://example.com/new"), link)
+
+	statuses, err := storage.GetStatuses()
+	require.NoError(t, err)
+	assert.Equal(t, map[gopay.ID]gopay.Status{"1": gopay.StatusSucceeded}, statuses)
+
+	// previous values must not be found by filters
+	for _, filter := range []gopay.PaymentFilter{
+		{Status: gopay.StatusPending},
+		{Email: newPayment(1).User.Email},
+		{MaxAmount: 999},
+	} {
+		filter.Sort, filter.Limit = gopay.SortCreatedDesc, 10
+
+		page, err := storage.ListPayments(filter)
+		require.NoError(t, err)
+		assert.Empty(t, page.Payments, "filter %+v", filter)
+	}
+
+	page, err := storage.ListPayments(gopay.PaymentFilter{
+		Status: gopay.StatusSucceeded,
+		Email:  "OTHER@mail.com",
+		Sort:   gopay.SortCreatedDesc,
+		Limit:  10,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []gopay.PaymentEntry{{ID: "1", Payment: pay}}, page.Payments)
+}
+
+func testRollback(t *testing.T, storage gopay.PaymentStorage) {
+	set(t, storage, "1", newPayment(1), "https://example.com/1")
+
+	errRollback := errors.New("rollback")
+
+	err := storage.Update(func(tx gopay.PaymentTx) error {
+		pay := newPayment(1)
+		pay.Status = gopay.StatusSucceeded
+
+		if err := tx.Set("1", pay); err != nil {
+			return err
+		}
+
+		if err := tx.Set("2", newPayment(2)); err != nil {
+			return err
+		}
+
+		if err := tx.SetLink("1", "https://example.com/changed"); err != nil {
+			return err
+		}
+
+		return errRollback
+	})
+	require.ErrorIs(t, err, errRollback)
+
+	stored, err := storage.Get("1")
+	require.NoError(t, err)
+	assert.Equal(t, newPayment(1), stored)
+
+	link, err := storage.GetLink("1")
+	require.NoError(t, err)
+	assert.Equal(t, gopay.Link("https://example.com/1"), link)
+
+	_, err = storage.Get("2")
+	require.Error(t, err)
+
+	statuses, err := storage.GetStatuses()
+	require.NoError(t, err)
+	assert.Equal(t, map[gopay.ID]gopay.Status{"1": gopay.StatusPending}, statuses)
+}
+
+func testReadYourWrites(t *testing.T, storage gopay.PaymentStorage) {
+	require.NoError(t, storage.Update(func(tx gopay.PaymentTx) error {
+		if err := tx.Set("1", newPayment(1)); err != nil {
+			return err
+		}
+
+		pay, err := tx.Get("1")
+		if err != nil {
+			return err
+		}
+
+		assert.Equal(t, newPayment(1), pay)
+
+		pay.Status = gopay.StatusSucceeded
+
+		return tx.Set("1", pay)
+	}))
+
+	status, err := storage.GetStatus("1")
+	require.NoError(t, err)
+	assert.Equal(t, gopay.StatusSucceeded, status)
+}
+
+func testConcurrentUpdates(t *testing.T, storage gopay.PaymentStorage) {
+	const updates = 20
+
+	pay := newPayment(1)
+	pay.Amount = 0
+
+	set(t, storage, "1", pay, "https://example.com/1")
+
+	var wg sync.WaitGroup
+
+	for range updates {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			assert.NoError(t, storage.Update(func(tx gopay.PaymentTx) error {
+				pay, err := tx.Get("1")
+				if err != nil {
+					return err
+				}
+
+				pay.Amount++
+
+				return tx.Set("1", pay)
+			}))
+		}()
+	}
+
+	wg.Wait()
+
+	stored, err := storage.Get("1")
+	require.NoError(t, err)
+	assert.Equal(t, uint(updates), stored.Amount)
+}
+
+func testListFilters(t *testing.T, storage gopay.PaymentStorage) {
+	for n := range 6 {
+		pay := newPayment(n)
+		if n%3 == 0 {
+			pay.Status = gopay.StatusSucceeded
+		}
+
+		set(t, storage, gopay.ID(fmt.Sprintf("p%d", n)), pay, "https://example.com")
+	}
+
+	for _, tt := range []struct {
+		name     string
+		filter   gopay.PaymentFilter
+		expected []gopay.ID
+	}{
+		{
+			name:     "newest first",
+			filter:   gopay.PaymentFilter{Sort: gopay.SortCreatedDesc},
+			expected: []gopay.ID{"p5", "p4", "p3", "p2", "p1", "p0"},
+		},
+		{
+			name:     "oldest first",
+			filter:   gopay.PaymentFilter{Sort: gopay.SortCreatedAsc},
+			expected: []gopay.ID{"p0", "p1", "p2", "p3", "p4", "p5"},
+		},
+		{
+			name:     "amount ascending with ties broken by id",
+			filter:   gopay.PaymentFilter{Sort: gopay.SortAmountAsc},
+			expected: []gopay.ID{"p0", "p3", "p1", "p4", "p2", "p5"},
+		},
+		{
+			name:     "amount descending",
+			filter:   gopay.PaymentFilter{Sort: gopay.SortAmountDesc},
+			expected: []gopay.ID{"p5", "p2", "p4", "p1", "p3", "p0"},
+		},
+		{
+			name:     "status",
+			filter:   gopay.PaymentFilter{Status: gopay.StatusSucceeded, Sort: gopay.SortCreatedAsc},
+			expected: []gopay.ID{"p0", "p3"},
+		},
+		{
+			name:     "email ignoring case",
+			filter:   gopay.PaymentFilter{Email: "buyer1@mail.COM", Sort: gopay.SortCreatedAsc},
+			expected: []gopay.ID{"p1", "p3", "p5"},
+		},
+		{
+			name:     "product",
+			filter:   gopay.PaymentFilter{ProductID: "product0", Sort: gopay.SortCreatedAsc},
+			expected: []gopay.ID{"p0", "p2", "p4"},
+		},
+		{
+			name: "creation time range",
+			filter: gopay.PaymentFilter{
+				CreatedFrom: baseTime.Add(time.Minute),
+				CreatedTo:   baseTime.Add(4 * time.Minute),
+				Sort:        gopay.SortCreatedDesc,
+			},
+			expected: []gopay.ID{"p3", "p2", "p1"},
+		},
+		{
+			name:     "amount range",
+			filter:   gopay.PaymentFilter{MinAmount: 200, MaxAmount: 300, Sort: gopay.SortCreatedAsc},
+			expected: []gopay.ID{"p1", "p2", "p4", "p5"},
+		},
+		{
+			name: "combined",
+			filter: gopay.PaymentFilter{
+				Status:    gopay.StatusPending,
+				Email:     "buyer0@mail.com",
+				MinAmount: 300,
+				Sort:      gopay.SortAmountDesc,
+			},
+			expected: []gopay.ID{"p2"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.filter.Limit = 10
+
+			page, err := storage.ListPayments(tt.filter)
+			require.NoError(t, err)
+			assert.Empty(t, page.NextCursor)
+
+			ids := make([]gopay.ID, 0, len(page.Payments))
+			for _, entry := range page.Payments {
+				ids = append(ids, entry.ID)
+			}
+
+			assert.Equal(t, tt.expected, ids)
+		})
+	}
+}
+
+func testListPagination(t *testing.T, storage gopay.PaymentStorage) {
+	const payments = 11
+
+	for n := range payments {
+		set(t, storage, gopay.ID(fmt.Sprintf("p%02d", n)), newPayment(n), "https://example.com")
+	}
+
+	for _, filter := range []gopay.PaymentFilter{
+		{Sort: gopay.SortCreatedAsc},
+		{Sort: gopay.SortCreatedDesc},
+		{Sort: gopay.SortAmountAsc},
+		{Sort: gopay.SortAmountDesc},
+		{Sort: gopay.SortCreatedDesc, Status: gopay.StatusPending},
+		{Sort: gopay.SortAmountAsc, Email: "buyer0@mail.com"},
+	} {
+		t.Run(string(filter.Sort)+filter.Email+string(filter.Status), func(t *testing.T) {
+			all := filter
+			all.Limit = payments
+
+			expected, err := storage.ListPayments(all)
+			require.NoError(t, err)
+			require.Empty(t, expected.NextCursor)
+
+			filter.Limit = 3
+
+			var paged []gopay.PaymentEntry
+
+			for {
+				page, err := storage.ListPayments(filter)
+				require.NoError(t, err)
+				require.LessOrEqual(t, len(page.Payments), filter.Limit)
+
+				paged = append(paged, page.Payments...)
+
+				if page.NextCursor == "" {
+					break
+				}
+
+				require.Len(t, page.Payments, filter.Limit)
+
+				filter.Cursor = page.NextCursor
+			}
+
+			assert.Equal(t, expected.Payments, paged)
+
+			// without limit all payments are on one page
+			filter.Limit, filter.Cursor = 0, ""
+
+			page, err := storage.ListPayments(filter)
+			require.NoError(t, err)
+			assert.Empty(t, page.NextCursor)
+			assert.Equal(t, expected.Payments, page.Payments)
+		})
+	}
+}
+
+func testListInvalidCursor(t *testing.T, storage gopay.PaymentStorage) {
+	set(t, storage, "1", newPayment(1), "https://example.com")
+
+	for _, cursor := range []string{"not base64!", "c2hvcnQ"} {
+		_, err := storage.ListPayments(gopay.PaymentFilter{Sort: gopay.SortCreatedDesc, Limit: 10, Cursor: cursor})
+		require.ErrorIs(t, err, gopay.ErrInvalidCursor, cursor)
+	}
+}